@@ -5,6 +5,8 @@ go 1.25.5
 require (
 	database v0.0.0-00010101000000-000000000000
 	github.com/aws/aws-sdk-go v1.55.8
+	github.com/chai2010/webp v1.4.0
+	github.com/disintegration/imaging v1.6.2
 	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
@@ -17,6 +19,7 @@ require (
 require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/mattn/go-sqlite3 v1.14.33 // indirect
+	golang.org/x/image v0.45.0 // indirect
 )
 
 replace database => ../../database
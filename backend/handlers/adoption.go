@@ -0,0 +1,677 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxAdoptionQuestions = 30
+
+// adoptionTransitions - допустимые переходы статусов заявки
+var adoptionTransitions = map[string][]string{
+	models.AdoptionStatusSubmitted: {models.AdoptionStatusInterview, models.AdoptionStatusApproved, models.AdoptionStatusRejected},
+	models.AdoptionStatusInterview: {models.AdoptionStatusApproved, models.AdoptionStatusRejected},
+	models.AdoptionStatusApproved:  {models.AdoptionStatusCompleted, models.AdoptionStatusRejected},
+}
+
+var adoptionStatusMessages = map[string]string{
+	models.AdoptionStatusInterview: "Вас приглашают на собеседование по заявке на %s",
+	models.AdoptionStatusApproved:  "Ваша заявка на %s одобрена",
+	models.AdoptionStatusRejected:  "Ваша заявка на %s отклонена",
+	models.AdoptionStatusCompleted: "Поздравляем! %s теперь ваш питомец",
+}
+
+const adoptionApplicationColumns = `
+	a.id, a.announcement_id, a.pet_id, a.organization_id, a.applicant_id,
+	a.questions, a.answers, a.message, a.status, a.status_note, a.assigned_to,
+	a.created_at, a.updated_at, a.completed_at,
+	trim(coalesce(u.name, '') || ' ' || coalesce(u.last_name, '')), coalesce(u.avatar, ''),
+	coalesce(pa.title, ''), coalesce(p.name, '')`
+
+const adoptionApplicationJoins = `
+	FROM adoption_applications a
+	LEFT JOIN users u ON u.id = a.applicant_id
+	LEFT JOIN pet_announcements pa ON pa.id = a.announcement_id
+	LEFT JOIN pets p ON p.id = a.pet_id`
+
+// AdoptionFormHandler - анкета организации для заявок на усыновление
+// GET /api/adoption/forms/{organization_id}
+// PUT /api/adoption/forms/{organization_id} {"questions": [...]}
+func AdoptionFormHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/adoption/forms/"))
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID организации", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			form, err := loadAdoptionForm(db, orgID)
+			if err != nil {
+				sendErrorResponse(w, "Ошибка получения анкеты: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			sendSuccessResponse(w, form)
+		case http.MethodPut:
+			saveAdoptionForm(w, r, db, orgID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// AdoptionApplicationsHandler - подача заявки (POST) и списки заявок (GET)
+// GET /api/adoption/applications - мои заявки
+// GET /api/adoption/applications?announcement_id=5 - заявки по объявлению (для приюта)
+// GET /api/adoption/applications?assigned=me - заявки, назначенные мне
+func AdoptionApplicationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		switch r.Method {
+		case http.MethodGet:
+			listAdoptionApplications(w, r, db, userID)
+		case http.MethodPost:
+			createAdoptionApplication(w, r, db, userID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// AdoptionApplicationHandler - одна заявка
+// GET /api/adoption/applications/{id}
+// POST /api/adoption/applications/{id}/status {"status": "interview", "note": "..."}
+// POST /api/adoption/applications/{id}/assign {"user_id": 12}
+func AdoptionApplicationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/adoption/applications/"), "/"), "/")
+		appID, err := strconv.Atoi(parts[0])
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID заявки", http.StatusBadRequest)
+			return
+		}
+
+		app, err := loadAdoptionApplication(db, appID)
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, "Заявка не найдена", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения заявки: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		canManage := canManageAdoption(db, userID, app)
+
+		action := ""
+		if len(parts) > 1 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			if !canManage && app.ApplicantID != userID {
+				sendErrorResponse(w, "Нет доступа к заявке", http.StatusForbidden)
+				return
+			}
+			sendSuccessResponse(w, app)
+		case action == "status" && r.Method == http.MethodPost:
+			if !canManage {
+				sendErrorResponse(w, "Нет прав на обработку заявки", http.StatusForbidden)
+				return
+			}
+			updateAdoptionStatus(w, r, db, userID, app)
+		case action == "assign" && r.Method == http.MethodPost:
+			if !canManage {
+				sendErrorResponse(w, "Нет прав на обработку заявки", http.StatusForbidden)
+				return
+			}
+			assignAdoptionApplication(w, r, db, userID, app)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func loadAdoptionForm(db *sql.DB, orgID int) (*models.AdoptionForm, error) {
+	form := &models.AdoptionForm{OrganizationID: orgID, Questions: []models.AdoptionQuestion{}}
+
+	var questionsJSON string
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT questions, updated_at FROM adoption_forms WHERE organization_id = ?
+	`), orgID).Scan(&questionsJSON, &form.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return form, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal([]byte(questionsJSON), &form.Questions)
+	return form, nil
+}
+
+func saveAdoptionForm(w http.ResponseWriter, r *http.Request, db *sql.DB, orgID int) {
+	userID := r.Context().Value("userID").(int)
+
+	if !isOrganizationEditor(db, userID, orgID) {
+		sendErrorResponse(w, "Нет прав на изменение анкеты организации", http.StatusForbidden)
+		return
+	}
+
+	var form models.AdoptionForm
+	if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateAdoptionQuestions(form.Questions); msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	questionsJSON, _ := json.Marshal(form.Questions)
+	_, err := db.Exec(ConvertPlaceholders(`
+		INSERT INTO adoption_forms (organization_id, questions, updated_by, updated_at)
+		VALUES (?, ?, ?, NOW())
+		ON CONFLICT (organization_id) DO UPDATE
+		SET questions = EXCLUDED.questions, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`), orgID, string(questionsJSON), userID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка сохранения анкеты: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	saved, err := loadAdoptionForm(db, orgID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения анкеты", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("📋 Organization %d adoption form updated by user %d (%d questions)", orgID, userID, len(saved.Questions))
+	sendSuccessResponse(w, saved)
+}
+
+func createAdoptionApplication(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) {
+	var req models.CreateAdoptionApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	var announcementType, status, title string
+	var authorID, petID, ownerID int
+	var orgID sql.NullInt64
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT pa.type, pa.status, pa.title, pa.author_id, pa.pet_id, p.user_id, p.organization_id
+		FROM pet_announcements pa
+		JOIN pets p ON p.id = pa.pet_id
+		WHERE pa.id = ? AND pa.is_published = 1
+	`), req.AnnouncementID).Scan(&announcementType, &status, &title, &authorID, &petID, &ownerID, &orgID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Объявление не найдено", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения объявления: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if announcementType != "looking_for_home" || status != "active" {
+		sendErrorResponse(w, "Заявки принимаются только по активным объявлениям \"ищет дом\"", http.StatusBadRequest)
+		return
+	}
+	if authorID == userID || ownerID == userID {
+		sendErrorResponse(w, "Нельзя подать заявку на своего питомца", http.StatusBadRequest)
+		return
+	}
+
+	var hasOpen bool
+	db.QueryRow(ConvertPlaceholders(`
+		SELECT EXISTS(
+			SELECT 1 FROM adoption_applications
+			WHERE announcement_id = ? AND applicant_id = ? AND status NOT IN ('rejected', 'completed')
+		)
+	`), req.AnnouncementID, userID).Scan(&hasOpen)
+	if hasOpen {
+		sendErrorResponse(w, "Вы уже подали заявку на этого питомца", http.StatusConflict)
+		return
+	}
+
+	// Анкета организации питомца; у частных объявлений её нет - только сообщение
+	questions := []models.AdoptionQuestion{}
+	var orgIDPtr *int
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		orgIDPtr = &id
+		form, err := loadAdoptionForm(db, id)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения анкеты: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		questions = form.Questions
+	}
+
+	answers, msg := validateAdoptionAnswers(questions, req.Answers)
+	if msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	questionsJSON, _ := json.Marshal(questions)
+	answersJSON, _ := json.Marshal(answers)
+
+	var appID int
+	err = db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO adoption_applications (announcement_id, pet_id, organization_id, applicant_id, questions, answers, message, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), req.AnnouncementID, petID, orgIDPtr, userID, string(questionsJSON), string(answersJSON),
+		strings.TrimSpace(req.Message), models.AdoptionStatusSubmitted).Scan(&appID)
+	if err != nil {
+		log.Printf("❌ Error creating adoption application: %v", err)
+		sendErrorResponse(w, "Ошибка подачи заявки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	notifHandler := &NotificationsHandler{DB: db}
+	if err := notifHandler.CreateNotification(authorID, userID, "adoption_application", "adoption_application", appID,
+		"Новая заявка на усыновление: "+title); err != nil {
+		log.Printf("⚠️ Failed to notify author %d about adoption application: %v", authorID, err)
+	}
+
+	app, err := loadAdoptionApplication(db, appID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения заявки", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🏠 Adoption application %d for announcement %d submitted by user %d", appID, req.AnnouncementID, userID)
+	sendSuccessResponse(w, app)
+}
+
+func listAdoptionApplications(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) {
+	limit, cursor, err := parseCursorPage(r, 20, 100)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
+	}
+
+	query := `SELECT ` + adoptionApplicationColumns + adoptionApplicationJoins + ` WHERE `
+	args := []interface{}{}
+
+	switch {
+	case r.URL.Query().Get("announcement_id") != "":
+		announcementID, err := strconv.Atoi(r.URL.Query().Get("announcement_id"))
+		if err != nil {
+			sendErrorResponse(w, "Неверный announcement_id", http.StatusBadRequest)
+			return
+		}
+		if !canManageAnnouncementAdoptions(db, userID, announcementID) {
+			sendErrorResponse(w, "Нет прав на просмотр заявок по объявлению", http.StatusForbidden)
+			return
+		}
+		query += `a.announcement_id = ?`
+		args = append(args, announcementID)
+	case r.URL.Query().Get("assigned") == "me":
+		query += `a.assigned_to = ?`
+		args = append(args, userID)
+	default:
+		query += `a.applicant_id = ?`
+		args = append(args, userID)
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += ` AND a.status = ?`
+		args = append(args, status)
+	}
+	if cursor != nil {
+		query += ` AND ` + cursorCondition("a.created_at", "a.id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	query += ` ORDER BY a.created_at DESC, a.id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения заявок: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	apps := []models.AdoptionApplication{}
+	for rows.Next() {
+		app, err := scanAdoptionApplication(rows)
+		if err != nil {
+			log.Printf("❌ Error scanning adoption application: %v", err)
+			continue
+		}
+		apps = append(apps, *app)
+	}
+
+	nextCursor := ""
+	if len(apps) > limit {
+		apps = apps[:limit]
+		last := apps[len(apps)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	sendPageResponse(w, apps, nextCursor)
+}
+
+func updateAdoptionStatus(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int, app *models.AdoptionApplication) {
+	var req models.UpdateAdoptionStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Ошибка обновления заявки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Статус перечитывается под блокировкой: два сотрудника не завершат заявку дважды
+	var current string
+	if err := tx.QueryRow(ConvertPlaceholders(`
+		SELECT status FROM adoption_applications WHERE id = ? FOR UPDATE
+	`), app.ID).Scan(&current); err != nil {
+		sendErrorResponse(w, "Ошибка обновления заявки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !adoptionTransitionAllowed(current, req.Status) {
+		sendErrorResponse(w, fmt.Sprintf("Нельзя перевести заявку из статуса %s в %s", current, req.Status), http.StatusBadRequest)
+		return
+	}
+
+	_, err = tx.Exec(ConvertPlaceholders(`
+		UPDATE adoption_applications
+		SET status = ?, status_note = ?, updated_at = NOW(),
+		    completed_at = CASE WHEN ? = 'completed' THEN NOW() ELSE completed_at END
+		WHERE id = ?
+	`), req.Status, strings.TrimSpace(req.Note), req.Status, app.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка обновления заявки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Status == models.AdoptionStatusCompleted {
+		if err := completeAdoption(tx, userID, app); err != nil {
+			log.Printf("❌ Adoption %d: handover failed: %v", app.ID, err)
+			sendErrorResponse(w, "Ошибка передачи питомца: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Ошибка обновления заявки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	notifHandler := &NotificationsHandler{DB: db}
+	message := fmt.Sprintf(adoptionStatusMessages[req.Status], app.PetName)
+	if err := notifHandler.CreateNotification(app.ApplicantID, userID, "adoption_status", "adoption_application", app.ID, message); err != nil {
+		log.Printf("⚠️ Failed to notify applicant %d: %v", app.ApplicantID, err)
+	}
+
+	updated, err := loadAdoptionApplication(db, app.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения заявки", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Adoption application %d: %s -> %s by user %d", app.ID, current, req.Status, userID)
+	sendSuccessResponse(w, updated)
+}
+
+// completeAdoption передаёт питомца заявителю, закрывает объявление
+// и отклоняет остальные открытые заявки. Выполняется в транзакции смены статуса.
+func completeAdoption(tx *sql.Tx, userID int, app *models.AdoptionApplication) error {
+	var previousStatus sql.NullString
+	if err := tx.QueryRow(ConvertPlaceholders(`
+		SELECT status FROM pets WHERE id = ? FOR UPDATE
+	`), app.PetID).Scan(&previousStatus); err != nil {
+		return err
+	}
+
+	// Смена владельца с записью в историю владения (как при обычной передаче)
+	if err := transferPetOwnership(tx, app.PetID, app.ApplicantID, nil, userID, "adoption", nil); err != nil {
+		return err
+	}
+
+	if previousStatus.String != "home" {
+		if _, err := tx.Exec(ConvertPlaceholders("UPDATE pets SET status = 'home' WHERE id = ?"), app.PetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ConvertPlaceholders(`
+			INSERT INTO pet_changes (pet_id, changed_by, field, old_value, new_value)
+			VALUES (?, ?, 'status', ?, 'home')
+		`), app.PetID, userID, previousStatus.String); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ConvertPlaceholders(`
+		UPDATE pet_announcements
+		SET status = 'closed', status_reason = 'adopted', closed_at = NOW(), updated_at = NOW()
+		WHERE id = ?
+	`), app.AnnouncementID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ConvertPlaceholders(`
+		UPDATE adoption_applications
+		SET status = 'rejected', status_note = 'Питомец передан другому заявителю', updated_at = NOW()
+		WHERE announcement_id = ? AND id <> ? AND status NOT IN ('rejected', 'completed')
+	`), app.AnnouncementID, app.ID)
+	return err
+}
+
+func assignAdoptionApplication(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int, app *models.AdoptionApplication) {
+	var req struct {
+		UserID *int `json:"user_id"` // null - снять назначение
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID != nil {
+		if app.OrganizationID == nil {
+			sendErrorResponse(w, "Назначение доступно только для заявок организаций", http.StatusBadRequest)
+			return
+		}
+		var isMember bool
+		db.QueryRow(ConvertPlaceholders(`
+			SELECT EXISTS(SELECT 1 FROM organization_members WHERE organization_id = ? AND user_id = ?)
+		`), *app.OrganizationID, *req.UserID).Scan(&isMember)
+		if !isMember {
+			sendErrorResponse(w, "Пользователь не состоит в организации", http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, err := db.Exec(ConvertPlaceholders(`
+		UPDATE adoption_applications SET assigned_to = ?, updated_at = NOW() WHERE id = ?
+	`), req.UserID, app.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка назначения: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.UserID != nil {
+		notifHandler := &NotificationsHandler{DB: db}
+		message := fmt.Sprintf("Вам назначена заявка на усыновление: %s", app.PetName)
+		if err := notifHandler.CreateNotification(*req.UserID, userID, "adoption_assigned", "adoption_application", app.ID, message); err != nil {
+			log.Printf("⚠️ Failed to notify assignee %d: %v", *req.UserID, err)
+		}
+	}
+
+	app.AssignedTo = req.UserID
+	sendSuccessResponse(w, app)
+}
+
+func loadAdoptionApplication(db *sql.DB, appID int) (*models.AdoptionApplication, error) {
+	row := db.QueryRow(ConvertPlaceholders(`SELECT `+adoptionApplicationColumns+adoptionApplicationJoins+` WHERE a.id = ?`), appID)
+	return scanAdoptionApplication(row)
+}
+
+func scanAdoptionApplication(row interface{ Scan(...interface{}) error }) (*models.AdoptionApplication, error) {
+	var a models.AdoptionApplication
+	var orgID, assignedTo sql.NullInt64
+	var questionsJSON, answersJSON string
+
+	err := row.Scan(
+		&a.ID, &a.AnnouncementID, &a.PetID, &orgID, &a.ApplicantID,
+		&questionsJSON, &answersJSON, &a.Message, &a.Status, &a.StatusNote, &assignedTo,
+		&a.CreatedAt, &a.UpdatedAt, &a.CompletedAt,
+		&a.ApplicantName, &a.ApplicantAvatar, &a.AnnouncementTitle, &a.PetName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		a.OrganizationID = &id
+	}
+	if assignedTo.Valid {
+		id := int(assignedTo.Int64)
+		a.AssignedTo = &id
+	}
+	a.Questions = []models.AdoptionQuestion{}
+	a.Answers = map[string]string{}
+	json.Unmarshal([]byte(questionsJSON), &a.Questions)
+	json.Unmarshal([]byte(answersJSON), &a.Answers)
+
+	return &a, nil
+}
+
+// canManageAdoption - автор объявления или редактор организации питомца
+func canManageAdoption(db *sql.DB, userID int, app *models.AdoptionApplication) bool {
+	if app.OrganizationID != nil && isOrganizationEditor(db, userID, *app.OrganizationID) {
+		return true
+	}
+
+	var authorID int
+	db.QueryRow(ConvertPlaceholders("SELECT author_id FROM pet_announcements WHERE id = ?"), app.AnnouncementID).Scan(&authorID)
+	return authorID == userID
+}
+
+func canManageAnnouncementAdoptions(db *sql.DB, userID, announcementID int) bool {
+	var authorID int
+	var orgID sql.NullInt64
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT pa.author_id, p.organization_id
+		FROM pet_announcements pa
+		JOIN pets p ON p.id = pa.pet_id
+		WHERE pa.id = ?
+	`), announcementID).Scan(&authorID, &orgID)
+	if err != nil {
+		return false
+	}
+
+	return authorID == userID || (orgID.Valid && isOrganizationEditor(db, userID, int(orgID.Int64)))
+}
+
+// isOrganizationEditor - участник организации с правом can_edit
+func isOrganizationEditor(db *sql.DB, userID, orgID int) bool {
+	var canEdit bool
+	db.QueryRow(ConvertPlaceholders(`
+		SELECT can_edit FROM organization_members
+		WHERE organization_id = ? AND user_id = ?
+	`), orgID, userID).Scan(&canEdit)
+	return canEdit
+}
+
+func adoptionTransitionAllowed(from, to string) bool {
+	for _, allowed := range adoptionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func validateAdoptionQuestions(questions []models.AdoptionQuestion) string {
+	if len(questions) > maxAdoptionQuestions {
+		return fmt.Sprintf("В анкете может быть не более %d вопросов", maxAdoptionQuestions)
+	}
+
+	seen := map[string]bool{}
+	for i := range questions {
+		q := &questions[i]
+		q.ID = strings.TrimSpace(q.ID)
+		q.Text = strings.TrimSpace(q.Text)
+
+		if q.ID == "" || q.Text == "" {
+			return "У каждого вопроса должны быть id и текст"
+		}
+		if seen[q.ID] {
+			return "Повторяющийся id вопроса: " + q.ID
+		}
+		seen[q.ID] = true
+
+		switch q.Type {
+		case "text", "boolean":
+			q.Options = nil
+		case "choice":
+			if len(q.Options) < 2 {
+				return "У вопроса с выбором должно быть минимум два варианта: " + q.ID
+			}
+		default:
+			return "Недопустимый тип вопроса: " + q.Type
+		}
+	}
+	return ""
+}
+
+// validateAdoptionAnswers проверяет ответы по анкете и отбрасывает ответы на несуществующие вопросы
+func validateAdoptionAnswers(questions []models.AdoptionQuestion, answers map[string]string) (map[string]string, string) {
+	result := map[string]string{}
+
+	for _, q := range questions {
+		answer := strings.TrimSpace(answers[q.ID])
+		if answer == "" {
+			if q.Required {
+				return nil, "Ответьте на обязательный вопрос: " + q.Text
+			}
+			continue
+		}
+
+		switch q.Type {
+		case "boolean":
+			if answer != "true" && answer != "false" {
+				return nil, "Ответ должен быть true или false: " + q.Text
+			}
+		case "choice":
+			valid := false
+			for _, option := range q.Options {
+				if option == answer {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, "Выберите один из вариантов: " + q.Text
+			}
+		}
+
+		result[q.ID] = answer
+	}
+
+	return result, ""
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAlertZonesPerUser = 5
+	maxAlertRadiusKm     = 50.0
+	// maxLostPetAlertsPerDay - сколько оповещений о потерянных/найденных животных
+	// пользователь получает максимум за 24 часа, остальные отбрасываются
+	maxLostPetAlertsPerDay = 10
+)
+
+// AlertZonesHandler - список зон оповещений (GET) и создание новой (POST)
+func AlertZonesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(int)
+
+		switch r.Method {
+		case http.MethodGet:
+			getAlertZones(w, db, userID)
+		case http.MethodPost:
+			createAlertZone(w, r, db, userID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// AlertZoneHandler - удаление зоны оповещений
+// DELETE /api/alerts/zones/{id}
+func AlertZoneHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(int)
+
+		zoneID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/alerts/zones/"))
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID зоны", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(ConvertPlaceholders("DELETE FROM alert_zones WHERE id = ? AND user_id = ?"), zoneID, userID)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка удаления зоны: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			sendErrorResponse(w, "Зона не найдена", http.StatusNotFound)
+			return
+		}
+
+		sendSuccessResponse(w, map[string]string{"message": "Зона удалена"})
+	}
+}
+
+func getAlertZones(w http.ResponseWriter, db *sql.DB, userID int) {
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT id, user_id, name, city, lat, lon, radius_km, created_at
+		FROM alert_zones
+		WHERE user_id = ?
+		ORDER BY created_at
+	`), userID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения зон: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	zones := []models.AlertZone{}
+	for rows.Next() {
+		var z models.AlertZone
+		if err := rows.Scan(&z.ID, &z.UserID, &z.Name, &z.City, &z.Lat, &z.Lon, &z.RadiusKm, &z.CreatedAt); err != nil {
+			log.Printf("❌ Error scanning alert zone: %v", err)
+			continue
+		}
+		zones = append(zones, z)
+	}
+
+	sendSuccessResponse(w, zones)
+}
+
+func createAlertZone(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) {
+	var req models.CreateAlertZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if req.City != nil {
+		city := strings.TrimSpace(*req.City)
+		req.City = &city
+		if city == "" {
+			req.City = nil
+		}
+	}
+
+	hasPoint := req.Lat != nil && req.Lon != nil
+	if (req.City == nil) == !hasPoint {
+		sendErrorResponse(w, "Укажите либо город, либо точку с радиусом", http.StatusBadRequest)
+		return
+	}
+
+	if hasPoint {
+		if *req.Lat < -90 || *req.Lat > 90 || *req.Lon < -180 || *req.Lon > 180 {
+			sendErrorResponse(w, "Неверные координаты", http.StatusBadRequest)
+			return
+		}
+		if req.RadiusKm == nil || *req.RadiusKm <= 0 || *req.RadiusKm > maxAlertRadiusKm {
+			sendErrorResponse(w, fmt.Sprintf("Радиус должен быть от 0 до %.0f км", maxAlertRadiusKm), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.RadiusKm = nil
+	}
+
+	var count int
+	db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM alert_zones WHERE user_id = ?"), userID).Scan(&count)
+	if count >= maxAlertZonesPerUser {
+		sendErrorResponse(w, fmt.Sprintf("Можно создать не более %d зон", maxAlertZonesPerUser), http.StatusBadRequest)
+		return
+	}
+
+	zone := models.AlertZone{
+		UserID:   userID,
+		Name:     strings.TrimSpace(req.Name),
+		City:     req.City,
+		Lat:      req.Lat,
+		Lon:      req.Lon,
+		RadiusKm: req.RadiusKm,
+	}
+
+	err := db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO alert_zones (user_id, name, city, lat, lon, radius_km)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`), zone.UserID, zone.Name, zone.City, zone.Lat, zone.Lon, zone.RadiusKm).Scan(&zone.ID, &zone.CreatedAt)
+	if err != nil {
+		log.Printf("❌ Error creating alert zone: %v", err)
+		sendErrorResponse(w, "Ошибка создания зоны: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("📍 User %d created alert zone %d", userID, zone.ID)
+	sendSuccessResponse(w, zone)
+}
+
+// notifyAlertZones рассылает оповещение о новом объявлении "lost"/"found"
+// всем, чья зона совпадает по городу или попадает в радиус.
+// Вызывается в горутине после создания объявления.
+func notifyAlertZones(db *sql.DB, announcementID, authorID int, announcementType, title string, city *string, lat, lon *float64) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if city != nil && strings.TrimSpace(*city) != "" {
+		conditions = append(conditions, "(z.city IS NOT NULL AND lower(z.city) = lower(?))")
+		args = append(args, strings.TrimSpace(*city))
+	}
+	if lat != nil && lon != nil {
+		// Радиус зоны не больше maxAlertRadiusKm: сначала отсекаем по индексу (lat, lon)
+		around := geoQuery{Lat: *lat, Lon: *lon, RadiusKm: maxAlertRadiusKm}
+		box, boxArgs := around.boxCondition("z.lat", "z.lon")
+		conditions = append(conditions, "(z.lat IS NOT NULL AND "+box+" AND "+distanceSQL("z.lat", "z.lon")+" <= z.radius_km)")
+		args = append(args, boxArgs...)
+		args = append(args, *lat, *lat, *lon)
+	}
+	if len(conditions) == 0 {
+		return
+	}
+
+	query := `
+		SELECT DISTINCT z.user_id
+		FROM alert_zones z
+		WHERE z.user_id <> ? AND (` + strings.Join(conditions, " OR ") + `)`
+	args = append([]interface{}{authorID}, args...)
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		log.Printf("❌ Failed to match alert zones for announcement %d: %v", announcementID, err)
+		return
+	}
+
+	userIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	notifType := "lost_pet_alert"
+	message := "Рядом с вами потерялось животное: " + title
+	if announcementType == "found" {
+		notifType = "found_pet_alert"
+		message = "Рядом с вами нашли животное: " + title
+	}
+
+	notifHandler := &NotificationsHandler{DB: db}
+	delivered := 0
+	for _, userID := range userIDs {
+		recorded, err := recordLostPetAlertDelivery(db, userID, announcementID)
+		if err != nil {
+			log.Printf("⚠️ Failed to record alert delivery for user %d: %v", userID, err)
+			continue
+		}
+		if !recorded {
+			continue
+		}
+
+		if err := notifHandler.CreateNotification(userID, authorID, notifType, "announcement", announcementID, message); err != nil {
+			log.Printf("⚠️ Failed to notify user %d about announcement %d: %v", userID, announcementID, err)
+		}
+
+		SendToUser(userID, notifType, map[string]interface{}{
+			"announcement_id": announcementID,
+			"type":            announcementType,
+			"title":           title,
+			"city":            city,
+			"lat":             lat,
+			"lon":             lon,
+		})
+		delivered++
+	}
+
+	log.Printf("📣 Announcement %d (%s): %d matched, %d alerted", announcementID, announcementType, len(userIDs), delivered)
+}
+
+// recordLostPetAlertDelivery записывает доставку оповещения, если дневной лимит
+// пользователя не исчерпан. Проверки одного пользователя идут по очереди
+// (advisory lock), поэтому параллельные объявления не превышают лимит.
+// UNIQUE (user_id, announcement_id) защищает от повторов при нескольких совпавших зонах.
+func recordLostPetAlertDelivery(db *sql.DB, userID, announcementID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ConvertPlaceholders(`SELECT pg_advisory_xact_lock(hashtext('lost_pet_alerts'), ?)`), userID); err != nil {
+		return false, err
+	}
+
+	result, err := tx.Exec(ConvertPlaceholders(`
+		INSERT INTO lost_pet_alert_deliveries (user_id, announcement_id)
+		SELECT ?, ?
+		WHERE (
+			SELECT COUNT(*) FROM lost_pet_alert_deliveries
+			WHERE user_id = ? AND created_at > NOW() - INTERVAL '24 hours'
+		) < ?
+		ON CONFLICT (user_id, announcement_id) DO NOTHING
+	`), userID, announcementID, userID, maxLostPetAlertsPerDay)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
@@ -49,24 +49,43 @@ func AnnouncementHandler(w http.ResponseWriter, r *http.Request) {
 
 // handleGetAnnouncements - получить список объявлений с фильтрами
 func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	// Поиск по радиусу: ?lat=55.75&lon=37.61&radius_km=10
+	geo, err := parseGeoQuery(r)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parseCursorPage(r, 20, 100)
+	if err != nil {
+		sendError(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	// Фильтры
+	params := []interface{}{}
+
+	distanceColumn := "NULL::double precision"
+	if geo != nil {
+		distanceColumn = distanceSQL("location_lat", "location_lon")
+		params = append(params, geo.Lat, geo.Lat, geo.Lon)
+	}
+
 	query := `
 		SELECT id, pet_id, type, title, description, author_id,
 		       contact_person_id, contact_person_name, contact_person_phone,
-		       location_city, location_address, location_coordinates,
+		       location_city, location_address, location_coordinates, location_lat, location_lon,
 		       event_date, event_time,
 		       lost_last_seen_location, lost_distinctive_features, lost_reward_amount,
 		       found_current_location, found_condition,
 		       fundraising_goal_amount, fundraising_current_amount, fundraising_purpose,
 		       fundraising_deadline, fundraising_bank_details,
 		       status, status_reason, is_published, views_count,
-		       created_at, updated_at, closed_at
+		       created_at, updated_at, closed_at, ` + distanceColumn + ` AS distance_km
 		FROM pet_announcements
 		WHERE is_published = 1 AND status = 'active'
 	`
 
-	// Фильтры
-	params := []interface{}{}
-
 	// Фильтр по типу
 	if announcementType := r.URL.Query().Get("type"); announcementType != "" {
 		query += " AND type = ?"
@@ -85,9 +104,30 @@ func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
 		params = append(params, authorID)
 	}
 
-	query += " ORDER BY created_at DESC"
+	if geo != nil {
+		// Сначала грубо отсекаем по индексу (location_lat, location_lon), потом точное расстояние
+		box, boxArgs := geo.boxCondition("location_lat", "location_lon")
+		query += " AND " + box
+		query += " AND " + distanceSQL("location_lat", "location_lon") + " <= ?"
+		params = append(params, boxArgs...)
+		params = append(params, geo.Lat, geo.Lat, geo.Lon, geo.RadiusKm)
+		// Ближайшие первыми, курсор по (distance_km, id)
+		if cursor != nil {
+			query += " AND (" + distanceSQL("location_lat", "location_lon") + ", id) > (?, ?)"
+			params = append(params, geo.Lat, geo.Lat, geo.Lon, cursor.DistanceKm, cursor.ID)
+		}
+		query += " ORDER BY distance_km ASC, id ASC LIMIT ?"
+		params = append(params, limit+1)
+	} else {
+		if cursor != nil {
+			query += " AND " + cursorCondition("created_at", "id", false)
+			params = append(params, cursor.CreatedAt, cursor.ID)
+		}
+		query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+		params = append(params, limit+1)
+	}
 
-	rows, err := database.DB.Query(query, params...)
+	rows, err := database.DB.Query(ConvertPlaceholders(query), params...)
 	if err != nil {
 		sendError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -100,14 +140,14 @@ func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
 		err := rows.Scan(
 			&a.ID, &a.PetID, &a.Type, &a.Title, &a.Description, &a.AuthorID,
 			&a.ContactPersonID, &a.ContactPersonName, &a.ContactPersonPhone,
-			&a.LocationCity, &a.LocationAddress, &a.LocationCoordinates,
+			&a.LocationCity, &a.LocationAddress, &a.LocationCoordinates, &a.LocationLat, &a.LocationLon,
 			&a.EventDate, &a.EventTime,
 			&a.LostLastSeenLocation, &a.LostDistinctiveFeatures, &a.LostRewardAmount,
 			&a.FoundCurrentLocation, &a.FoundCondition,
 			&a.FundraisingGoalAmount, &a.FundraisingCurrentAmount, &a.FundraisingPurpose,
 			&a.FundraisingDeadline, &a.FundraisingBankDetails,
 			&a.Status, &a.StatusReason, &a.IsPublished, &a.ViewsCount,
-			&a.CreatedAt, &a.UpdatedAt, &a.ClosedAt,
+			&a.CreatedAt, &a.UpdatedAt, &a.ClosedAt, &a.DistanceKm,
 		)
 		if err != nil {
 			sendError(w, err.Error(), http.StatusInternalServerError)
@@ -116,7 +156,18 @@ func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
 		announcements = append(announcements, a)
 	}
 
-	sendSuccess(w, announcements)
+	nextCursor := ""
+	if len(announcements) > limit {
+		announcements = announcements[:limit]
+		last := announcements[len(announcements)-1]
+		if geo != nil && last.DistanceKm != nil {
+			nextCursor = encodeDistanceCursor(*last.DistanceKm, last.ID)
+		} else {
+			nextCursor = encodeCursor(last.CreatedAt, last.ID)
+		}
+	}
+
+	sendPageResponse(w, announcements, nextCursor)
 }
 
 // handleGetAnnouncement - получить конкретное объявление со всеми данными
@@ -126,7 +177,7 @@ func handleGetAnnouncement(w http.ResponseWriter, _ *http.Request, id int) {
 	query := `
 		SELECT id, pet_id, type, title, description, author_id,
 		       contact_person_id, contact_person_name, contact_person_phone,
-		       location_city, location_address, location_coordinates,
+		       location_city, location_address, location_coordinates, location_lat, location_lon,
 		       event_date, event_time,
 		       lost_last_seen_location, lost_distinctive_features, lost_reward_amount,
 		       found_current_location, found_condition,
@@ -141,7 +192,7 @@ func handleGetAnnouncement(w http.ResponseWriter, _ *http.Request, id int) {
 	err := database.DB.QueryRow(query, id).Scan(
 		&a.ID, &a.PetID, &a.Type, &a.Title, &a.Description, &a.AuthorID,
 		&a.ContactPersonID, &a.ContactPersonName, &a.ContactPersonPhone,
-		&a.LocationCity, &a.LocationAddress, &a.LocationCoordinates,
+		&a.LocationCity, &a.LocationAddress, &a.LocationCoordinates, &a.LocationLat, &a.LocationLon,
 		&a.EventDate, &a.EventTime,
 		&a.LostLastSeenLocation, &a.LostDistinctiveFeatures, &a.LostRewardAmount,
 		&a.FoundCurrentLocation, &a.FoundCondition,
@@ -219,30 +270,38 @@ func handleCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
 		INSERT INTO pet_announcements (
 			pet_id, type, title, description, author_id,
 			contact_person_id, contact_person_name, contact_person_phone,
-			location_city, location_address, location_coordinates,
+			location_city, location_address, location_coordinates, location_lat, location_lon,
 			event_date, event_time,
 			lost_last_seen_location, lost_distinctive_features, lost_reward_amount,
 			found_current_location, found_condition,
 			fundraising_goal_amount, fundraising_purpose, fundraising_deadline, fundraising_bank_details
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
 	`
 
-	result, err := database.DB.Exec(query,
+	lat, lon := announcementCoordinates(&req)
+
+	var id int
+	err := database.DB.QueryRow(ConvertPlaceholders(query),
 		req.PetID, req.Type, req.Title, req.Description, userID,
 		req.ContactPersonID, req.ContactPersonName, req.ContactPersonPhone,
-		req.LocationCity, req.LocationAddress, req.LocationCoordinates,
+		req.LocationCity, req.LocationAddress, req.LocationCoordinates, lat, lon,
 		eventDate, req.EventTime,
 		req.LostLastSeenLocation, req.LostDistinctiveFeatures, req.LostRewardAmount,
 		req.FoundCurrentLocation, req.FoundCondition,
 		req.FundraisingGoalAmount, req.FundraisingPurpose, fundraisingDeadline, req.FundraisingBankDetails,
-	)
+	).Scan(&id)
 
 	if err != nil {
 		sendError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	id, _ := result.LastInsertId()
+	// Оповещаем подписчиков зон о потерянных и найденных животных
+	if req.Type == "lost" || req.Type == "found" {
+		go notifyAlertZones(database.DB, id, userID, req.Type, req.Title, req.LocationCity, lat, lon)
+	}
+
 	sendSuccess(w, map[string]interface{}{"id": id, "message": "Announcement created successfully"})
 }
 
@@ -274,14 +333,18 @@ func handleUpdateAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
 		UPDATE pet_announcements SET
 			title = ?, description = ?,
 			contact_person_id = ?, contact_person_name = ?, contact_person_phone = ?,
-			location_city = ?, location_address = ?, location_coordinates = ?
+			location_city = ?, location_address = ?, location_coordinates = ?,
+			location_lat = ?, location_lon = ?
 		WHERE id = ?
 	`
 
+	lat, lon := announcementCoordinates(&req)
+
 	_, err = database.DB.Exec(query,
 		req.Title, req.Description,
 		req.ContactPersonID, req.ContactPersonName, req.ContactPersonPhone,
 		req.LocationCity, req.LocationAddress, req.LocationCoordinates,
+		lat, lon,
 		id,
 	)
 
@@ -293,6 +356,22 @@ func handleUpdateAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
 	sendSuccess(w, map[string]string{"message": "Announcement updated successfully"})
 }
 
+// announcementCoordinates возвращает числовые координаты объявления:
+// явно переданные location_lat/location_lon или разобранные из location_coordinates
+func announcementCoordinates(req *models.CreateAnnouncementRequest) (lat, lon *float64) {
+	if req.LocationLat != nil && req.LocationLon != nil &&
+		*req.LocationLat >= -90 && *req.LocationLat <= 90 &&
+		*req.LocationLon >= -180 && *req.LocationLon <= 180 {
+		return req.LocationLat, req.LocationLon
+	}
+
+	if req.LocationCoordinates != nil {
+		return parseCoordinates(*req.LocationCoordinates)
+	}
+
+	return nil, nil
+}
+
 // handleDeleteAnnouncement - удалить объявление
 func handleDeleteAnnouncement(w http.ResponseWriter, r *http.Request, id int) {
 	userID := r.Context().Value("userID").(int)
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"backend/middleware"
 	"backend/models"
 	"bytes"
 	"database"
@@ -438,6 +439,20 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Заблокированный или приостановленный аккаунт не получает cookie
+	ban, err := middleware.ActiveBan(authResp.Data.User.ID)
+	if err != nil {
+		log.Printf("❌ LoginHandler: Failed to check ban for user %d: %v", authResp.Data.User.ID, err)
+		sendError(w, "Failed to check account status", http.StatusServiceUnavailable)
+		return
+	}
+	if ban != nil {
+		log.Printf("🚫 LoginHandler: User %s is banned (%s)", authResp.Data.User.Email, ban.BanType)
+		CreateUserLog(database.DB, authResp.Data.User.ID, "login_blocked", "Попытка входа в заблокированный аккаунт", r.RemoteAddr, r.Header.Get("User-Agent"))
+		middleware.WriteBanned(w, ban)
+		return
+	}
+
 	// Устанавливаем cookie с токеном от Auth Service
 	http.SetCookie(w, &http.Cookie{
 		Name:     "auth_token",
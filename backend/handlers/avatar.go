@@ -1,9 +1,9 @@
 package handlers
 
 import (
+	"backend/imageproc"
 	"database"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -59,9 +59,28 @@ func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	releaseQuota, ok := reserveStorageQuota(w, database.DB, userID, header.Size)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	// Поворот по EXIF, удаление EXIF/GPS и варианты thumb/medium/large
+	processed, err := imageproc.Process(file)
+	if err != nil && err != imageproc.ErrUnsupported {
+		logSystemEvent("warning", "profile", "upload_avatar", fmt.Sprintf("Не удалось обработать изображение: %v", err), &userID, ipAddress)
+		sendErrorResponse(w, "Не удалось обработать изображение", http.StatusBadRequest)
+		return
+	}
+	file.Seek(0, 0)
+
 	// Генерируем уникальное имя файла
+	baseName := uuid.New().String()
 	ext := filepath.Ext(header.Filename)
-	fileName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	if processed != nil {
+		ext = processed.OriginalExt
+	}
+	fileName := baseName + ext
 
 	// Определяем базовый путь для uploads
 	// В Docker: /app/uploads
@@ -82,15 +101,7 @@ func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Сохраняем файл
 	filePath := filepath.Join(uploadDir, fileName)
-	dst, err := os.Create(filePath)
-	if err != nil {
-		logSystemEvent("error", "profile", "upload_avatar", fmt.Sprintf("Ошибка создания файла: %v", err), &userID, ipAddress)
-		sendErrorResponse(w, "Ошибка создания файла", http.StatusInternalServerError)
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
+	if err := saveUploadedImage(uploadDir, baseName, filePath, file, processed); err != nil {
 		logSystemEvent("error", "profile", "upload_avatar", fmt.Sprintf("Ошибка сохранения файла: %v", err), &userID, ipAddress)
 		sendErrorResponse(w, "Ошибка сохранения файла", http.StatusInternalServerError)
 		return
@@ -103,15 +114,20 @@ func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Формируем URL для доступа к файлу
-	avatarURL := fmt.Sprintf("/uploads/users/%d/avatars/%s", userID, fileName)
+	// Переносим в хранилище (S3, если настроено) и формируем URL для доступа к файлу
+	avatarURL, variants, err := publishUploadedImage(uploadDir, fmt.Sprintf("users/%d/avatars", userID), baseName, fileName, processed)
+	if err != nil {
+		logSystemEvent("error", "profile", "upload_avatar", fmt.Sprintf("Ошибка загрузки в хранилище: %v", err), &userID, ipAddress)
+		sendErrorResponse(w, "Ошибка сохранения файла", http.StatusInternalServerError)
+		return
+	}
 
 	// Логируем детали для отладки
 	logSystemEvent("info", "profile", "upload_avatar", fmt.Sprintf("Файл сохранён: %s → URL: %s", filePath, avatarURL), &userID, ipAddress)
 
-	// Обновляем аватар в базе данных
-	query := ConvertPlaceholders(`UPDATE users SET avatar = ? WHERE id = ?`)
-	_, err = database.DB.Exec(query, avatarURL, userID)
+	// Обновляем аватар в базе данных; размер учитывается в квоте хранилища
+	query := ConvertPlaceholders(`UPDATE users SET avatar = ?, avatar_size = ? WHERE id = ?`)
+	_, err = database.DB.Exec(query, avatarURL, storedImageSize(processed, header.Size), userID)
 	if err != nil {
 		logSystemEvent("error", "profile", "upload_avatar", fmt.Sprintf("Ошибка обновления БД: %v", err), &userID, ipAddress)
 		sendErrorResponse(w, "Ошибка обновления базы данных", http.StatusInternalServerError)
@@ -122,6 +138,7 @@ func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
 
 	sendSuccessResponse(w, map[string]interface{}{
 		"avatar_url": avatarURL,
+		"variants":   variants,
 		"message":    "Аватар успешно загружен",
 	})
 }
@@ -173,9 +190,28 @@ func UploadCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	releaseQuota, ok := reserveStorageQuota(w, database.DB, userID, header.Size)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	// Поворот по EXIF, удаление EXIF/GPS и варианты thumb/medium/large
+	processed, err := imageproc.Process(file)
+	if err != nil && err != imageproc.ErrUnsupported {
+		logSystemEvent("warning", "profile", "upload_cover", fmt.Sprintf("Не удалось обработать изображение: %v", err), &userID, ipAddress)
+		sendErrorResponse(w, "Не удалось обработать изображение", http.StatusBadRequest)
+		return
+	}
+	file.Seek(0, 0)
+
 	// Генерируем уникальное имя файла
+	baseName := uuid.New().String()
 	ext := filepath.Ext(header.Filename)
-	fileName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	if processed != nil {
+		ext = processed.OriginalExt
+	}
+	fileName := baseName + ext
 
 	// Определяем базовый путь для uploads
 	baseUploadPath := os.Getenv("UPLOAD_PATH")
@@ -193,26 +229,23 @@ func UploadCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Сохраняем файл
 	filePath := filepath.Join(uploadDir, fileName)
-	dst, err := os.Create(filePath)
-	if err != nil {
-		logSystemEvent("error", "profile", "upload_cover", fmt.Sprintf("Ошибка создания файла: %v", err), &userID, ipAddress)
-		sendErrorResponse(w, "Ошибка создания файла", http.StatusInternalServerError)
+	if err := saveUploadedImage(uploadDir, baseName, filePath, file, processed); err != nil {
+		logSystemEvent("error", "profile", "upload_cover", fmt.Sprintf("Ошибка сохранения файла: %v", err), &userID, ipAddress)
+		sendErrorResponse(w, "Ошибка сохранения файла", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		logSystemEvent("error", "profile", "upload_cover", fmt.Sprintf("Ошибка сохранения файла: %v", err), &userID, ipAddress)
+	// Переносим в хранилище (S3, если настроено) и формируем URL для доступа к файлу
+	coverURL, variants, err := publishUploadedImage(uploadDir, fmt.Sprintf("users/%d/covers", userID), baseName, fileName, processed)
+	if err != nil {
+		logSystemEvent("error", "profile", "upload_cover", fmt.Sprintf("Ошибка загрузки в хранилище: %v", err), &userID, ipAddress)
 		sendErrorResponse(w, "Ошибка сохранения файла", http.StatusInternalServerError)
 		return
 	}
 
-	// Формируем URL для доступа к файлу
-	coverURL := fmt.Sprintf("/uploads/users/%d/covers/%s", userID, fileName)
-
-	// Обновляем обложку в базе данных
-	query := ConvertPlaceholders(`UPDATE users SET cover_photo = ? WHERE id = ?`)
-	_, err = database.DB.Exec(query, coverURL, userID)
+	// Обновляем обложку в базе данных; размер учитывается в квоте хранилища
+	query := ConvertPlaceholders(`UPDATE users SET cover_photo = ?, cover_photo_size = ? WHERE id = ?`)
+	_, err = database.DB.Exec(query, coverURL, storedImageSize(processed, header.Size), userID)
 	if err != nil {
 		logSystemEvent("error", "profile", "upload_cover", fmt.Sprintf("Ошибка обновления БД: %v", err), &userID, ipAddress)
 		sendErrorResponse(w, "Ошибка обновления базы данных", http.StatusInternalServerError)
@@ -223,6 +256,7 @@ func UploadCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 
 	sendSuccessResponse(w, map[string]interface{}{
 		"cover_url": coverURL,
+		"variants":  variants,
 		"message":   "Обложка успешно загружена",
 	})
 }
@@ -244,7 +278,7 @@ func DeleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
 	ipAddress := r.RemoteAddr
 
 	// Обновляем аватар в базе данных (устанавливаем NULL)
-	query := ConvertPlaceholders(`UPDATE users SET avatar = NULL WHERE id = ?`)
+	query := ConvertPlaceholders(`UPDATE users SET avatar = NULL, avatar_size = 0 WHERE id = ?`)
 	_, err := database.DB.Exec(query, userID)
 	if err != nil {
 		logSystemEvent("error", "profile", "delete_avatar", fmt.Sprintf("Ошибка обновления БД: %v", err), &userID, ipAddress)
@@ -276,7 +310,7 @@ func DeleteCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 	ipAddress := r.RemoteAddr
 
 	// Обновляем обложку в базе данных (устанавливаем NULL)
-	query := ConvertPlaceholders(`UPDATE users SET cover_photo = NULL WHERE id = ?`)
+	query := ConvertPlaceholders(`UPDATE users SET cover_photo = NULL, cover_photo_size = 0 WHERE id = ?`)
 	_, err := database.DB.Exec(query, userID)
 	if err != nil {
 		logSystemEvent("error", "profile", "delete_cover", fmt.Sprintf("Ошибка обновления БД: %v", err), &userID, ipAddress)
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BanUserHandler блокирует аккаунт пользователя (маршрут закрыт правом ban_users).
+// duration_hours = 0 - бессрочный бан
+func BanUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		createUserBan(w, r, db, models.BanTypeBan)
+	}
+}
+
+// SuspendUserHandler временно приостанавливает аккаунт (маршрут закрыт правом ban_users)
+func SuspendUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		createUserBan(w, r, db, models.BanTypeSuspension)
+	}
+}
+
+// createUserBan создаёт блокировку указанного типа
+func createUserBan(w http.ResponseWriter, r *http.Request, db *sql.DB, banType string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currentUserID := r.Context().Value("userID").(int)
+
+	var req models.BanUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.UserID == 0 || req.Reason == "" {
+		sendErrorResponse(w, "Укажите пользователя и причину", http.StatusBadRequest)
+		return
+	}
+
+	if req.DurationHours < 0 {
+		sendErrorResponse(w, "Длительность не может быть отрицательной", http.StatusBadRequest)
+		return
+	}
+
+	if banType == models.BanTypeSuspension && req.DurationHours == 0 {
+		sendErrorResponse(w, "Для приостановки укажите длительность", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == currentUserID {
+		sendErrorResponse(w, "Нельзя заблокировать самого себя", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := userExists(db, req.UserID)
+	if err != nil || !exists {
+		sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+
+	if hasRole(db, req.UserID, models.RoleSuperAdmin) {
+		sendErrorResponse(w, "Нельзя заблокировать суперадминистратора", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	if req.DurationHours > 0 {
+		t := now.Add(time.Duration(req.DurationHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	var banID int
+	err = db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO user_bans (user_id, ban_type, reason, banned_by, starts_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), req.UserID, banType, req.Reason, currentUserID, now, expiresAt).Scan(&banID)
+	if err != nil {
+		log.Printf("❌ Error creating ban: %v", err)
+		sendErrorResponse(w, "Ошибка блокировки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🚫 User %d got %s by moderator %d until %v", req.UserID, banType, currentUserID, expiresAt)
+
+	var adminEmail, userName string
+	db.QueryRow(ConvertPlaceholders("SELECT email FROM users WHERE id = ?"), currentUserID).Scan(&adminEmail)
+	db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), req.UserID).Scan(&userName)
+
+	until := "бессрочно"
+	if expiresAt != nil {
+		until = "до " + expiresAt.Format("02.01.2006 15:04")
+	}
+
+	actionType := models.ActionBanUser
+	userLogAction := "account_banned"
+	userLogDetails := "Аккаунт заблокирован " + until + ". Причина: " + req.Reason
+	if banType == models.BanTypeSuspension {
+		actionType = models.ActionSuspendUser
+		userLogAction = "account_suspended"
+		userLogDetails = "Аккаунт приостановлен " + until + ". Причина: " + req.Reason
+	}
+
+	CreateAdminLog(
+		currentUserID,
+		adminEmail,
+		actionType,
+		models.TargetUser,
+		req.UserID,
+		userName,
+		fmt.Sprintf("Reason: %s, Duration: %dh", req.Reason, req.DurationHours),
+		r.RemoteAddr,
+		r.Header.Get("User-Agent"),
+	)
+
+	// Запись в истории самого пользователя (без IP модератора)
+	CreateUserLog(db, req.UserID, userLogAction, userLogDetails, "", "")
+
+	ban := models.UserBan{
+		ID:        banID,
+		UserID:    req.UserID,
+		BanType:   banType,
+		Reason:    req.Reason,
+		BannedBy:  &currentUserID,
+		StartsAt:  now,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+
+	sendSuccessResponse(w, ban)
+}
+
+// UnbanUserHandler снимает все действующие блокировки пользователя (маршрут закрыт правом ban_users)
+func UnbanUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		currentUserID := r.Context().Value("userID").(int)
+
+		var req models.UnbanUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		if req.UserID == 0 {
+			sendErrorResponse(w, "Укажите пользователя", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		result, err := db.Exec(ConvertPlaceholders(`
+			UPDATE user_bans SET lifted_at = ?, lifted_by = ?
+			WHERE user_id = ? AND lifted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > ?)
+		`), now, currentUserID, req.UserID, now)
+		if err != nil {
+			log.Printf("❌ Error lifting ban: %v", err)
+			sendErrorResponse(w, "Ошибка снятия блокировки: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			sendErrorResponse(w, "У пользователя нет действующих блокировок", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("✅ User %d unbanned by moderator %d", req.UserID, currentUserID)
+
+		var adminEmail, userName string
+		db.QueryRow(ConvertPlaceholders("SELECT email FROM users WHERE id = ?"), currentUserID).Scan(&adminEmail)
+		db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), req.UserID).Scan(&userName)
+
+		details := "Ban lifted"
+		if req.Reason != "" {
+			details += ", Reason: " + req.Reason
+		}
+		CreateAdminLog(
+			currentUserID,
+			adminEmail,
+			models.ActionUnbanUser,
+			models.TargetUser,
+			req.UserID,
+			userName,
+			details,
+			r.RemoteAddr,
+			r.Header.Get("User-Agent"),
+		)
+
+		CreateUserLog(db, req.UserID, "account_unbanned", "Блокировка аккаунта снята", "", "")
+
+		sendSuccessResponse(w, map[string]string{"message": "Блокировка снята"})
+	}
+}
+
+// GetUserBansHandler возвращает историю блокировок пользователя (маршрут закрыт правом ban_users)
+// GET /api/moderation/users/bans/{id}
+func GetUserBansHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/moderation/users/bans/"))
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(ConvertPlaceholders(`
+			SELECT id, user_id, ban_type, reason, banned_by, starts_at, expires_at,
+			       lifted_at, lifted_by, created_at
+			FROM user_bans
+			WHERE user_id = ?
+			ORDER BY created_at DESC
+		`), userID)
+		if err != nil {
+			log.Printf("❌ Error fetching bans: %v", err)
+			sendErrorResponse(w, "Ошибка получения блокировок: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		bans := []models.UserBan{}
+		for rows.Next() {
+			var ban models.UserBan
+			var expiresAt, liftedAt sql.NullTime
+			var bannedBy, liftedBy sql.NullInt64
+
+			if err := rows.Scan(
+				&ban.ID, &ban.UserID, &ban.BanType, &ban.Reason, &bannedBy, &ban.StartsAt, &expiresAt,
+				&liftedAt, &liftedBy, &ban.CreatedAt,
+			); err != nil {
+				log.Printf("❌ Error scanning ban: %v", err)
+				sendErrorResponse(w, "Ошибка чтения блокировок", http.StatusInternalServerError)
+				return
+			}
+
+			if expiresAt.Valid {
+				ban.ExpiresAt = &expiresAt.Time
+			}
+			if liftedAt.Valid {
+				ban.LiftedAt = &liftedAt.Time
+			}
+			if bannedBy.Valid {
+				id := int(bannedBy.Int64)
+				ban.BannedBy = &id
+			}
+			if liftedBy.Valid {
+				id := int(liftedBy.Int64)
+				ban.LiftedBy = &id
+			}
+
+			bans = append(bans, ban)
+		}
+
+		sendSuccessResponse(w, bans)
+	}
+}
@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Индикатор набора текста: клиент повторяет typing_start, пока пользователь печатает.
+// Если typing_stop потерялся, собеседник скрывает индикатор через typingExpiresIn.
+const (
+	typingThrottle  = 3 * time.Second
+	typingExpiresIn = 6 // секунд
+)
+
+// showsOnlineStatus - разрешил ли пользователь показывать свою активность
+// (онлайн, набор текста, доставку и прочтение сообщений). show_online: yes/no.
+func showsOnlineStatus(db *sql.DB, userID int) bool {
+	var showOnline sql.NullString
+	err := db.QueryRow(ConvertPlaceholders("SELECT show_online FROM users WHERE id = ?"), userID).Scan(&showOnline)
+	if err != nil {
+		return false
+	}
+	return showOnline.String != "no"
+}
+
+// chatParticipantIDs возвращает участников чата (личного или группового)
+func chatParticipantIDs(db *sql.DB, chatID int) ([]int, error) {
+	var chatType string
+	var user1ID, user2ID sql.NullInt64
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT type, user1_id, user2_id FROM chats WHERE id = ?
+	`), chatID).Scan(&chatType, &user1ID, &user2ID)
+	if err != nil {
+		return nil, err
+	}
+	if chatType == models.ChatTypeGroup {
+		return chatMemberIDs(db, chatID)
+	}
+	return []int{int(user1ID.Int64), int(user2ID.Int64)}, nil
+}
+
+// countUnreadMessages - непрочитанные сообщения пользователя во всех чатах:
+// в личных по is_read, в групповых - после курсора прочтения
+func countUnreadMessages(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT
+			(SELECT COUNT(*) FROM messages WHERE receiver_id = ? AND is_read = FALSE) +
+			(SELECT COUNT(*)
+			 FROM messages m
+			 JOIN chat_members cm ON cm.chat_id = m.chat_id AND cm.user_id = ?
+			 LEFT JOIN chat_read_cursors rc ON rc.chat_id = m.chat_id AND rc.user_id = cm.user_id
+			 WHERE m.receiver_id IS NULL AND m.sender_id <> cm.user_id
+			   AND m.id > COALESCE(rc.last_read_message_id, 0))
+	`), userID, userID).Scan(&count)
+	return count, err
+}
+
+// setMessageStatus вычисляет статус сообщения по отметкам доставки и прочтения
+func setMessageStatus(msg *models.Message) {
+	switch {
+	case msg.IsRead:
+		msg.Status = models.MessageStatusRead
+	case msg.DeliveredAt != nil:
+		msg.Status = models.MessageStatusDelivered
+	default:
+		msg.Status = models.MessageStatusSent
+	}
+}
+
+// hideReceipts скрывает от отправителя доставку и прочтение, если получатель
+// не показывает свою активность
+func hideReceipts(msg *models.Message) {
+	msg.IsRead = false
+	msg.ReadAt = nil
+	msg.DeliveredAt = nil
+	msg.Status = models.MessageStatusSent
+}
+
+// markMessagesDelivered отмечает входящие сообщения пользователя доставленными
+// (chatID = 0 - во всех чатах, upToID = 0 - все сообщения) и сообщает отправителям.
+// В личных чатах заполняется delivered_at, в группах сдвигается курсор доставки.
+func markMessagesDelivered(db *sql.DB, receiverID, chatID, upToID int) {
+	deliveredAt := time.Now()
+	markDirectMessagesDelivered(db, receiverID, chatID, upToID, deliveredAt)
+	markGroupMessagesDelivered(db, receiverID, chatID, upToID, deliveredAt)
+}
+
+// markDirectMessagesDelivered отмечает доставленными сообщения личных чатов
+func markDirectMessagesDelivered(db *sql.DB, receiverID, chatID, upToID int, deliveredAt time.Time) {
+	query := `
+		UPDATE messages
+		SET delivered_at = ?
+		WHERE receiver_id = ? AND delivered_at IS NULL
+	`
+	args := []interface{}{deliveredAt, receiverID}
+	if chatID > 0 {
+		query += ` AND chat_id = ?`
+		args = append(args, chatID)
+	}
+	if upToID > 0 {
+		query += ` AND id <= ?`
+		args = append(args, upToID)
+	}
+	query += ` RETURNING id, chat_id, sender_id`
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to mark messages as delivered: %v", err)
+		return
+	}
+
+	// chat_id -> отправитель и последнее доставленное сообщение
+	type delivery struct{ senderID, upToID int }
+	deliveries := map[int]*delivery{}
+	for rows.Next() {
+		var id, msgChatID, senderID int
+		if rows.Scan(&id, &msgChatID, &senderID) != nil {
+			continue
+		}
+		d, ok := deliveries[msgChatID]
+		if !ok {
+			d = &delivery{senderID: senderID}
+			deliveries[msgChatID] = d
+		}
+		if id > d.upToID {
+			d.upToID = id
+		}
+	}
+	rows.Close()
+
+	if len(deliveries) == 0 || !showsOnlineStatus(db, receiverID) {
+		return
+	}
+	for msgChatID, d := range deliveries {
+		SendToUser(d.senderID, "messages_delivered", map[string]interface{}{
+			"chat_id":          msgChatID,
+			"user_id":          receiverID,
+			"up_to_message_id": d.upToID,
+			"delivered_at":     deliveredAt,
+		})
+	}
+}
+
+// markGroupMessagesDelivered сдвигает курсоры доставки пользователя в группах
+// до upToID (0 или больше последнего сообщения - до последнего сообщения)
+// и сообщает авторам ставших доставленными сообщений
+func markGroupMessagesDelivered(db *sql.DB, receiverID, chatID, upToID int, deliveredAt time.Time) {
+	query := `
+		SELECT c.id, c.last_message_id, COALESCE(rc.last_delivered_message_id, 0)
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id AND cm.user_id = ?
+		LEFT JOIN chat_read_cursors rc ON rc.chat_id = c.id AND rc.user_id = cm.user_id
+		WHERE c.type = 'group' AND c.last_message_id > COALESCE(rc.last_delivered_message_id, 0)
+	`
+	args := []interface{}{receiverID}
+	if chatID > 0 {
+		query += ` AND c.id = ?`
+		args = append(args, chatID)
+	}
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load group delivery cursors: %v", err)
+		return
+	}
+	type groupDelivery struct{ chatID, previousID, upToID int }
+	var pending []groupDelivery
+	for rows.Next() {
+		var d groupDelivery
+		if rows.Scan(&d.chatID, &d.upToID, &d.previousID) != nil {
+			continue
+		}
+		if upToID > 0 && upToID < d.upToID {
+			d.upToID = upToID
+		}
+		if d.upToID > d.previousID {
+			pending = append(pending, d)
+		}
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return
+	}
+
+	showsStatus := showsOnlineStatus(db, receiverID)
+	for _, d := range pending {
+		_, err := db.Exec(ConvertPlaceholders(`
+			INSERT INTO chat_read_cursors (chat_id, user_id, last_read_message_id, last_delivered_message_id, delivered_at)
+			VALUES (?, ?, 0, ?, ?)
+			ON CONFLICT (chat_id, user_id) DO UPDATE SET
+				last_delivered_message_id = GREATEST(chat_read_cursors.last_delivered_message_id, excluded.last_delivered_message_id),
+				delivered_at = excluded.delivered_at
+		`), d.chatID, receiverID, d.upToID, deliveredAt)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to update delivery cursor: %v", err)
+			continue
+		}
+		if !showsStatus {
+			continue
+		}
+
+		senders, err := db.Query(ConvertPlaceholders(`
+			SELECT DISTINCT sender_id FROM messages
+			WHERE chat_id = ? AND id > ? AND id <= ? AND sender_id <> ?
+		`), d.chatID, d.previousID, d.upToID, receiverID)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to load message senders: %v", err)
+			continue
+		}
+		for senders.Next() {
+			var senderID int
+			if senders.Scan(&senderID) != nil {
+				continue
+			}
+			SendToUser(senderID, "messages_delivered", map[string]interface{}{
+				"chat_id":          d.chatID,
+				"user_id":          receiverID,
+				"up_to_message_id": d.upToID,
+				"delivered_at":     deliveredAt,
+			})
+		}
+		senders.Close()
+	}
+}
+
+// markMessagesAsRead отмечает входящие сообщения чата прочитанными до upToID
+// включительно (0 - все), сдвигает курсор прочтения и сообщает об этом
+// отправителям и остальным устройствам пользователя
+func markMessagesAsRead(db *sql.DB, chatID, userID, upToID int) {
+	info, err := getChatInfo(db, chatID)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load chat %d: %v", chatID, err)
+		return
+	}
+	if info.Type == models.ChatTypeGroup {
+		markGroupMessagesAsRead(db, chatID, userID, upToID, info.LastMessageID)
+		return
+	}
+
+	readAt := time.Now()
+	query := `
+		UPDATE messages
+		SET is_read = TRUE, read_at = ?, delivered_at = COALESCE(delivered_at, ?)
+		WHERE chat_id = ? AND receiver_id = ? AND is_read = FALSE
+	`
+	args := []interface{}{readAt, readAt, chatID, userID}
+	if upToID > 0 {
+		query += ` AND id <= ?`
+		args = append(args, upToID)
+	}
+	query += ` RETURNING id, sender_id`
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to mark messages as read: %v", err)
+		return
+	}
+
+	lastReadID := 0
+	senders := map[int]bool{}
+	for rows.Next() {
+		var id, senderID int
+		if rows.Scan(&id, &senderID) != nil {
+			continue
+		}
+		senders[senderID] = true
+		if id > lastReadID {
+			lastReadID = id
+		}
+	}
+	rows.Close()
+
+	if lastReadID == 0 {
+		return
+	}
+
+	lastReadID = advanceReadCursor(db, chatID, userID, lastReadID, readAt)
+	notifyChatRead(db, chatID, userID, lastReadID, readAt, senders)
+}
+
+// markGroupMessagesAsRead сдвигает курсор прочтения в группе до upToID
+// (0 или больше последнего сообщения - до последнего сообщения)
+func markGroupMessagesAsRead(db *sql.DB, chatID, userID, upToID, lastMessageID int) {
+	if upToID <= 0 || upToID > lastMessageID {
+		upToID = lastMessageID
+	}
+	if upToID == 0 {
+		return
+	}
+
+	var previousID int
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT last_read_message_id FROM chat_read_cursors WHERE chat_id = ? AND user_id = ?
+	`), chatID, userID).Scan(&previousID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("⚠️ Warning: Failed to load read cursor: %v", err)
+		return
+	}
+	if upToID <= previousID {
+		return
+	}
+
+	// Уведомляем только авторов сообщений, которые стали прочитанными
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT DISTINCT sender_id FROM messages
+		WHERE chat_id = ? AND id > ? AND id <= ? AND sender_id <> ?
+	`), chatID, previousID, upToID, userID)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load message senders: %v", err)
+		return
+	}
+	senders := map[int]bool{}
+	for rows.Next() {
+		var senderID int
+		if rows.Scan(&senderID) == nil {
+			senders[senderID] = true
+		}
+	}
+	rows.Close()
+
+	readAt := time.Now()
+	lastReadID := advanceReadCursor(db, chatID, userID, upToID, readAt)
+	notifyChatRead(db, chatID, userID, lastReadID, readAt, senders)
+}
+
+// advanceReadCursor сдвигает курсор прочтения и возвращает его новое значение.
+// Курсор только растёт: прочтение старой страницы не откатывает его назад.
+// Прочитанные сообщения считаются доставленными.
+func advanceReadCursor(db *sql.DB, chatID, userID, messageID int, readAt time.Time) int {
+	err := db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO chat_read_cursors (chat_id, user_id, last_read_message_id, read_at, last_delivered_message_id, delivered_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, user_id) DO UPDATE SET
+			last_read_message_id = GREATEST(chat_read_cursors.last_read_message_id, excluded.last_read_message_id),
+			read_at = excluded.read_at,
+			last_delivered_message_id = GREATEST(chat_read_cursors.last_delivered_message_id, excluded.last_delivered_message_id),
+			delivered_at = COALESCE(chat_read_cursors.delivered_at, excluded.delivered_at)
+		RETURNING last_read_message_id
+	`), chatID, userID, messageID, readAt, messageID, readAt).Scan(&messageID)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to update read cursor: %v", err)
+	}
+	return messageID
+}
+
+// notifyChatRead сообщает отправителям о прочтении (если читатель показывает
+// активность), а остальным устройствам читателя - о новом курсоре
+func notifyChatRead(db *sql.DB, chatID, userID, lastReadID int, readAt time.Time, senders map[int]bool) {
+	if showsOnlineStatus(db, userID) {
+		for senderID := range senders {
+			NotifyMessagesRead(senderID, chatID, userID, lastReadID, readAt)
+		}
+	}
+	SendToUser(userID, "chat_read", map[string]interface{}{
+		"chat_id":              chatID,
+		"last_read_message_id": lastReadID,
+	})
+	NotifyUnreadCount(userID)
+}
+
+// applyGroupReadStatus отмечает свои сообщения группы прочитанными (доставленными),
+// если их просмотрел (получил) хотя бы один участник (по видимым курсорам)
+func applyGroupReadStatus(messages []models.Message, cursors []models.ChatReadCursor, userID int) {
+	maxRead, maxDelivered := 0, 0
+	for _, cursor := range cursors {
+		if cursor.UserID == userID {
+			continue
+		}
+		if cursor.LastReadMessageID > maxRead {
+			maxRead = cursor.LastReadMessageID
+		}
+		if cursor.LastDeliveredMessageID > maxDelivered {
+			maxDelivered = cursor.LastDeliveredMessageID
+		}
+	}
+	for i := range messages {
+		if messages[i].SenderID != userID || messages[i].ReceiverID != 0 {
+			continue
+		}
+		switch {
+		case messages[i].ID <= maxRead:
+			messages[i].Status = models.MessageStatusRead
+		case messages[i].ID <= maxDelivered:
+			messages[i].Status = models.MessageStatusDelivered
+		}
+	}
+}
+
+// getChatReadCursors возвращает курсоры прочтения и доставки участников чатов
+// пользователя (chat_id -> курсоры, chatID = 0 - все чаты). Курсоры собеседников,
+// скрывающих активность, не попадают в результат.
+func getChatReadCursors(db *sql.DB, userID, chatID int) (map[int][]models.ChatReadCursor, error) {
+	query := `
+		SELECT rc.chat_id, rc.user_id, rc.last_read_message_id, rc.read_at, rc.last_delivered_message_id
+		FROM chat_read_cursors rc
+		JOIN chats c ON c.id = rc.chat_id
+		JOIN users u ON u.id = rc.user_id
+		WHERE (c.user1_id = ? OR c.user2_id = ?
+		       OR EXISTS (SELECT 1 FROM chat_members cm WHERE cm.chat_id = c.id AND cm.user_id = ?))
+		  AND (rc.user_id = ? OR COALESCE(u.show_online, 'yes') <> 'no')
+	`
+	args := []interface{}{userID, userID, userID, userID}
+	if chatID > 0 {
+		query += ` AND rc.chat_id = ?`
+		args = append(args, chatID)
+	}
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursors := map[int][]models.ChatReadCursor{}
+	for rows.Next() {
+		var chatID int
+		var cursor models.ChatReadCursor
+		if err := rows.Scan(&chatID, &cursor.UserID, &cursor.LastReadMessageID, &cursor.ReadAt, &cursor.LastDeliveredMessageID); err != nil {
+			return nil, err
+		}
+		cursors[chatID] = append(cursors[chatID], cursor)
+	}
+	return cursors, rows.Err()
+}
+
+// ChatReadHandler - курсоры прочтения чата (GET) и отметка прочтения (POST)
+// /api/chats/{id}/read, тело POST: {"message_id": 123} (без message_id - весь чат)
+func ChatReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 4 {
+			sendErrorResponse(w, "Invalid chat ID", http.StatusBadRequest)
+			return
+		}
+		chatID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			sendErrorResponse(w, "Invalid chat ID", http.StatusBadRequest)
+			return
+		}
+		if !isUserInChat(db, chatID, userID) {
+			sendErrorResponse(w, "Access denied", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			cursors, err := getChatReadCursors(db, userID, chatID)
+			if err != nil {
+				log.Printf("❌ Error fetching read cursors: %v", err)
+				sendErrorResponse(w, "Failed to fetch read cursors", http.StatusInternalServerError)
+				return
+			}
+			chatCursors := cursors[chatID]
+			if chatCursors == nil {
+				chatCursors = []models.ChatReadCursor{}
+			}
+			sendSuccessResponse(w, chatCursors)
+
+		case http.MethodPost:
+			var req struct {
+				MessageID int `json:"message_id"`
+			}
+			if r.ContentLength > 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+					return
+				}
+			}
+			markMessagesAsRead(db, chatID, userID, req.MessageID)
+			sendSuccessResponse(w, map[string]int{"chat_id": chatID})
+
+		default:
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
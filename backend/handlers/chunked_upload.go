@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"backend/models"
+	"backend/storage"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -17,7 +23,7 @@ import (
 const (
 	ChunkSize     = 5 * 1024 * 1024 // 5MB chunks
 	TempUploadDir = "../../uploads/temp"
-	MaxChunkAge   = 24 * time.Hour // Cleanup old chunks after 24h
+	MaxChunkAge   = 24 * time.Hour // Session expires 24h after the last received chunk
 )
 
 type ChunkedUploadHandler struct {
@@ -30,6 +36,60 @@ func NewChunkedUploadHandler(db *sql.DB) *ChunkedUploadHandler {
 	return &ChunkedUploadHandler{DB: db}
 }
 
+// uploadSessionColumns - columns in scanUploadSession order
+const uploadSessionColumns = `id, user_id, file_name, file_size, mime_type, media_type, chunk_size, total_chunks,
+	received_chunks, COALESCE(sha256, ''), status, media_id, expires_at, created_at`
+
+// scanUploadSession reads an upload_sessions row and decodes the received-chunk bitmap
+func scanUploadSession(row interface {
+	Scan(dest ...interface{}) error
+}) (models.UploadSession, error) {
+	var s models.UploadSession
+	var bitmap []byte
+	var mediaID sql.NullInt64
+	err := row.Scan(&s.ID, &s.UserID, &s.FileName, &s.FileSize, &s.MimeType, &s.MediaType, &s.ChunkSize,
+		&s.TotalChunks, &bitmap, &s.SHA256, &s.Status, &mediaID, &s.ExpiresAt, &s.CreatedAt)
+	if err != nil {
+		return s, err
+	}
+
+	s.MediaID = nullIntPtr(mediaID)
+	s.MissingChunks = []int{}
+	for i := 0; i < s.TotalChunks; i++ {
+		// set_bit() in PostgreSQL numbers bits from the least significant one
+		if i/8 < len(bitmap) && bitmap[i/8]&(1<<(i%8)) != 0 {
+			s.ReceivedChunks++
+		} else {
+			s.MissingChunks = append(s.MissingChunks, i)
+		}
+	}
+	return s, nil
+}
+
+// loadUploadSession loads a session and checks that it belongs to userID and is not expired
+func (h *ChunkedUploadHandler) loadUploadSession(w http.ResponseWriter, uploadID string, userID int) (models.UploadSession, bool) {
+	session, err := scanUploadSession(h.DB.QueryRow(ConvertPlaceholders(
+		`SELECT `+uploadSessionColumns+` FROM upload_sessions WHERE id = ?`), uploadID))
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Upload session not found", http.StatusNotFound)
+		return session, false
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch upload session", http.StatusInternalServerError)
+		return session, false
+	}
+	if session.UserID != userID {
+		sendErrorResponse(w, "Forbidden", http.StatusForbidden)
+		return session, false
+	}
+	expired := session.Status == models.UploadSessionUploading && time.Now().After(session.ExpiresAt)
+	if session.Status == models.UploadSessionExpired || expired {
+		sendErrorResponse(w, "Upload session expired", http.StatusGone)
+		return session, false
+	}
+	return session, true
+}
+
 // InitiateUpload creates a new upload session
 func (h *ChunkedUploadHandler) InitiateUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -46,8 +106,9 @@ func (h *ChunkedUploadHandler) InitiateUpload(w http.ResponseWriter, r *http.Req
 	// Get file info from request
 	fileName := r.FormValue("file_name")
 	fileSizeStr := r.FormValue("file_size")
-	_ = r.FormValue("media_type") // Reserved for future use
-	_ = r.FormValue("mime_type")  // Reserved for future use
+	mediaType := r.FormValue("media_type")
+	mimeType := r.FormValue("mime_type")
+	checksum := strings.ToLower(strings.TrimSpace(r.FormValue("sha256")))
 
 	if fileName == "" || fileSizeStr == "" {
 		sendErrorResponse(w, "Missing file_name or file_size", http.StatusBadRequest)
@@ -55,11 +116,46 @@ func (h *ChunkedUploadHandler) InitiateUpload(w http.ResponseWriter, r *http.Req
 	}
 
 	fileSize, err := strconv.ParseInt(fileSizeStr, 10, 64)
-	if err != nil {
+	if err != nil || fileSize <= 0 {
 		sendErrorResponse(w, "Invalid file_size", http.StatusBadRequest)
 		return
 	}
 
+	// Photos go through /api/media/upload, where EXIF is stripped and variants are generated
+	if mediaType == "" {
+		mediaType = "video"
+	}
+	if mediaType != "video" && mediaType != "document" {
+		sendErrorResponse(w, "Chunked upload supports only video and document", http.StatusBadRequest)
+		return
+	}
+	if !isAllowedMimeType(mimeType, mediaType) {
+		sendErrorResponse(w, "Invalid file type", http.StatusBadRequest)
+		return
+	}
+
+	maxSize := int64(MaxPhotoSize)
+	if mediaType == "video" {
+		maxSize = int64(MaxVideoSize)
+	}
+	if fileSize > maxSize {
+		sendErrorResponse(w, fmt.Sprintf("File too large. Max size: %dMB", maxSize/(1024*1024)), http.StatusBadRequest)
+		return
+	}
+
+	releaseQuota, ok := reserveStorageQuota(w, h.DB, userID, fileSize)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	if checksum != "" {
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			sendErrorResponse(w, "Invalid sha256", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Generate upload ID
 	uploadID := uuid.New().String()
 
@@ -73,29 +169,40 @@ func (h *ChunkedUploadHandler) InitiateUpload(w http.ResponseWriter, r *http.Req
 	// Calculate total chunks
 	totalChunks := int((fileSize + ChunkSize - 1) / ChunkSize)
 
+	session, err := scanUploadSession(h.DB.QueryRow(ConvertPlaceholders(`
+		INSERT INTO upload_sessions (id, user_id, file_name, file_size, mime_type, media_type, chunk_size, total_chunks,
+			received_chunks, sha256, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+uploadSessionColumns), uploadID, userID, fileName, fileSize, mimeType, mediaType, ChunkSize, totalChunks,
+		make([]byte, (totalChunks+7)/8), nullIfEmpty(checksum), time.Now().Add(MaxChunkAge)))
+	if err != nil {
+		os.RemoveAll(uploadDir)
+		sendErrorResponse(w, "Failed to create upload session", http.StatusInternalServerError)
+		return
+	}
+
 	fmt.Printf("📤 [CHUNKED] Инициализация загрузки: upload_id=%s, user_id=%d, file=%s, size=%d, chunks=%d\n",
 		uploadID, userID, fileName, fileSize, totalChunks)
 
-	sendSuccessResponse(w, map[string]interface{}{
-		"upload_id":    uploadID,
-		"chunk_size":   ChunkSize,
-		"total_chunks": totalChunks,
-	})
+	sendSuccessResponse(w, session)
 }
 
-// UploadChunk handles individual chunk upload
+// UploadChunk handles individual chunk upload. Re-sending a chunk overwrites it,
+// so the client can safely retry after a network error.
 func (h *ChunkedUploadHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	_, ok := r.Context().Value("userID").(int)
+	userID, ok := r.Context().Value("userID").(int)
 	if !ok {
 		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, ChunkSize+1024*1024)
+
 	// Get chunk info
 	uploadID := r.FormValue("upload_id")
 	chunkIndexStr := r.FormValue("chunk_index")
@@ -111,6 +218,25 @@ func (h *ChunkedUploadHandler) UploadChunk(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	session, ok := h.loadUploadSession(w, uploadID, userID)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionUploading {
+		sendErrorResponse(w, "Upload is already completed", http.StatusConflict)
+		return
+	}
+	if chunkIndex < 0 || chunkIndex >= session.TotalChunks {
+		sendErrorResponse(w, "chunk_index out of range", http.StatusBadRequest)
+		return
+	}
+
+	// Every chunk except the last one has exactly chunk_size bytes
+	expectedSize := int64(session.ChunkSize)
+	if chunkIndex == session.TotalChunks-1 {
+		expectedSize = session.FileSize - int64(session.ChunkSize)*int64(session.TotalChunks-1)
+	}
+
 	// Get chunk data
 	file, _, err := r.FormFile("chunk")
 	if err != nil {
@@ -119,32 +245,90 @@ func (h *ChunkedUploadHandler) UploadChunk(w http.ResponseWriter, r *http.Reques
 	}
 	defer file.Close()
 
-	// Save chunk to temp directory
+	// Save chunk to temp directory; rename hides half-written chunks from complete
 	uploadDir := filepath.Join(TempUploadDir, uploadID)
 	chunkPath := filepath.Join(uploadDir, fmt.Sprintf("chunk_%d", chunkIndex))
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		sendErrorResponse(w, "Failed to create upload directory", http.StatusInternalServerError)
+		return
+	}
 
-	dst, err := os.Create(chunkPath)
+	dst, err := os.CreateTemp(uploadDir, fmt.Sprintf("chunk_%d_*.part", chunkIndex))
 	if err != nil {
 		sendErrorResponse(w, "Failed to save chunk", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
-
 	chunkSize, err := io.Copy(dst, file)
+	dst.Close()
 	if err != nil {
+		os.Remove(dst.Name())
 		sendErrorResponse(w, "Failed to write chunk", http.StatusInternalServerError)
 		return
 	}
+	if chunkSize != expectedSize {
+		os.Remove(dst.Name())
+		sendErrorResponse(w, fmt.Sprintf("Invalid chunk size: expected %d bytes, got %d", expectedSize, chunkSize), http.StatusBadRequest)
+		return
+	}
+	if err := os.Rename(dst.Name(), chunkPath); err != nil {
+		os.Remove(dst.Name())
+		sendErrorResponse(w, "Failed to save chunk", http.StatusInternalServerError)
+		return
+	}
+
+	// set_bit runs under the row lock, so parallel chunks don't overwrite each other's bits
+	session, err = scanUploadSession(h.DB.QueryRow(ConvertPlaceholders(`
+		UPDATE upload_sessions
+		SET received_chunks = set_bit(received_chunks, ?, 1), expires_at = ?, updated_at = NOW()
+		WHERE id = ? AND status = 'uploading'
+		RETURNING `+uploadSessionColumns), chunkIndex, time.Now().Add(MaxChunkAge), uploadID))
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Upload is already completed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to update upload session", http.StatusInternalServerError)
+		return
+	}
 
 	fmt.Printf("📦 [CHUNKED] Chunk загружен: upload_id=%s, chunk=%d, size=%d\n", uploadID, chunkIndex, chunkSize)
 
 	sendSuccessResponse(w, map[string]interface{}{
-		"chunk_index": chunkIndex,
-		"uploaded":    true,
+		"chunk_index":     chunkIndex,
+		"uploaded":        true,
+		"received_chunks": session.ReceivedChunks,
+		"total_chunks":    session.TotalChunks,
 	})
 }
 
-// CompleteUpload assembles chunks and processes the file
+// GetUploadStatus returns the session with the list of missing chunks so the client can resume
+func (h *ChunkedUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := r.URL.Query().Get("upload_id")
+	if uploadID == "" {
+		sendErrorResponse(w, "Missing upload_id", http.StatusBadRequest)
+		return
+	}
+
+	session, ok := h.loadUploadSession(w, uploadID, userID)
+	if !ok {
+		return
+	}
+
+	sendSuccessResponse(w, session)
+}
+
+// CompleteUpload assembles chunks, verifies size and SHA-256 and creates the media record
 func (h *ChunkedUploadHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -157,135 +341,296 @@ func (h *ChunkedUploadHandler) CompleteUpload(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Get upload info
+	// File name, type and size come from the session, not from this request
 	uploadID := r.FormValue("upload_id")
-	fileName := r.FormValue("file_name")
-	mediaType := r.FormValue("media_type")
-	mimeType := r.FormValue("mime_type")
-	totalChunksStr := r.FormValue("total_chunks")
-
-	if uploadID == "" || fileName == "" {
+	if uploadID == "" {
 		sendErrorResponse(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
-	totalChunks, err := strconv.Atoi(totalChunksStr)
+	session, ok := h.loadUploadSession(w, uploadID, userID)
+	if !ok {
+		return
+	}
+
+	// Repeated complete (e.g. the response was lost) returns the same media
+	if session.Status == models.UploadSessionCompleted && session.MediaID != nil {
+		media, err := scanUserMedia(h.DB.QueryRow(ConvertPlaceholders(`SELECT `+userMediaColumns+` FROM user_media WHERE id = ?`), *session.MediaID))
+		if err != nil {
+			sendErrorResponse(w, "Media not found", http.StatusNotFound)
+			return
+		}
+		sendSuccessResponse(w, chunkedUploadResponse(media))
+		return
+	}
+	if session.Status != models.UploadSessionUploading {
+		sendErrorResponse(w, "Upload is already being completed", http.StatusConflict)
+		return
+	}
+
+	if len(session.MissingChunks) > 0 {
+		sendErrorResponse(w, fmt.Sprintf("Not all chunks are uploaded: %d missing", len(session.MissingChunks)), http.StatusBadRequest)
+		return
+	}
+
+	// The checksum can be passed on initiate or on complete
+	expectedSum := session.SHA256
+	if sum := strings.ToLower(strings.TrimSpace(r.FormValue("sha256"))); sum != "" {
+		if expectedSum != "" && expectedSum != sum {
+			sendErrorResponse(w, "sha256 does not match the one given on initiate", http.StatusBadRequest)
+			return
+		}
+		expectedSum = sum
+	}
+
+	// Only one complete request assembles the file
+	result, err := h.DB.Exec(ConvertPlaceholders(`
+		UPDATE upload_sessions SET status = 'assembling', updated_at = NOW() WHERE id = ? AND status = 'uploading'
+	`), uploadID)
 	if err != nil {
-		sendErrorResponse(w, "Invalid total_chunks", http.StatusBadRequest)
+		sendErrorResponse(w, "Failed to update upload session", http.StatusInternalServerError)
 		return
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		sendErrorResponse(w, "Upload is already being completed", http.StatusConflict)
+		return
+	}
+	resetSession := func() {
+		h.DB.Exec(ConvertPlaceholders(`UPDATE upload_sessions SET status = 'uploading', updated_at = NOW() WHERE id = ?`), uploadID)
+	}
 
-	fmt.Printf("🔗 [CHUNKED] Сборка файла: upload_id=%s, chunks=%d\n", uploadID, totalChunks)
+	fmt.Printf("🔗 [CHUNKED] Сборка файла: upload_id=%s, chunks=%d\n", uploadID, session.TotalChunks)
 
-	// Assemble chunks
 	uploadDir := filepath.Join(TempUploadDir, uploadID)
 
-	// Generate final file path
-	ext := filepath.Ext(fileName)
+	// Generate final file key (the same for every storage backend)
+	store := mediaStorage()
+	ext := filepath.Ext(session.FileName)
 	finalFileName := uuid.New().String() + ext
 	now := time.Now()
-	relativePath := filepath.Join("users", strconv.Itoa(userID), mediaType+"s",
+	relativePath := path.Join("users", strconv.Itoa(userID), session.MediaType+"s",
 		strconv.Itoa(now.Year()), fmt.Sprintf("%02d", now.Month()), finalFileName)
+
+	// Local files are assembled in place; for S3 the file is assembled next to
+	// the chunks and uploaded once the size and checksum are verified
 	fullPath := filepath.Join(UploadDir, relativePath)
+	if store.Name() != storage.BackendLocal {
+		fullPath = filepath.Join(uploadDir, "assembled"+ext)
+	}
 
 	// Create directory
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		resetSession()
 		sendErrorResponse(w, "Failed to create directory", http.StatusInternalServerError)
 		return
 	}
 
-	// Create final file
-	finalFile, err := os.Create(fullPath)
+	totalSize, actualSum, err := assembleChunks(uploadDir, session.TotalChunks, fullPath)
+	if err != nil {
+		fmt.Printf("❌ [CHUNKED] Ошибка сборки upload_id=%s: %v\n", uploadID, err)
+		os.Remove(fullPath)
+		resetSession()
+		sendErrorResponse(w, "Failed to assemble chunks", http.StatusInternalServerError)
+		return
+	}
+
+	if totalSize != session.FileSize {
+		os.Remove(fullPath)
+		resetSession()
+		sendErrorResponse(w, fmt.Sprintf("Assembled size %d does not match file_size %d", totalSize, session.FileSize), http.StatusBadRequest)
+		return
+	}
+	if expectedSum != "" && actualSum != expectedSum {
+		fmt.Printf("❌ [CHUNKED] SHA-256 не совпадает: upload_id=%s, ожидалось %s, получено %s\n", uploadID, expectedSum, actualSum)
+		os.Remove(fullPath)
+		resetSession()
+		sendErrorResponse(w, "Checksum mismatch", http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("✅ [CHUNKED] Файл собран: %s, size=%d, sha256=%s\n", fullPath, totalSize, actualSum)
+
+	// removeFile removes the stored file if the media record cannot be created
+	removeFile := func() { os.Remove(fullPath) }
+	if store.Name() != storage.BackendLocal {
+		if err := putLocalFileAs(store, fullPath, relativePath, session.MimeType); err != nil {
+			fmt.Printf("❌ [CHUNKED] Ошибка загрузки в %s: upload_id=%s: %v\n", store.Name(), uploadID, err)
+			os.Remove(fullPath)
+			resetSession()
+			sendErrorResponse(w, "Failed to save file", http.StatusInternalServerError)
+			return
+		}
+		os.Remove(fullPath)
+		removeFile = func() { store.Delete(relativePath) }
+	}
+
+	// Save to database; video is transcoded by the media job queue
+	status := models.MediaStatusReady
+	if session.MediaType == "video" {
+		status = models.MediaStatusProcessing
+	}
+
+	query := ConvertPlaceholders(`
+		INSERT INTO user_media (user_id, file_name, original_name, file_path, file_size, mime_type, media_type, status, storage)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`)
+	var mediaID int64
+	err = h.DB.QueryRow(query, userID, finalFileName, session.FileName, relativePath, totalSize, session.MimeType, session.MediaType, status, store.Name()).Scan(&mediaID)
 	if err != nil {
-		sendErrorResponse(w, "Failed to create final file", http.StatusInternalServerError)
+		removeFile()
+		resetSession()
+		sendErrorResponse(w, "Failed to save to database", http.StatusInternalServerError)
 		return
 	}
+
+	fmt.Printf("💾 [CHUNKED] Сохранено в БД: ID=%d\n", mediaID)
+
+	if status == models.MediaStatusProcessing {
+		if err := enqueueMediaJob(h.DB, int(mediaID), userID); err != nil {
+			h.DB.Exec(ConvertPlaceholders("DELETE FROM user_media WHERE id = ?"), mediaID)
+			removeFile()
+			resetSession()
+			sendErrorResponse(w, "Failed to queue video processing", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.DB.Exec(ConvertPlaceholders(`
+		UPDATE upload_sessions SET status = 'completed', media_id = ?, sha256 = ?, updated_at = NOW() WHERE id = ?
+	`), mediaID, actualSum, uploadID)
+
+	// Cleanup temp directory
+	os.RemoveAll(uploadDir)
+
+	fmt.Printf("🎉 [CHUNKED] Загрузка завершена: ID=%d\n", mediaID)
+
+	sendSuccessResponse(w, chunkedUploadResponse(models.UserMedia{
+		ID:           int(mediaID),
+		FileName:     finalFileName,
+		OriginalName: session.FileName,
+		FileSize:     totalSize,
+		MimeType:     session.MimeType,
+		MediaType:    session.MediaType,
+		URL:          "/api/media/file/" + strconv.Itoa(int(mediaID)),
+		Status:       status,
+	}))
+}
+
+// assembleChunks concatenates chunk_0..chunk_{n-1} into dstPath and returns the size and hex SHA-256
+func assembleChunks(uploadDir string, totalChunks int, dstPath string) (int64, string, error) {
+	finalFile, err := os.Create(dstPath)
+	if err != nil {
+		return 0, "", err
+	}
 	defer finalFile.Close()
 
-	// Assemble chunks
+	hasher := sha256.New()
+	out := io.MultiWriter(finalFile, hasher)
+
 	var totalSize int64
 	for i := 0; i < totalChunks; i++ {
-		chunkPath := filepath.Join(uploadDir, fmt.Sprintf("chunk_%d", i))
-		chunkFile, err := os.Open(chunkPath)
+		chunkFile, err := os.Open(filepath.Join(uploadDir, fmt.Sprintf("chunk_%d", i)))
 		if err != nil {
-			sendErrorResponse(w, fmt.Sprintf("Missing chunk %d", i), http.StatusBadRequest)
-			os.Remove(fullPath)
-			return
+			return 0, "", fmt.Errorf("missing chunk %d: %v", i, err)
 		}
 
-		size, err := io.Copy(finalFile, chunkFile)
+		size, err := io.Copy(out, chunkFile)
 		chunkFile.Close()
 		if err != nil {
-			sendErrorResponse(w, "Failed to assemble chunks", http.StatusInternalServerError)
-			os.Remove(fullPath)
-			return
+			return 0, "", err
 		}
 		totalSize += size
 	}
 
-	fmt.Printf("✅ [CHUNKED] Файл собран: %s, size=%d\n", fullPath, totalSize)
+	return totalSize, hex.EncodeToString(hasher.Sum(nil)), nil
+}
 
-	// Save to database FIRST (with original file)
-	query := `
-		INSERT INTO user_media (user_id, file_name, original_name, file_path, file_size, mime_type, media_type)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
-	result, err := h.DB.Exec(query, userID, finalFileName, fileName, relativePath, totalSize, mimeType, mediaType)
-	if err != nil {
-		os.Remove(fullPath)
-		sendErrorResponse(w, "Failed to save to database", http.StatusInternalServerError)
-		return
+// chunkedUploadResponse - response of complete (same fields as before sessions were persisted)
+func chunkedUploadResponse(media models.UserMedia) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            media.ID,
+		"url":           media.URL,
+		"file_name":     media.FileName,
+		"original_name": media.OriginalName,
+		"file_size":     media.FileSize,
+		"mime_type":     media.MimeType,
+		"media_type":    media.MediaType,
+		"status":        media.Status,
+		"optimizing":    media.Status == models.MediaStatusProcessing, // Indicate that optimization is in progress
 	}
+}
 
-	mediaID, _ := result.LastInsertId()
+// StartUploadJanitor periodically expires abandoned upload sessions and removes
+// their chunks. Temp directories without a live session (e.g. left by older
+// versions) are removed once they are older than MaxChunkAge.
+func StartUploadJanitor(db *sql.DB, interval time.Duration) {
+	go func() {
+		cleanupUploadSessions(db)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleanupUploadSessions(db)
+		}
+	}()
 
-	fmt.Printf("💾 [CHUNKED] Сохранено в БД: ID=%d\n", mediaID)
+	log.Printf("✅ Upload janitor started (interval %s)", interval)
+}
 
-	// Optimize video ASYNCHRONOUSLY if needed
-	if mediaType == "video" {
-		go func() {
-			fmt.Printf("🎬 [ASYNC] Начинается фоновая оптимизация для ID=%d\n", mediaID)
-
-			optimizedPath, err := optimizeVideo(fullPath)
-			if err != nil {
-				fmt.Printf("❌ [ASYNC] Ошибка оптимизации ID=%d: %v\n", mediaID, err)
-				return
-			}
-
-			// Update database with optimized file
-			optimizedRelativePath := strings.TrimPrefix(optimizedPath, UploadDir+string(filepath.Separator))
-			optimizedFileName := filepath.Base(optimizedPath)
-			fileInfo, _ := os.Stat(optimizedPath)
-			optimizedSize := fileInfo.Size()
-
-			updateQuery := `
-				UPDATE user_media 
-				SET file_name = ?, file_path = ?, file_size = ?
-				WHERE id = ?
-			`
-			_, err = h.DB.Exec(updateQuery, optimizedFileName, optimizedRelativePath, optimizedSize, mediaID)
-			if err != nil {
-				fmt.Printf("❌ [ASYNC] Ошибка обновления БД для ID=%d: %v\n", mediaID, err)
-				return
-			}
-
-			fmt.Printf("✅ [ASYNC] Оптимизация завершена для ID=%d, новый размер: %d\n", mediaID, optimizedSize)
-		}()
+// cleanupUploadSessions - one janitor pass
+func cleanupUploadSessions(db *sql.DB) {
+	// 'assembling' for longer than MaxChunkAge means the server died during complete
+	rows, err := db.Query(ConvertPlaceholders(`
+		UPDATE upload_sessions SET status = 'expired', updated_at = NOW()
+		WHERE (status = 'uploading' AND expires_at < NOW())
+		   OR (status = 'assembling' AND updated_at < ?)
+		RETURNING id
+	`), time.Now().Add(-MaxChunkAge))
+	if err != nil {
+		log.Printf("❌ Upload janitor: %v", err)
+		return
 	}
 
-	// Cleanup temp directory
-	os.RemoveAll(uploadDir)
+	removed := 0
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			os.RemoveAll(filepath.Join(TempUploadDir, id))
+			removed++
+		}
+	}
+	rows.Close()
 
-	fmt.Printf("🎉 [CHUNKED] Загрузка завершена: ID=%d (оптимизация в фоне)\n", mediaID)
+	// Finished sessions are kept for a week so a repeated complete returns the same media
+	db.Exec(ConvertPlaceholders(`
+		DELETE FROM upload_sessions WHERE status IN ('completed', 'expired') AND updated_at < ?
+	`), time.Now().Add(-7*24*time.Hour))
 
-	sendSuccessResponse(w, map[string]interface{}{
-		"id":            int(mediaID),
-		"url":           "/api/media/file/" + strconv.Itoa(int(mediaID)),
-		"file_name":     finalFileName,
-		"original_name": fileName,
-		"file_size":     totalSize,
-		"mime_type":     mimeType,
-		"media_type":    mediaType,
-		"optimizing":    mediaType == "video", // Indicate that optimization is in progress
-	})
+	cleanupDirectUploads(db)
+
+	// Orphaned temp directories
+	entries, _ := os.ReadDir(TempUploadDir)
+	for _, entry := range entries {
+		// jobs - рабочие файлы очереди медиазадач
+		if !entry.IsDir() || entry.Name() == "jobs" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < MaxChunkAge {
+			continue
+		}
+		var live bool
+		db.QueryRow(ConvertPlaceholders(`
+			SELECT EXISTS(SELECT 1 FROM upload_sessions WHERE id = ? AND status IN ('uploading', 'assembling'))
+		`), entry.Name()).Scan(&live)
+		if !live {
+			os.RemoveAll(filepath.Join(TempUploadDir, entry.Name()))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("🧹 Upload janitor: removed %d abandoned uploads", removed)
+	}
 }
@@ -31,18 +31,35 @@ func getComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Страница - это корневые комментарии (по умолчанию 50) вместе со всеми ответами на них
+	limit, cursor, err := parseCursorPage(r, 50, 100)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
+	}
+
+	rootsQuery := `SELECT id FROM comments WHERE post_id = ? AND parent_id IS NULL`
+	args := []interface{}{postID}
+	if cursor != nil {
+		rootsQuery += ` AND ` + cursorCondition("created_at", "id", true)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	rootsQuery += ` ORDER BY created_at ASC, id ASC LIMIT ?`
+	args = append(args, limit+1)
+
 	query := `
+		WITH roots AS (` + rootsQuery + `)
 		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.parent_id, c.reply_to_user_id,
 		       u.name, u.email, u.avatar,
 		       ru.name, ru.email, ru.avatar
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
 		LEFT JOIN users ru ON c.reply_to_user_id = ru.id
-		WHERE c.post_id = ?
-		ORDER BY c.created_at ASC
+		WHERE c.id IN (SELECT id FROM roots) OR c.parent_id IN (SELECT id FROM roots)
+		ORDER BY c.created_at ASC, c.id ASC
 	`
 
-	rows, err := database.DB.Query(query, postID)
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
 	if err != nil {
 		sendErrorResponse(w, "Ошибка получения комментариев: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -113,7 +130,14 @@ func getComments(w http.ResponseWriter, r *http.Request) {
 		rootComments = []*models.Comment{}
 	}
 
-	sendSuccessResponse(w, rootComments)
+	nextCursor := ""
+	if len(rootComments) > limit {
+		rootComments = rootComments[:limit]
+		last := rootComments[len(rootComments)-1]
+		nextCursor = encodeCursorString(last.CreatedAt, last.ID)
+	}
+
+	sendPageResponse(w, rootComments, nextCursor)
 }
 
 func createComment(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/storage"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DirectMultipartThreshold - файлы больше этого размера загружаются в S3 по частям
+	DirectMultipartThreshold = 16 * 1024 * 1024
+	// DirectPartSize - размер части multipart-загрузки (минимум S3 - 5MB)
+	DirectPartSize = 8 * 1024 * 1024
+)
+
+// DirectUploadHandler - загрузка файлов напрямую в S3 по presigned URL.
+// Байты не проходят через backend: клиент получает URL, загружает файл в бакет
+// и подтверждает загрузку, после чего создаётся запись user_media.
+type DirectUploadHandler struct {
+	DB *sql.DB
+}
+
+func NewDirectUploadHandler(db *sql.DB) *DirectUploadHandler {
+	return &DirectUploadHandler{DB: db}
+}
+
+// directUploadRow - сохранённая прямая загрузка
+type directUploadRow struct {
+	ID         string
+	UserID     int
+	ObjectKey  string
+	FileName   string
+	FileSize   int64
+	MimeType   string
+	MediaType  string
+	IsPrivate  bool
+	S3UploadID string
+	TotalParts int
+	Status     string
+	MediaID    *int
+	ExpiresAt  time.Time
+}
+
+// loadDirectUpload загружает прямую загрузку и проверяет владельца.
+// При ошибке отправляет ответ и возвращает false.
+func (h *DirectUploadHandler) loadDirectUpload(w http.ResponseWriter, uploadID string, userID int) (directUploadRow, bool) {
+	var u directUploadRow
+	var s3UploadID sql.NullString
+	var totalParts, mediaID sql.NullInt64
+	err := h.DB.QueryRow(ConvertPlaceholders(`
+		SELECT id, user_id, object_key, file_name, file_size, mime_type, media_type, is_private,
+			s3_upload_id, total_parts, status, media_id, expires_at
+		FROM direct_uploads WHERE id = ?
+	`), uploadID).Scan(&u.ID, &u.UserID, &u.ObjectKey, &u.FileName, &u.FileSize, &u.MimeType, &u.MediaType,
+		&u.IsPrivate, &s3UploadID, &totalParts, &u.Status, &mediaID, &u.ExpiresAt)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Upload not found", http.StatusNotFound)
+		return u, false
+	}
+	if err != nil {
+		log.Printf("❌ [DIRECT] Error loading upload %s: %v", uploadID, err)
+		sendErrorResponse(w, "Failed to load upload", http.StatusInternalServerError)
+		return u, false
+	}
+	if u.UserID != userID {
+		sendErrorResponse(w, "Access denied", http.StatusForbidden)
+		return u, false
+	}
+
+	u.S3UploadID = s3UploadID.String
+	u.TotalParts = int(totalParts.Int64)
+	u.MediaID = nullIntPtr(mediaID)
+	return u, true
+}
+
+// InitiateUpload выдаёт presigned URL для загрузки файла в бакет (POST /api/media/direct/initiate).
+// Файлы до DirectMultipartThreshold загружаются одним PUT, остальные - по частям.
+func (h *DirectUploadHandler) InitiateUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !storage.Enabled() {
+		sendErrorResponse(w, "Direct upload is not available: S3 storage is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req models.InitiateDirectUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.FileName = strings.TrimSpace(req.FileName)
+	if req.FileName == "" || req.FileSize <= 0 {
+		sendErrorResponse(w, "Missing file_name or file_size", http.StatusBadRequest)
+		return
+	}
+
+	// Фото загружаются через /api/media/upload, где удаляется EXIF и создаются варианты
+	if req.MediaType == "" {
+		req.MediaType = "video"
+	}
+	if req.MediaType != "video" && req.MediaType != "document" {
+		sendErrorResponse(w, "Direct upload supports only video and document", http.StatusBadRequest)
+		return
+	}
+	if !isAllowedMimeType(req.MimeType, req.MediaType) {
+		sendErrorResponse(w, "Invalid file type", http.StatusBadRequest)
+		return
+	}
+
+	maxSize := int64(MaxPhotoSize)
+	if req.MediaType == "video" {
+		maxSize = int64(MaxVideoSize)
+	}
+	if req.FileSize > maxSize {
+		sendErrorResponse(w, fmt.Sprintf("File too large. Max size: %dMB", maxSize/(1024*1024)), http.StatusBadRequest)
+		return
+	}
+
+	releaseQuota, ok := reserveStorageQuota(w, h.DB, userID, req.FileSize)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	uploadID := uuid.New().String()
+	now := time.Now()
+	objectKey := strings.Join([]string{"users", strconv.Itoa(userID), req.MediaType + "s",
+		strconv.Itoa(now.Year()), fmt.Sprintf("%02d", now.Month()),
+		uuid.New().String() + strings.ToLower(filepath.Ext(req.FileName))}, "/")
+
+	ttl := storage.PresignTTL()
+	result := models.DirectUpload{
+		UploadID:  uploadID,
+		Method:    http.MethodPut,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	var s3UploadID *string
+	var partSize *int64
+	var totalParts *int
+	if req.FileSize > DirectMultipartThreshold {
+		id, err := storage.GlobalS3Client.CreateMultipartUpload(objectKey, req.MimeType)
+		if err != nil {
+			log.Printf("❌ [DIRECT] %v", err)
+			sendErrorResponse(w, "Failed to start upload", http.StatusBadGateway)
+			return
+		}
+
+		size := int64(DirectPartSize)
+		parts := int((req.FileSize + size - 1) / size)
+		for n := 1; n <= parts; n++ {
+			url, err := storage.GlobalS3Client.PresignUploadPart(objectKey, id, int64(n), ttl)
+			if err != nil {
+				log.Printf("❌ [DIRECT] %v", err)
+				storage.GlobalS3Client.AbortMultipartUpload(objectKey, id)
+				sendErrorResponse(w, "Failed to sign upload", http.StatusInternalServerError)
+				return
+			}
+			result.Parts = append(result.Parts, models.DirectUploadPart{PartNumber: int64(n), URL: url})
+		}
+
+		result.Multipart = true
+		result.PartSize = size
+		s3UploadID, partSize, totalParts = &id, &size, &parts
+	} else {
+		url, headers, err := storage.GlobalS3Client.PresignPut(objectKey, req.MimeType, req.FileSize, ttl)
+		if err != nil {
+			log.Printf("❌ [DIRECT] %v", err)
+			sendErrorResponse(w, "Failed to sign upload", http.StatusInternalServerError)
+			return
+		}
+		result.URL = url
+		result.Headers = make(map[string]string, len(headers))
+		for name := range headers {
+			// Host выставляет сам клиент
+			if !strings.EqualFold(name, "Host") {
+				result.Headers[name] = headers.Get(name)
+			}
+		}
+	}
+
+	_, err := h.DB.Exec(ConvertPlaceholders(`
+		INSERT INTO direct_uploads (id, user_id, object_key, file_name, file_size, mime_type, media_type, is_private,
+			s3_upload_id, part_size, total_parts, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), uploadID, userID, objectKey, req.FileName, req.FileSize, req.MimeType, req.MediaType, req.Private,
+		s3UploadID, partSize, totalParts, result.ExpiresAt)
+	if err != nil {
+		log.Printf("❌ [DIRECT] Error saving upload: %v", err)
+		if s3UploadID != nil {
+			storage.GlobalS3Client.AbortMultipartUpload(objectKey, *s3UploadID)
+		}
+		sendErrorResponse(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("📤 [DIRECT] Инициализация загрузки: upload_id=%s, user_id=%d, key=%s, size=%d, multipart=%v\n",
+		uploadID, userID, objectKey, req.FileSize, result.Multipart)
+
+	sendSuccessResponse(w, result)
+}
+
+// CompleteUpload подтверждает загрузку и регистрирует файл в медиатеке (POST /api/media/direct/complete).
+// Повторный вызов для завершённой загрузки возвращает тот же файл.
+func (h *DirectUploadHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !storage.Enabled() {
+		sendErrorResponse(w, "Direct upload is not available: S3 storage is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req models.CompleteDirectUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UploadID == "" {
+		sendErrorResponse(w, "Missing upload_id", http.StatusBadRequest)
+		return
+	}
+
+	upload, ok := h.loadDirectUpload(w, req.UploadID, userID)
+	if !ok {
+		return
+	}
+
+	if upload.Status == models.DirectUploadCompleted && upload.MediaID != nil {
+		media, err := scanUserMedia(h.DB.QueryRow(ConvertPlaceholders(`
+			SELECT `+userMediaColumns+` FROM user_media WHERE id = ?
+		`), *upload.MediaID))
+		if err != nil {
+			sendErrorResponse(w, "Media not found", http.StatusNotFound)
+			return
+		}
+		sendSuccessResponse(w, media)
+		return
+	}
+	if upload.Status != models.DirectUploadPending {
+		sendErrorResponse(w, "Upload is "+upload.Status, http.StatusGone)
+		return
+	}
+
+	if upload.S3UploadID != "" {
+		if len(req.Parts) != upload.TotalParts {
+			sendErrorResponse(w, fmt.Sprintf("Expected %d parts, got %d", upload.TotalParts, len(req.Parts)), http.StatusBadRequest)
+			return
+		}
+		parts := make([]storage.CompletedPart, 0, len(req.Parts))
+		for _, p := range req.Parts {
+			if p.PartNumber < 1 || int(p.PartNumber) > upload.TotalParts || p.ETag == "" {
+				sendErrorResponse(w, "Invalid part list", http.StatusBadRequest)
+				return
+			}
+			parts = append(parts, storage.CompletedPart{PartNumber: p.PartNumber, ETag: p.ETag})
+		}
+		if err := storage.GlobalS3Client.CompleteMultipartUpload(upload.ObjectKey, upload.S3UploadID, parts); err != nil {
+			// Клиент может дозагрузить недостающие части и повторить запрос
+			log.Printf("❌ [DIRECT] upload_id=%s: %v", upload.ID, err)
+			sendErrorResponse(w, "Failed to complete multipart upload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	info, err := storage.GlobalS3Client.HeadObject(upload.ObjectKey)
+	if err != nil {
+		sendErrorResponse(w, "File was not uploaded", http.StatusBadRequest)
+		return
+	}
+	if info.Size != upload.FileSize {
+		// Части уже собраны в объект, отменять multipart не нужно
+		abortDirectUpload(h.DB, upload.ID, upload.ObjectKey, "", models.DirectUploadAborted)
+		sendErrorResponse(w, fmt.Sprintf("Uploaded size %d does not match file_size %d", info.Size, upload.FileSize), http.StatusBadRequest)
+		return
+	}
+
+	// Захватываем загрузку, чтобы параллельный complete не создал второй файл
+	result, err := h.DB.Exec(ConvertPlaceholders(`
+		UPDATE direct_uploads SET status = 'completed', updated_at = NOW() WHERE id = ? AND status = 'pending'
+	`), upload.ID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to complete upload", http.StatusInternalServerError)
+		return
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		sendErrorResponse(w, "Upload is already being completed", http.StatusConflict)
+		return
+	}
+
+	// Видео транскодируется очередью медиазадач
+	status := models.MediaStatusReady
+	if upload.MediaType == "video" {
+		status = models.MediaStatusProcessing
+	}
+
+	media, err := scanUserMedia(h.DB.QueryRow(ConvertPlaceholders(`
+		INSERT INTO user_media (user_id, file_name, original_name, file_path, file_size, mime_type, media_type,
+			status, storage, is_private)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 's3', ?)
+		RETURNING `+userMediaColumns), userID, filepath.Base(upload.ObjectKey), upload.FileName, upload.ObjectKey,
+		upload.FileSize, upload.MimeType, upload.MediaType, status, upload.IsPrivate))
+	if err != nil {
+		log.Printf("❌ [DIRECT] Error saving media: %v", err)
+		h.DB.Exec(ConvertPlaceholders(`
+			UPDATE direct_uploads SET status = 'pending', updated_at = NOW() WHERE id = ?
+		`), upload.ID)
+		sendErrorResponse(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	if status == models.MediaStatusProcessing {
+		if err := enqueueMediaJob(h.DB, media.ID, userID); err != nil {
+			h.DB.Exec(ConvertPlaceholders("DELETE FROM user_media WHERE id = ?"), media.ID)
+			h.DB.Exec(ConvertPlaceholders(`
+				UPDATE direct_uploads SET status = 'pending', updated_at = NOW() WHERE id = ?
+			`), upload.ID)
+			sendErrorResponse(w, "Failed to queue video processing", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.DB.Exec(ConvertPlaceholders(`UPDATE direct_uploads SET media_id = ? WHERE id = ?`), media.ID, upload.ID)
+
+	fmt.Printf("🎉 [DIRECT] Загрузка завершена: upload_id=%s, media_id=%d\n", upload.ID, media.ID)
+
+	sendSuccessResponse(w, media)
+}
+
+// AbortUpload отменяет незавершённую загрузку (POST /api/media/direct/abort)
+func (h *DirectUploadHandler) AbortUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CompleteDirectUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UploadID == "" {
+		sendErrorResponse(w, "Missing upload_id", http.StatusBadRequest)
+		return
+	}
+
+	upload, ok := h.loadDirectUpload(w, req.UploadID, userID)
+	if !ok {
+		return
+	}
+	if upload.Status != models.DirectUploadPending {
+		sendErrorResponse(w, "Upload is "+upload.Status, http.StatusConflict)
+		return
+	}
+
+	abortDirectUpload(h.DB, upload.ID, upload.ObjectKey, upload.S3UploadID, models.DirectUploadAborted)
+
+	sendSuccessResponse(w, map[string]string{"message": "Upload aborted"})
+}
+
+// abortDirectUpload удаляет загруженные данные из бакета и переводит загрузку в status
+func abortDirectUpload(db *sql.DB, id, objectKey, s3UploadID, status string) {
+	if storage.Enabled() {
+		if s3UploadID != "" {
+			if err := storage.GlobalS3Client.AbortMultipartUpload(objectKey, s3UploadID); err != nil {
+				log.Printf("⚠️ [DIRECT] %v", err)
+			}
+		}
+		storage.GlobalS3Client.DeleteObject(objectKey)
+	}
+	db.Exec(ConvertPlaceholders(`
+		UPDATE direct_uploads SET status = ?, updated_at = NOW() WHERE id = ? AND status = 'pending'
+	`), status, id)
+}
+
+// cleanupDirectUploads - проход janitor'а по прямым загрузкам: просроченные
+// отменяются в бакете, завершённые и отменённые удаляются через неделю
+func cleanupDirectUploads(db *sql.DB) {
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT id, object_key, COALESCE(s3_upload_id, '') FROM direct_uploads
+		WHERE status = 'pending' AND expires_at < NOW()
+	`))
+	if err != nil {
+		log.Printf("❌ Upload janitor (direct): %v", err)
+		return
+	}
+
+	type expiredUpload struct{ id, key, s3UploadID string }
+	var expired []expiredUpload
+	for rows.Next() {
+		var u expiredUpload
+		if rows.Scan(&u.id, &u.key, &u.s3UploadID) == nil {
+			expired = append(expired, u)
+		}
+	}
+	rows.Close()
+
+	for _, u := range expired {
+		abortDirectUpload(db, u.id, u.key, u.s3UploadID, models.DirectUploadExpired)
+	}
+	if len(expired) > 0 {
+		log.Printf("🧹 Upload janitor: expired %d direct uploads", len(expired))
+	}
+
+	db.Exec(ConvertPlaceholders(`
+		DELETE FROM direct_uploads WHERE status <> 'pending' AND updated_at < ?
+	`), time.Now().Add(-7*24*time.Hour))
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"backend/models"
+	"database"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Ранжирование ленты "Для вас" в два этапа:
+//  1. StartFeedRanker периодически пересчитывает post_scores - агрегаты лайков,
+//     комментариев и контентные бусты для постов за последнюю неделю (без
+//     коррелированных подзапросов, одним INSERT ... SELECT с GROUP BY).
+//  2. getRankedFeed строит для пользователя снимок выдачи: к post_scores
+//     (и ещё не посчитанным новым постам) добавляются дружба, членство в
+//     организациях и город, учитывается возраст поста. Снимок хранится в
+//     feed_snapshots (общий для реплик), страницы отдаются из него; после снимка лента продолжается
+//     остальными постами по времени.
+const (
+	feedRankingWindow = 7 * 24 * time.Hour
+	feedSnapshotTTL   = 5 * time.Minute
+	feedSnapshotSize  = 500
+
+	// Веса ранжирования
+	feedWeightLikesTotal     = 1.0 // ln(1 + лайков всего)
+	feedWeightCommentsTotal  = 2.0 // ln(1 + комментариев всего)
+	feedWeightLikesRecent    = 0.5 // за каждый лайк за последние 24 часа
+	feedWeightCommentsRecent = 1.0 // за каждый комментарий за последние 24 часа
+	feedWeightContentBoost   = 3.0 // за метку "ищет дом"/"потерян"/... и за срочного питомца
+	feedWeightFriend         = 5.0
+	feedWeightOrganization   = 4.0 // организации, в которых состоит пользователь
+	feedWeightCity           = 3.0
+	feedAgeGravity           = 1.5 // score / (часов + 2)^gravity
+)
+
+// feedPriorityTags - метки, которые поднимают пост в ленте
+var feedPriorityTags = []string{"ищет дом", "потерян", "найден", "срочно", "нужна помощь"}
+
+// StartFeedRanker запускает фоновый пересчёт post_scores
+func StartFeedRanker(db *sql.DB, interval time.Duration) {
+	go func() {
+		refresh := func() {
+			if err := refreshPostScores(db); err != nil {
+				log.Printf("❌ Feed ranker: %v", err)
+			}
+		}
+
+		refresh()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refresh()
+		}
+	}()
+
+	log.Printf("✅ Feed ranker started (interval %s)", interval)
+}
+
+// refreshPostScores пересчитывает агрегаты для опубликованных постов в окне ранжирования
+func refreshPostScores(db *sql.DB) error {
+	since := time.Now().Add(-feedRankingWindow)
+
+	tagPatterns := make([]string, len(feedPriorityTags))
+	for i, tag := range feedPriorityTags {
+		tagPatterns[i] = "'%" + tag + "%'"
+	}
+
+	query := `
+		INSERT INTO post_scores (
+			post_id, author_id, author_type, city, created_at,
+			likes_total, comments_total, likes_recent, comments_recent, content_boost, updated_at
+		)
+		SELECT p.id, p.author_id, p.author_type,
+		       coalesce(CASE WHEN p.author_type = 'user' THEN u.location ELSE o.address_city END, ''),
+		       p.created_at,
+		       coalesce(l.total, 0), coalesce(c.total, 0), coalesce(l.recent, 0), coalesce(c.recent, 0),
+		       (CASE WHEN p.tags::text ILIKE ANY (ARRAY[` + strings.Join(tagPatterns, ", ") + `]) THEN 1 ELSE 0 END) +
+		       (CASE WHEN up.post_id IS NOT NULL THEN 1 ELSE 0 END),
+		       NOW()
+		FROM posts p
+		LEFT JOIN users u ON p.author_type = 'user' AND u.id = p.author_id
+		LEFT JOIN organizations o ON p.author_type = 'organization' AND o.id = p.author_id
+		LEFT JOIN (
+			SELECT post_id, COUNT(*) AS total,
+			       COUNT(*) FILTER (WHERE created_at > NOW() - INTERVAL '24 hours') AS recent
+			FROM likes WHERE created_at > ? GROUP BY post_id
+		) l ON l.post_id = p.id
+		LEFT JOIN (
+			SELECT post_id, COUNT(*) AS total,
+			       COUNT(*) FILTER (WHERE created_at > NOW() - INTERVAL '24 hours') AS recent
+			FROM comments WHERE created_at > ? GROUP BY post_id
+		) c ON c.post_id = p.id
+		LEFT JOIN (
+			SELECT DISTINCT pp.post_id
+			FROM post_pets pp
+			JOIN pets pt ON pt.id = pp.pet_id
+			WHERE pt.urgent::int = 1
+		) up ON up.post_id = p.id
+		WHERE p.is_deleted = FALSE AND p.status = 'published' AND p.created_at > ?
+		ON CONFLICT (post_id) DO UPDATE SET
+			city = EXCLUDED.city,
+			created_at = EXCLUDED.created_at,
+			likes_total = EXCLUDED.likes_total,
+			comments_total = EXCLUDED.comments_total,
+			likes_recent = EXCLUDED.likes_recent,
+			comments_recent = EXCLUDED.comments_recent,
+			content_boost = EXCLUDED.content_boost,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := db.Exec(ConvertPlaceholders(query), since, since, since); err != nil {
+		return fmt.Errorf("refresh post_scores: %w", err)
+	}
+
+	// Убираем устаревшие, удалённые и снятые с публикации посты
+	_, err := db.Exec(ConvertPlaceholders(`
+		DELETE FROM post_scores ps
+		WHERE ps.created_at <= ?
+		   OR NOT EXISTS (
+			SELECT 1 FROM posts p
+			WHERE p.id = ps.post_id AND p.is_deleted = FALSE AND p.status = 'published'
+		   )
+	`), since)
+	if err != nil {
+		return fmt.Errorf("cleanup post_scores: %w", err)
+	}
+
+	// Снимки ленты, по которым уже никто не листает
+	_, err = db.Exec(ConvertPlaceholders(`DELETE FROM feed_snapshots WHERE built_at < ?`), time.Now().Add(-2*feedSnapshotTTL))
+	if err != nil {
+		return fmt.Errorf("cleanup feed_snapshots: %w", err)
+	}
+
+	return nil
+}
+
+// feedSnapshot - ранжированный список постов для одного пользователя
+type feedSnapshot struct {
+	id      int64
+	postIDs []int
+	builtAt time.Time
+}
+
+// rankedFeedSnapshot возвращает снимок выдачи. Первая страница (без курсора)
+// пересобирает устаревший снимок; следующие страницы читают тот снимок,
+// на который указывает курсор, чтобы посты не дублировались и не терялись.
+// Снимки хранятся в feed_snapshots и общие для всех реплик.
+func rankedFeedSnapshot(db *sql.DB, userID int, userCity string, cursor *pageCursor) (*feedSnapshot, error) {
+	snap, err := loadFeedSnapshot(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if snap != nil {
+		if cursor != nil && cursor.Snapshot == snap.id {
+			return snap, nil
+		}
+		// Первая страница пересобирается, если снимок устарел или появились новые посты
+		if cursor == nil && time.Since(snap.builtAt) < feedSnapshotTTL && !hasPostsSince(db, snap.builtAt) {
+			return snap, nil
+		}
+	}
+
+	postIDs, err := rankPostsForUser(db, userID, userCity)
+	if err != nil {
+		return nil, err
+	}
+
+	snap = &feedSnapshot{id: time.Now().UnixNano(), postIDs: postIDs, builtAt: time.Now()}
+	if err := saveFeedSnapshot(db, userID, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// loadFeedSnapshot читает снимок пользователя (nil - снимка нет)
+func loadFeedSnapshot(db *sql.DB, userID int) (*feedSnapshot, error) {
+	snap := &feedSnapshot{}
+	var postIDs pq.Int64Array
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT snapshot_id, post_ids, built_at FROM feed_snapshots WHERE user_id = ?
+	`), userID).Scan(&snap.id, &postIDs, &snap.builtAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap.postIDs = make([]int, len(postIDs))
+	for i, id := range postIDs {
+		snap.postIDs[i] = int(id)
+	}
+	return snap, nil
+}
+
+// saveFeedSnapshot заменяет снимок пользователя
+func saveFeedSnapshot(db *sql.DB, userID int, snap *feedSnapshot) error {
+	postIDs := make(pq.Int64Array, len(snap.postIDs))
+	for i, id := range snap.postIDs {
+		postIDs[i] = int64(id)
+	}
+
+	_, err := db.Exec(ConvertPlaceholders(`
+		INSERT INTO feed_snapshots (user_id, snapshot_id, post_ids, built_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			snapshot_id = EXCLUDED.snapshot_id,
+			post_ids = EXCLUDED.post_ids,
+			built_at = EXCLUDED.built_at
+	`), userID, snap.id, postIDs, snap.builtAt)
+	return err
+}
+
+// hasPostsSince - опубликованы ли посты после t (снимок их ещё не содержит)
+func hasPostsSince(db *sql.DB, t time.Time) bool {
+	var exists bool
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT EXISTS(SELECT 1 FROM posts WHERE is_deleted = FALSE AND status = 'published' AND created_at > ?)
+	`), t).Scan(&exists)
+	return err == nil && exists
+}
+
+// rankPostsForUser - один запрос по post_scores с персональными бустами.
+// Посты, опубликованные после последнего пересчёта, ранжируются с нулевыми счётчиками.
+func rankPostsForUser(db *sql.DB, userID int, userCity string) ([]int, error) {
+	query := fmt.Sprintf(`
+		WITH friends AS (
+			SELECT CASE WHEN user_id = ? THEN friend_id ELSE user_id END AS id
+			FROM friendships
+			WHERE (user_id = ? OR friend_id = ?) AND status = 'accepted'
+		),
+		my_orgs AS (
+			SELECT organization_id AS id FROM organization_members WHERE user_id = ?
+		)
+		SELECT ps.post_id
+		FROM (
+			SELECT post_id, author_id, author_type, city, created_at,
+			       likes_total, comments_total, likes_recent, comments_recent, content_boost
+			FROM post_scores
+			UNION ALL
+			SELECT p.id, p.author_id, p.author_type, '', p.created_at, 0, 0, 0, 0, 0
+			FROM posts p
+			WHERE p.is_deleted = FALSE AND p.status = 'published' AND p.created_at > ?
+			  AND NOT EXISTS (SELECT 1 FROM post_scores WHERE post_id = p.id)
+		) ps
+		ORDER BY (
+			1
+			+ %[1]g * ln(1 + ps.likes_total)
+			+ %[2]g * ln(1 + ps.comments_total)
+			+ %[3]g * ps.likes_recent
+			+ %[4]g * ps.comments_recent
+			+ %[5]g * ps.content_boost
+			+ CASE WHEN ps.author_type = 'user' AND ps.author_id IN (SELECT id FROM friends) THEN %[6]g ELSE 0 END
+			+ CASE WHEN ps.author_type = 'organization' AND ps.author_id IN (SELECT id FROM my_orgs) THEN %[7]g ELSE 0 END
+			+ CASE WHEN ? <> '' AND lower(ps.city) = lower(?) THEN %[8]g ELSE 0 END
+		) / power(EXTRACT(EPOCH FROM (NOW() - ps.created_at)) / 3600 + 2, %[9]g) DESC,
+		ps.created_at DESC, ps.post_id DESC
+		LIMIT %[10]d
+	`, feedWeightLikesTotal, feedWeightCommentsTotal, feedWeightLikesRecent, feedWeightCommentsRecent,
+		feedWeightContentBoost, feedWeightFriend, feedWeightOrganization, feedWeightCity,
+		feedAgeGravity, feedSnapshotSize)
+
+	since := time.Now().Add(-feedRankingWindow)
+	rows, err := db.Query(ConvertPlaceholders(query), userID, userID, userID, userID, since, userCity, userCity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		postIDs = append(postIDs, id)
+	}
+	return postIDs, rows.Err()
+}
+
+// encodeRankedCursor - курсор ранжированной ленты: снимок и позиция в нём
+func encodeRankedCursor(snapshotID int64, offset, lastPostID int) string {
+	data, _ := json.Marshal(pageCursor{ID: lastPostID, Snapshot: snapshotID, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// encodeChronoCursor - курсор хронологической части ленты после снимка
+func encodeChronoCursor(snapshotID int64, last models.Post) string {
+	createdAt, err := time.Parse(time.RFC3339Nano, last.CreatedAt)
+	if err != nil {
+		return ""
+	}
+	data, _ := json.Marshal(pageCursor{CreatedAt: createdAt, ID: last.ID, Snapshot: snapshotID, Chrono: true})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// getRankedFeed отдаёт страницу ленты "Для вас": сначала ранжированный снимок,
+// затем остальные посты по времени, чтобы лента не обрывалась
+func getRankedFeed(w http.ResponseWriter, r *http.Request, userID int, userCity string, limit int, cursor *pageCursor) {
+	snap, err := rankedFeedSnapshot(database.DB, userID, userCity, cursor)
+	if err != nil {
+		log.Printf("❌ Ranked feed error for user %d: %v", userID, err)
+		sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Курсор от другого снимка (истёк или пересобран):
+	// позиция в нём ничего не значит для нового снимка, начинаем его сначала
+	offset := 0
+	var chronoAfter *pageCursor
+	if cursor != nil {
+		switch {
+		case cursor.Chrono:
+			chronoAfter = cursor
+			offset = len(snap.postIDs)
+		case cursor.Snapshot == snap.id:
+			offset = min(cursor.Offset, len(snap.postIDs))
+		}
+	}
+
+	end := min(offset+limit, len(snap.postIDs))
+	posts := []models.Post{}
+	if end > offset {
+		posts, err = loadFeedPostsByIDs(userID, snap.postIDs[offset:end])
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	nextCursor := ""
+	if end < len(snap.postIDs) {
+		nextCursor = encodeRankedCursor(snap.id, end, snap.postIDs[end-1])
+	} else if rest := limit - (end - offset); rest > 0 {
+		// Снимок закончился - добираем страницу постами по времени (кроме уже показанных в снимке)
+		chrono, err := loadChronoFeedPosts(userID, snap.postIDs, chronoAfter, rest+1)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(chrono) > rest {
+			chrono = chrono[:rest]
+			nextCursor = encodeChronoCursor(snap.id, chrono[len(chrono)-1])
+		}
+		posts = append(posts, chrono...)
+	} else {
+		// Страница закончилась ровно на последнем посте снимка
+		nextCursor = encodeChronoCursor(snap.id, models.Post{ID: snap.postIDs[end-1], CreatedAt: time.Now().Format(time.RFC3339Nano)})
+	}
+
+	posts = loadPetsForPostsBatch(posts)
+	if r.URL.Query().Get("include_polls") == "true" {
+		posts = loadPollsForPostsBatch(posts, userID)
+	}
+	for i := range posts {
+		posts[i].CanEdit = checkCanEditPost(userID, &posts[i])
+	}
+
+	log.Printf("✅ Ranked feed for user %d: %d posts (offset %d of %d)", userID, len(posts), offset, len(snap.postIDs))
+	posts = loadAttachmentVariantsBatch(posts)
+	sendPageResponse(w, posts, nextCursor)
+}
+
+// feedPostColumns - колонки для scanFeedPosts; is_friend по CTE friends.
+// Счётчик комментариев живой, как на странице поста: post_scores отстаёт
+// на интервал пересчёта и нужен только для ранжирования.
+const feedPostColumns = `
+		WITH friends AS (
+			SELECT CASE WHEN user_id = ? THEN friend_id ELSE user_id END AS id
+			FROM friendships
+			WHERE (user_id = ? OR friend_id = ?) AND status = 'accepted'
+		)
+		SELECT p.id, p.author_id, p.author_type, p.content, p.attached_pets,
+		       p.attachments, p.tags, p.status, p.scheduled_at, p.created_at, p.updated_at,
+		       o.name as org_name, o.short_name as org_short_name, o.logo as org_logo,
+		       u.name as user_name, u.last_name as user_last_name, u.avatar as user_avatar,
+		       (SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count,
+		       CASE WHEN p.author_type = 'user' AND p.author_id IN (SELECT id FROM friends) THEN 1 ELSE 0 END as is_friend
+		FROM posts p
+		LEFT JOIN organizations o ON p.author_id = o.id AND p.author_type = 'organization'
+		LEFT JOIN users u ON p.author_id = u.id AND p.author_type = 'user'
+		WHERE p.is_deleted = FALSE AND p.status = 'published'`
+
+// loadFeedPostsByIDs загружает посты в том порядке, в котором переданы ids
+func loadFeedPostsByIDs(userID int, ids []int) ([]models.Post, error) {
+	placeholders := make([]string, len(ids))
+	args := []interface{}{userID, userID, userID}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := feedPostColumns + ` AND p.id IN (` + strings.Join(placeholders, ", ") + `)`
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loaded, err := scanFeedPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]models.Post, len(loaded))
+	for _, post := range loaded {
+		byID[post.ID] = post
+	}
+
+	// Восстанавливаем порядок ранжирования; удалённые после снимка посты пропускаем
+	posts := make([]models.Post, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// loadChronoFeedPosts - посты по (created_at, id) после курсора, кроме вошедших в снимок
+func loadChronoFeedPosts(userID int, excludeIDs []int, after *pageCursor, limit int) ([]models.Post, error) {
+	exclude := make(pq.Int64Array, len(excludeIDs))
+	for i, id := range excludeIDs {
+		exclude[i] = int64(id)
+	}
+
+	query := feedPostColumns + ` AND NOT (p.id = ANY(?))`
+	args := []interface{}{userID, userID, userID, exclude}
+	if after != nil {
+		query += ` AND ` + cursorCondition("p.created_at", "p.id", false)
+		args = append(args, after.CreatedAt, after.ID)
+	}
+	query += ` ORDER BY p.created_at DESC, p.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts, err := scanFeedPosts(rows)
+	if posts == nil {
+		posts = []models.Post{}
+	}
+	return posts, err
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// FollowHandler - подписаться на пользователя или организацию (повторный вызов меняет notify)
+// POST /api/follows/follow {"target_type": "organization", "target_id": 5, "notify": true}
+// Уведомления о новых постах приходят только от организаций и только если notify включён.
+func FollowHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(int)
+
+		var req models.FollowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateFollowTarget(db, userID, req.TargetType, req.TargetID); err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		notify := false
+		if req.Notify != nil {
+			notify = *req.Notify
+		}
+
+		var follow models.Follow
+		var inserted bool
+		err := db.QueryRow(ConvertPlaceholders(`
+			INSERT INTO follows (follower_id, target_type, target_id, notify)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (follower_id, target_type, target_id) DO UPDATE SET notify = EXCLUDED.notify
+			RETURNING id, follower_id, target_type, target_id, notify, created_at, (xmax = 0)
+		`), userID, req.TargetType, req.TargetID, notify).Scan(
+			&follow.ID, &follow.FollowerID, &follow.TargetType, &follow.TargetID, &follow.Notify, &follow.CreatedAt, &inserted,
+		)
+		if err != nil {
+			log.Printf("❌ Error following %s %d: %v", req.TargetType, req.TargetID, err)
+			sendErrorResponse(w, "Ошибка подписки: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Уведомляем пользователя о новом подписчике (только при первой подписке)
+		if inserted && req.TargetType == models.FollowTargetUser {
+			var followerName string
+			db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), userID).Scan(&followerName)
+
+			notifHandler := &NotificationsHandler{DB: db}
+			message := fmt.Sprintf("%s подписался на вас", followerName)
+			if err := notifHandler.CreateNotification(req.TargetID, userID, "follow", "user", userID, message); err != nil {
+				log.Printf("⚠️ Failed to notify user %d about follower: %v", req.TargetID, err)
+			}
+		}
+
+		log.Printf("✅ User %d follows %s %d (notify=%v)", userID, req.TargetType, req.TargetID, notify)
+		sendSuccessResponse(w, follow)
+	}
+}
+
+// UnfollowHandler - отписаться
+// POST /api/follows/unfollow {"target_type": "user", "target_id": 7}
+func UnfollowHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(int)
+
+		var req models.FollowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(ConvertPlaceholders(`
+			DELETE FROM follows WHERE follower_id = ? AND target_type = ? AND target_id = ?
+		`), userID, req.TargetType, req.TargetID)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка отписки: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			sendErrorResponse(w, "Подписка не найдена", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("✅ User %d unfollowed %s %d", userID, req.TargetType, req.TargetID)
+		sendSuccessResponse(w, map[string]string{"message": "Подписка отменена"})
+	}
+}
+
+// FollowStatusHandler - подписан ли текущий пользователь на объект
+// GET /api/follows/status?target_type=organization&target_id=5
+func FollowStatusHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(int)
+
+		targetType := r.URL.Query().Get("target_type")
+		targetID, err := strconv.Atoi(r.URL.Query().Get("target_id"))
+		if err != nil || !isFollowTargetType(targetType) {
+			sendErrorResponse(w, "Укажите target_type и target_id", http.StatusBadRequest)
+			return
+		}
+
+		following := false
+		notify := false
+		err = db.QueryRow(ConvertPlaceholders(`
+			SELECT notify FROM follows WHERE follower_id = ? AND target_type = ? AND target_id = ?
+		`), userID, targetType, targetID).Scan(&notify)
+		if err == nil {
+			following = true
+		}
+
+		sendSuccessResponse(w, map[string]interface{}{
+			"following":       following,
+			"notify":          notify,
+			"followers_count": countFollowers(db, targetType, targetID),
+		})
+	}
+}
+
+// FollowersHandler - подписчики пользователя или организации
+// GET /api/follows/followers?target_type=organization&target_id=5&cursor=...
+func FollowersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		targetType := r.URL.Query().Get("target_type")
+		targetID, err := strconv.Atoi(r.URL.Query().Get("target_id"))
+		if err != nil || !isFollowTargetType(targetType) {
+			sendErrorResponse(w, "Укажите target_type и target_id", http.StatusBadRequest)
+			return
+		}
+
+		limit, cursor, err := parseCursorPage(r, 50, 100)
+		if err != nil {
+			sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+			return
+		}
+
+		query := `
+			SELECT f.id, f.follower_id, f.target_type, f.target_id, f.notify, f.created_at,
+			       trim(u.name || ' ' || coalesce(u.last_name, '')), coalesce(u.avatar, '')
+			FROM follows f
+			JOIN users u ON u.id = f.follower_id
+			WHERE f.target_type = ? AND f.target_id = ?
+		`
+		args := []interface{}{targetType, targetID}
+		if cursor != nil {
+			query += ` AND ` + cursorCondition("f.created_at", "f.id", false)
+			args = append(args, cursor.CreatedAt, cursor.ID)
+		}
+		query += ` ORDER BY f.created_at DESC, f.id DESC LIMIT ?`
+		args = append(args, limit+1)
+
+		follows, nextCursor, err := queryFollows(db, query, args, limit)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения подписчиков: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sendPageResponse(w, follows, nextCursor)
+	}
+}
+
+// FollowingHandler - на кого подписан пользователь (по умолчанию текущий)
+// GET /api/follows/following?user_id=7&target_type=organization&cursor=...
+func FollowingHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value("userID").(int)
+		if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+			id, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				sendErrorResponse(w, "Неверный user_id", http.StatusBadRequest)
+				return
+			}
+			userID = id
+		}
+
+		limit, cursor, err := parseCursorPage(r, 50, 100)
+		if err != nil {
+			sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+			return
+		}
+
+		query := `
+			SELECT f.id, f.follower_id, f.target_type, f.target_id, f.notify, f.created_at,
+			       coalesce(trim(u.name || ' ' || coalesce(u.last_name, '')), o.name, ''),
+			       coalesce(u.avatar, o.logo, '')
+			FROM follows f
+			LEFT JOIN users u ON f.target_type = 'user' AND u.id = f.target_id
+			LEFT JOIN organizations o ON f.target_type = 'organization' AND o.id = f.target_id
+			WHERE f.follower_id = ?
+		`
+		args := []interface{}{userID}
+		if targetType := r.URL.Query().Get("target_type"); targetType != "" {
+			query += ` AND f.target_type = ?`
+			args = append(args, targetType)
+		}
+		if cursor != nil {
+			query += ` AND ` + cursorCondition("f.created_at", "f.id", false)
+			args = append(args, cursor.CreatedAt, cursor.ID)
+		}
+		query += ` ORDER BY f.created_at DESC, f.id DESC LIMIT ?`
+		args = append(args, limit+1)
+
+		follows, nextCursor, err := queryFollows(db, query, args, limit)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка получения подписок: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sendPageResponse(w, follows, nextCursor)
+	}
+}
+
+func queryFollows(db *sql.DB, query string, args []interface{}, limit int) ([]models.Follow, string, error) {
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	follows := []models.Follow{}
+	for rows.Next() {
+		var f models.Follow
+		if err := rows.Scan(&f.ID, &f.FollowerID, &f.TargetType, &f.TargetID, &f.Notify, &f.CreatedAt, &f.Name, &f.Avatar); err != nil {
+			log.Printf("❌ Error scanning follow: %v", err)
+			continue
+		}
+		follows = append(follows, f)
+	}
+
+	nextCursor := ""
+	if len(follows) > limit {
+		follows = follows[:limit]
+		last := follows[len(follows)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return follows, nextCursor, rows.Err()
+}
+
+func isFollowTargetType(targetType string) bool {
+	return targetType == models.FollowTargetUser || targetType == models.FollowTargetOrganization
+}
+
+// validateFollowTarget проверяет, что объект подписки существует и это не сам пользователь
+func validateFollowTarget(db *sql.DB, userID int, targetType string, targetID int) error {
+	if !isFollowTargetType(targetType) || targetID == 0 {
+		return fmt.Errorf("Укажите target_type (user или organization) и target_id")
+	}
+
+	var exists bool
+	switch targetType {
+	case models.FollowTargetUser:
+		if targetID == userID {
+			return fmt.Errorf("Нельзя подписаться на самого себя")
+		}
+		db.QueryRow(ConvertPlaceholders("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)"), targetID).Scan(&exists)
+	case models.FollowTargetOrganization:
+		db.QueryRow(ConvertPlaceholders("SELECT EXISTS(SELECT 1 FROM organizations WHERE id = ? AND status = 'active')"), targetID).Scan(&exists)
+	}
+
+	if !exists {
+		return fmt.Errorf("Объект подписки не найден")
+	}
+	return nil
+}
+
+// countFollowers - количество подписчиков пользователя или организации
+func countFollowers(db *sql.DB, targetType string, targetID int) int {
+	var count int
+	db.QueryRow(ConvertPlaceholders(`
+		SELECT COUNT(*) FROM follows WHERE target_type = ? AND target_id = ?
+	`), targetType, targetID).Scan(&count)
+	return count
+}
+
+// getFollowCounts - счётчики для профиля пользователя
+func getFollowCounts(db *sql.DB, userID int) models.FollowCounts {
+	counts := models.FollowCounts{FollowersCount: countFollowers(db, models.FollowTargetUser, userID)}
+	db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM follows WHERE follower_id = ?"), userID).Scan(&counts.FollowingCount)
+	return counts
+}
+
+// notifyFollowersAboutPost рассылает уведомление о новом посте организации
+// подписчикам, включившим notify. Посты пользователей уведомлений не создают.
+// actorID - пользователь, опубликовавший пост (для отложенных постов - владелец организации).
+func notifyFollowersAboutPost(db *sql.DB, postID int, authorType string, authorID, actorID int) {
+	if authorType != models.FollowTargetOrganization {
+		return
+	}
+
+	var authorName string
+	var ownerID sql.NullInt64
+	db.QueryRow(ConvertPlaceholders("SELECT name, owner_user_id FROM organizations WHERE id = ?"), authorID).Scan(&authorName, &ownerID)
+	if actorID == 0 && ownerID.Valid {
+		actorID = int(ownerID.Int64)
+	}
+	if actorID == 0 {
+		return
+	}
+
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT follower_id FROM follows
+		WHERE target_type = ? AND target_id = ? AND notify = TRUE
+	`), authorType, authorID)
+	if err != nil {
+		log.Printf("❌ Failed to load followers of %s %d: %v", authorType, authorID, err)
+		return
+	}
+
+	followerIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			followerIDs = append(followerIDs, id)
+		}
+	}
+	rows.Close()
+
+	notifHandler := &NotificationsHandler{DB: db}
+	message := fmt.Sprintf("%s опубликовал новый пост", authorName)
+	for _, followerID := range followerIDs {
+		if err := notifHandler.CreateNotification(followerID, actorID, "followed_post", "post", postID, message); err != nil {
+			log.Printf("⚠️ Failed to notify follower %d about post %d: %v", followerID, postID, err)
+		}
+	}
+
+	if len(followerIDs) > 0 {
+		log.Printf("📣 Post %d: notified %d followers of %s %d", postID, len(followerIDs), authorType, authorID)
+	}
+}
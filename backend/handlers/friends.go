@@ -280,15 +280,11 @@ func GetFriendsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем всех друзей (где статус accepted) + проверяем онлайн статус
+	// Получаем всех друзей (где статус accepted), онлайн статус берётся из WebSocket hub
 	query := convertPlaceholdersFriends(`
 		SELECT f.id, f.user_id, f.friend_id, f.status, f.created_at, f.updated_at,
 		       u.id, u.name, u.last_name, u.email, u.avatar, u.location,
-		       ua.last_seen,
-		       CASE 
-		           WHEN ua.last_seen IS NOT NULL AND ua.last_seen > NOW() - INTERVAL '5 minutes' THEN 1
-		           ELSE 0
-		       END as is_online
+		       ua.last_seen
 		FROM friendships f
 		JOIN users u ON (
 			CASE 
@@ -318,18 +314,16 @@ func GetFriendsHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var fr models.FriendshipResponse
 		var friend models.UserResponse
-		var isOnline int
 		var lastSeen sql.NullTime
 		err := rows.Scan(
 			&fr.ID, &fr.UserID, &fr.FriendID, &fr.Status, &fr.CreatedAt, &fr.UpdatedAt,
 			&friend.ID, &friend.Name, &friend.LastName, &friend.Email, &friend.Avatar, &friend.Location,
 			&lastSeen,
-			&isOnline,
 		)
 		if err != nil {
 			continue
 		}
-		friend.IsOnline = (isOnline == 1)
+		friend.IsOnline = IsUserOnline(friend.ID)
 		if lastSeen.Valid {
 			friend.LastSeen = &lastSeen.Time
 		}
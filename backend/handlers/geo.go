@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	earthRadiusKm       = 6371.0
+	defaultGeoRadiusKm  = 10.0
+	maxGeoRadiusKm      = 500.0
+	kmPerDegreeLatitude = 111.32
+)
+
+// geoQuery - параметры поиска "в радиусе N км от точки"
+type geoQuery struct {
+	Lat      float64
+	Lon      float64
+	RadiusKm float64
+}
+
+// parseGeoQuery читает lat, lon и radius_km из query string.
+// Возвращает nil, если координаты не переданы.
+func parseGeoQuery(r *http.Request) (*geoQuery, error) {
+	latStr := r.URL.Query().Get("lat")
+	lonStr := r.URL.Query().Get("lon")
+	if latStr == "" && lonStr == "" {
+		return nil, nil
+	}
+
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, fmt.Errorf("invalid lat")
+	}
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("invalid lon")
+	}
+
+	radius := defaultGeoRadiusKm
+	if radiusStr := r.URL.Query().Get("radius_km"); radiusStr != "" {
+		radius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("invalid radius_km")
+		}
+		if radius > maxGeoRadiusKm {
+			radius = maxGeoRadiusKm
+		}
+	}
+
+	return &geoQuery{Lat: lat, Lon: lon, RadiusKm: radius}, nil
+}
+
+// lonRange - диапазон долготы [Min, Max] в градусах
+type lonRange struct {
+	Min, Max float64
+}
+
+// boundingBox возвращает прямоугольник, заведомо содержащий круг поиска.
+// Используется как предфильтр по индексу (lat, lon) перед точным расчётом расстояния.
+// Если круг пересекает меридиан ±180° (Чукотка), долгота задаётся двумя диапазонами.
+func (g *geoQuery) boundingBox() (minLat, maxLat float64, lons []lonRange) {
+	deltaLat := g.RadiusKm / kmPerDegreeLatitude
+	minLat = math.Max(g.Lat-deltaLat, -90)
+	maxLat = math.Min(g.Lat+deltaLat, 90)
+
+	// Около полюсов долгота вырождается - берём весь диапазон
+	cosLat := math.Cos(g.Lat * math.Pi / 180)
+	if cosLat < 0.01 {
+		return minLat, maxLat, []lonRange{{-180, 180}}
+	}
+	deltaLon := g.RadiusKm / (kmPerDegreeLatitude * cosLat)
+	if deltaLon >= 180 {
+		return minLat, maxLat, []lonRange{{-180, 180}}
+	}
+
+	minLon, maxLon := g.Lon-deltaLon, g.Lon+deltaLon
+	switch {
+	case minLon < -180:
+		return minLat, maxLat, []lonRange{{minLon + 360, 180}, {-180, maxLon}}
+	case maxLon > 180:
+		return minLat, maxLat, []lonRange{{minLon, 180}, {-180, maxLon - 360}}
+	}
+	return minLat, maxLat, []lonRange{{minLon, maxLon}}
+}
+
+// boxCondition - условие "точка в boundingBox" для колонок широты и долготы и его параметры
+func (g *geoQuery) boxCondition(latColumn, lonColumn string) (string, []interface{}) {
+	minLat, maxLat, lons := g.boundingBox()
+	args := []interface{}{minLat, maxLat}
+	lonConditions := make([]string, len(lons))
+	for i, r := range lons {
+		lonConditions[i] = lonColumn + " BETWEEN ? AND ?"
+		args = append(args, r.Min, r.Max)
+	}
+	return fmt.Sprintf("%s BETWEEN ? AND ? AND (%s)", latColumn, strings.Join(lonConditions, " OR ")), args
+}
+
+// distanceSQL - расстояние в км по формуле гаверсинусов.
+// Параметры: lat, lat, lon (точка поиска).
+func distanceSQL(latColumn, lonColumn string) string {
+	return fmt.Sprintf(`(2 * %[3]g * asin(sqrt(
+		power(sin(radians(%[1]s - ?) / 2), 2) +
+		cos(radians(?)) * cos(radians(%[1]s)) * power(sin(radians(%[2]s - ?) / 2), 2)
+	)))`, latColumn, lonColumn, earthRadiusKm)
+}
+
+// parseCoordinates разбирает строку координат вида "55.7558, 37.6173",
+// "55.7558 37.6173" или "[55.7558;37.6173]" (сначала широта, потом долгота)
+func parseCoordinates(s string) (lat, lon *float64) {
+	s = strings.Trim(strings.TrimSpace(s), "[]()")
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+	if len(parts) != 2 {
+		return nil, nil
+	}
+
+	la, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || la < -90 || la > 90 {
+		return nil, nil
+	}
+	lo, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || lo < -180 || lo > 180 {
+		return nil, nil
+	}
+
+	return &la, &lo
+}
@@ -0,0 +1,885 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения групповых чатов
+const (
+	maxGroupMembers     = 500
+	maxGroupTitleLength = 255
+)
+
+// chatInfo - тип чата и связанная организация
+type chatInfo struct {
+	Type           string
+	OrganizationID *int
+	LastMessageID  int
+}
+
+func getChatInfo(db *sql.DB, chatID int) (*chatInfo, error) {
+	var info chatInfo
+	var orgID, lastMessageID sql.NullInt64
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT type, organization_id, last_message_id FROM chats WHERE id = ?
+	`), chatID).Scan(&info.Type, &orgID, &lastMessageID)
+	if err != nil {
+		return nil, err
+	}
+	info.OrganizationID = nullIntPtr(orgID)
+	info.LastMessageID = int(lastMessageID.Int64)
+	return &info, nil
+}
+
+// getChatMemberRole возвращает роль участника группы (sql.ErrNoRows - не участник)
+func getChatMemberRole(db *sql.DB, chatID, userID int) (string, error) {
+	var role string
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT role FROM chat_members WHERE chat_id = ? AND user_id = ?
+	`), chatID, userID).Scan(&role)
+	return role, err
+}
+
+func canManageChat(role string) bool {
+	return role == models.ChatRoleOwner || role == models.ChatRoleAdmin
+}
+
+// chatMemberIDs возвращает участников группового чата
+func chatMemberIDs(db *sql.DB, chatID int) ([]int, error) {
+	rows, err := db.Query(ConvertPlaceholders("SELECT user_id FROM chat_members WHERE chat_id = ?"), chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// canAddToChat - можно ли добавить пользователя в группу (настройка allow_messages)
+func canAddToChat(db *sql.DB, inviterID, userID int) bool {
+	var allowMessages sql.NullString
+	err := db.QueryRow(ConvertPlaceholders("SELECT allow_messages FROM users WHERE id = ?"), userID).Scan(&allowMessages)
+	if err != nil {
+		return false
+	}
+
+	switch allowMessages.String {
+	case "nobody":
+		return false
+	case "friends":
+		var friends bool
+		db.QueryRow(ConvertPlaceholders(`
+			SELECT EXISTS(
+				SELECT 1 FROM friendships
+				WHERE status = 'accepted'
+				  AND ((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?))
+			)
+		`), inviterID, userID, userID, inviterID).Scan(&friends)
+		return friends
+	}
+	return true
+}
+
+// notifyChatMembers отправляет событие всем участникам группы
+func notifyChatMembers(db *sql.DB, chatID int, eventType string, data interface{}) {
+	memberIDs, err := chatMemberIDs(db, chatID)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load members of chat %d: %v", chatID, err)
+		return
+	}
+	for _, memberID := range memberIDs {
+		SendToUser(memberID, eventType, data)
+	}
+}
+
+// addChatMember добавляет участника в группу. Новый участник начинает с текущего
+// последнего сообщения: история видна, но не считается непрочитанной.
+// Возвращает false, если пользователь уже в группе.
+func addChatMember(db *sql.DB, chatID, userID int, role string, addedBy *int) (bool, error) {
+	var added int
+	err := db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO chat_members (chat_id, user_id, role, added_by, joined_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, user_id) DO NOTHING
+		RETURNING user_id
+	`), chatID, userID, role, addedBy, time.Now()).Scan(&added)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.Exec(ConvertPlaceholders(`
+		INSERT INTO chat_read_cursors (chat_id, user_id, last_read_message_id, read_at, last_delivered_message_id)
+		SELECT id, ?, last_message_id, ?, last_message_id FROM chats WHERE id = ? AND last_message_id IS NOT NULL
+		ON CONFLICT (chat_id, user_id) DO UPDATE SET
+			last_read_message_id = GREATEST(chat_read_cursors.last_read_message_id, excluded.last_read_message_id),
+			last_delivered_message_id = GREATEST(chat_read_cursors.last_delivered_message_id, excluded.last_delivered_message_id)
+	`), userID, time.Now(), chatID)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to init read cursor for user %d in chat %d: %v", userID, chatID, err)
+	}
+	return true, nil
+}
+
+// removeChatMember удаляет участника из группы. Если ушёл последний владелец,
+// владельцем становится самый давний админ (или участник).
+func removeChatMember(db *sql.DB, chatID, userID int) error {
+	var role string
+	err := db.QueryRow(ConvertPlaceholders(`
+		DELETE FROM chat_members WHERE chat_id = ? AND user_id = ? RETURNING role
+	`), chatID, userID).Scan(&role)
+	if err != nil {
+		return err
+	}
+	db.Exec(ConvertPlaceholders("DELETE FROM chat_read_cursors WHERE chat_id = ? AND user_id = ?"), chatID, userID)
+
+	if role != models.ChatRoleOwner {
+		return nil
+	}
+
+	var newOwnerID int
+	err = db.QueryRow(ConvertPlaceholders(`
+		UPDATE chat_members SET role = 'owner'
+		WHERE chat_id = ? AND user_id = (
+			SELECT user_id FROM chat_members
+			WHERE chat_id = ?
+			  AND NOT EXISTS (SELECT 1 FROM chat_members WHERE chat_id = ? AND role = 'owner')
+			ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at
+			LIMIT 1
+		)
+		RETURNING user_id
+	`), chatID, chatID, chatID).Scan(&newOwnerID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	notifyChatMembers(db, chatID, "chat_member_role_changed", map[string]interface{}{
+		"chat_id": chatID,
+		"user_id": newOwnerID,
+		"role":    models.ChatRoleOwner,
+	})
+	return nil
+}
+
+// getChatMembers возвращает участников группы с профилями
+func getChatMembers(db *sql.DB, chatID int) ([]models.ChatMember, error) {
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT cm.chat_id, cm.user_id, cm.role, cm.added_by, cm.joined_at,
+		       u.name, u.last_name, u.avatar
+		FROM chat_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.chat_id = ?
+		ORDER BY CASE cm.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, cm.joined_at
+	`), chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ChatMember{}
+	for rows.Next() {
+		var member models.ChatMember
+		var addedBy sql.NullInt64
+		var lastName, avatar sql.NullString
+		user := &models.User{}
+		err := rows.Scan(
+			&member.ChatID, &member.UserID, &member.Role, &addedBy, &member.JoinedAt,
+			&user.Name, &lastName, &avatar,
+		)
+		if err != nil {
+			return nil, err
+		}
+		member.AddedBy = nullIntPtr(addedBy)
+		user.ID = member.UserID
+		user.LastName = lastName.String
+		user.Avatar = avatar.String
+		user.IsOnline = IsUserOnline(user.ID)
+		member.User = user
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// getGroupChats возвращает групповые чаты пользователя (chatID = 0 - все)
+func getGroupChats(db *sql.DB, userID, chatID int) ([]models.Chat, error) {
+	query := `
+		SELECT c.id, c.title, c.avatar, c.organization_id,
+		       c.last_message_id, c.last_message_at, c.created_at,
+		       me.role,
+		       (SELECT COUNT(*) FROM chat_members WHERE chat_id = c.id) AS members_count,
+		       m.id, m.sender_id, m.content, m.created_at,
+		       (SELECT COUNT(*) FROM messages um
+		         WHERE um.chat_id = c.id AND um.sender_id <> me.user_id
+		           AND um.id > COALESCE(rc.last_read_message_id, 0)) AS unread_count
+		FROM chats c
+		JOIN chat_members me ON me.chat_id = c.id AND me.user_id = ?
+		LEFT JOIN chat_read_cursors rc ON rc.chat_id = c.id AND rc.user_id = me.user_id
+		LEFT JOIN messages m ON m.id = c.last_message_id
+		WHERE c.type = 'group'
+	`
+	args := []interface{}{userID}
+	if chatID > 0 {
+		query += ` AND c.id = ?`
+		args = append(args, chatID)
+	}
+
+	rows, err := db.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []models.Chat
+	for rows.Next() {
+		chat := models.Chat{Type: models.ChatTypeGroup}
+		var title, avatar sql.NullString
+		var orgID, msgID, msgSenderID sql.NullInt64
+		var msgContent sql.NullString
+		var msgCreatedAt sql.NullTime
+
+		err := rows.Scan(
+			&chat.ID, &title, &avatar, &orgID,
+			&chat.LastMessageID, &chat.LastMessageAt, &chat.CreatedAt,
+			&chat.MyRole, &chat.MembersCount,
+			&msgID, &msgSenderID, &msgContent, &msgCreatedAt,
+			&chat.UnreadCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		chat.Title = title.String
+		chat.Avatar = avatar.String
+		chat.OrganizationID = nullIntPtr(orgID)
+
+		if msgID.Valid {
+			lastMessage := models.Message{
+				ID:       int(msgID.Int64),
+				ChatID:   chat.ID,
+				SenderID: int(msgSenderID.Int64),
+				Content:  msgContent.String,
+				// Доставку и прочтение уточняет applyGroupReadStatus по курсорам участников
+				Status: models.MessageStatusSent,
+			}
+			if msgCreatedAt.Valid {
+				lastMessage.CreatedAt = &msgCreatedAt.Time
+			}
+			chat.LastMessage = &lastMessage
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// sortChatsByActivity сортирует чаты как в GetChatsHandler: по последнему
+// сообщению, чаты без сообщений - в конце по дате создания
+func sortChatsByActivity(chats []models.Chat) {
+	sort.SliceStable(chats, func(i, j int) bool {
+		a, b := chats[i].LastMessageAt, chats[j].LastMessageAt
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil && !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return chats[i].CreatedAt.After(chats[j].CreatedAt)
+	})
+}
+
+// validateGroupTitle проверяет название группы
+func validateGroupTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", fmt.Errorf("Title is required")
+	}
+	if utf8.RuneCountInString(title) > maxGroupTitleLength {
+		return "", fmt.Errorf("Title is too long (max %d characters)", maxGroupTitleLength)
+	}
+	return title, nil
+}
+
+// CreateGroupChatHandler создаёт групповой чат. Создатель становится владельцем.
+// POST /api/chats/groups {"title": "...", "member_ids": [2, 3]}
+func CreateGroupChatHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req models.CreateGroupChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		title, err := validateGroupTitle(req.Title)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memberIDs, status, err := validateNewMembers(db, userID, req.MemberIDs, 1)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), status)
+			return
+		}
+
+		var chatID int
+		err = db.QueryRow(ConvertPlaceholders(`
+			INSERT INTO chats (type, title, created_by, created_at)
+			VALUES ('group', ?, ?, ?)
+			RETURNING id
+		`), title, userID, time.Now()).Scan(&chatID)
+		if err != nil {
+			log.Printf("❌ Error creating group chat: %v", err)
+			sendErrorResponse(w, "Failed to create chat", http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := addChatMember(db, chatID, userID, models.ChatRoleOwner, nil); err != nil {
+			log.Printf("❌ Error adding owner to chat %d: %v", chatID, err)
+			db.Exec(ConvertPlaceholders("DELETE FROM chats WHERE id = ?"), chatID)
+			sendErrorResponse(w, "Failed to create chat", http.StatusInternalServerError)
+			return
+		}
+		for _, memberID := range memberIDs {
+			if _, err := addChatMember(db, chatID, memberID, models.ChatRoleMember, &userID); err != nil {
+				log.Printf("⚠️ Warning: Failed to add user %d to chat %d: %v", memberID, chatID, err)
+			}
+		}
+
+		log.Printf("✅ Group chat %d created by user %d (%d members)", chatID, userID, len(memberIDs)+1)
+
+		// Участники видят новый чат без перезагрузки списка
+		notifyChatMembers(db, chatID, "chat_added", map[string]int{"chat_id": chatID})
+
+		chats, err := getGroupChats(db, userID, chatID)
+		if err != nil || len(chats) == 0 {
+			sendSuccessResponse(w, map[string]int{"id": chatID})
+			return
+		}
+		sendSuccessResponse(w, chats[0])
+	}
+}
+
+// validateNewMembers убирает повторы и самого пользователя, проверяет существование
+// пользователей, их настройку allow_messages и лимит участников
+func validateNewMembers(db *sql.DB, inviterID int, userIDs []int, currentCount int) ([]int, int, error) {
+	seen := map[int]bool{inviterID: true}
+	var memberIDs []int
+	for _, id := range userIDs {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		memberIDs = append(memberIDs, id)
+	}
+
+	if currentCount+len(memberIDs) > maxGroupMembers {
+		return nil, http.StatusBadRequest, fmt.Errorf("Too many members (max %d)", maxGroupMembers)
+	}
+	for _, id := range memberIDs {
+		exists, err := userExists(db, id)
+		if err != nil || !exists {
+			return nil, http.StatusNotFound, fmt.Errorf("User %d not found", id)
+		}
+		if !canAddToChat(db, inviterID, id) {
+			return nil, http.StatusForbidden, fmt.Errorf("User %d does not accept invitations", id)
+		}
+	}
+	return memberIDs, http.StatusOK, nil
+}
+
+// parseGroupChatPath разбирает /api/chats/{id}/... и проверяет, что чат групповой
+// и пользователь в нём состоит
+func parseGroupChatPath(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) (chatID int, parts []string, info *chatInfo, role string, ok bool) {
+	parts = strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 {
+		sendErrorResponse(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	chatID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		sendErrorResponse(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	info, err = getChatInfo(db, chatID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Error loading chat %d: %v", chatID, err)
+		sendErrorResponse(w, "Failed to load chat", http.StatusInternalServerError)
+		return
+	}
+	if info.Type != models.ChatTypeGroup {
+		sendErrorResponse(w, "Not a group chat", http.StatusBadRequest)
+		return
+	}
+
+	role, err = getChatMemberRole(db, chatID, userID)
+	if err != nil {
+		sendErrorResponse(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	return chatID, parts, info, role, true
+}
+
+// UpdateGroupChatHandler меняет название группы (владелец и админы)
+// PATCH /api/chats/{id} {"title": "..."}
+func UpdateGroupChatHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		chatID, _, _, role, ok := parseGroupChatPath(w, r, db, userID)
+		if !ok {
+			return
+		}
+		if !canManageChat(role) {
+			sendErrorResponse(w, "Only chat admins can edit the chat", http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		title, err := validateGroupTitle(req.Title)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.Exec(ConvertPlaceholders("UPDATE chats SET title = ? WHERE id = ?"), title, chatID); err != nil {
+			log.Printf("❌ Error updating chat %d: %v", chatID, err)
+			sendErrorResponse(w, "Failed to update chat", http.StatusInternalServerError)
+			return
+		}
+
+		notifyChatMembers(db, chatID, "chat_updated", map[string]interface{}{
+			"chat_id": chatID,
+			"title":   title,
+		})
+		sendSuccessResponse(w, map[string]interface{}{"id": chatID, "title": title})
+	}
+}
+
+// ChatMembersHandler - участники группы:
+// GET    /api/chats/{id}/members           - список
+// POST   /api/chats/{id}/members           - пригласить {"user_ids": [..]} (владелец и админы)
+// PATCH  /api/chats/{id}/members/{userId}  - роль {"role": "admin|member|owner"} (владелец)
+// DELETE /api/chats/{id}/members/{userId}  - исключить (админы) или выйти (свой ID)
+func ChatMembersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		chatID, parts, info, role, ok := parseGroupChatPath(w, r, db, userID)
+		if !ok {
+			return
+		}
+
+		if len(parts) > 5 {
+			sendErrorResponse(w, "Not found", http.StatusNotFound)
+			return
+		}
+		targetID := 0
+		if len(parts) == 5 {
+			id, err := strconv.Atoi(parts[4])
+			if err != nil {
+				sendErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+			targetID = id
+		}
+
+		if r.Method == http.MethodGet && targetID == 0 {
+			members, err := getChatMembers(db, chatID)
+			if err != nil {
+				log.Printf("❌ Error fetching members of chat %d: %v", chatID, err)
+				sendErrorResponse(w, "Failed to fetch members", http.StatusInternalServerError)
+				return
+			}
+			sendSuccessResponse(w, members)
+			return
+		}
+
+		// Составом чата организации управляет организация
+		if info.OrganizationID != nil {
+			sendErrorResponse(w, "Members of an organization chat are managed by the organization", http.StatusConflict)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && targetID == 0:
+			inviteChatMembers(w, r, db, chatID, userID, role)
+		case r.Method == http.MethodPatch && targetID != 0:
+			changeChatMemberRole(w, r, db, chatID, userID, role, targetID)
+		case r.Method == http.MethodDelete && targetID == userID:
+			leaveChat(w, db, chatID, userID)
+		case r.Method == http.MethodDelete && targetID != 0:
+			kickChatMember(w, db, chatID, userID, role, targetID)
+		default:
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// LeaveChatHandler - выход из группы: POST /api/chats/{id}/leave
+func LeaveChatHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		chatID, _, info, _, ok := parseGroupChatPath(w, r, db, userID)
+		if !ok {
+			return
+		}
+		if info.OrganizationID != nil {
+			sendErrorResponse(w, "Members of an organization chat are managed by the organization", http.StatusConflict)
+			return
+		}
+		leaveChat(w, db, chatID, userID)
+	}
+}
+
+func inviteChatMembers(w http.ResponseWriter, r *http.Request, db *sql.DB, chatID, userID int, role string) {
+	if !canManageChat(role) {
+		sendErrorResponse(w, "Only chat admins can invite members", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		sendErrorResponse(w, "user_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	var count int
+	db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM chat_members WHERE chat_id = ?"), chatID).Scan(&count)
+	memberIDs, status, err := validateNewMembers(db, userID, req.UserIDs, count)
+	if err != nil {
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+
+	added := []int{}
+	for _, memberID := range memberIDs {
+		ok, err := addChatMember(db, chatID, memberID, models.ChatRoleMember, &userID)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to add user %d to chat %d: %v", memberID, chatID, err)
+			continue
+		}
+		if ok {
+			added = append(added, memberID)
+		}
+	}
+
+	if len(added) > 0 {
+		log.Printf("✅ User %d invited %v to chat %d", userID, added, chatID)
+		notifyChatMembers(db, chatID, "chat_member_added", map[string]interface{}{
+			"chat_id":  chatID,
+			"user_ids": added,
+			"added_by": userID,
+		})
+	}
+	sendSuccessResponse(w, map[string]interface{}{"chat_id": chatID, "added": added})
+}
+
+func changeChatMemberRole(w http.ResponseWriter, r *http.Request, db *sql.DB, chatID, userID int, role string, targetID int) {
+	if role != models.ChatRoleOwner {
+		sendErrorResponse(w, "Only the chat owner can change roles", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != models.ChatRoleOwner && req.Role != models.ChatRoleAdmin && req.Role != models.ChatRoleMember {
+		sendErrorResponse(w, "Role must be owner, admin or member", http.StatusBadRequest)
+		return
+	}
+	if targetID == userID {
+		sendErrorResponse(w, "Transfer ownership to another member instead", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec(ConvertPlaceholders(`
+		UPDATE chat_members SET role = ? WHERE chat_id = ? AND user_id = ?
+	`), req.Role, chatID, targetID)
+	if err != nil {
+		log.Printf("❌ Error changing role in chat %d: %v", chatID, err)
+		sendErrorResponse(w, "Failed to change role", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		sendErrorResponse(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	changes := map[int]string{targetID: req.Role}
+	// Передача владения: прежний владелец становится админом
+	if req.Role == models.ChatRoleOwner {
+		db.Exec(ConvertPlaceholders(`
+			UPDATE chat_members SET role = 'admin' WHERE chat_id = ? AND user_id = ?
+		`), chatID, userID)
+		changes[userID] = models.ChatRoleAdmin
+	}
+
+	for memberID, newRole := range changes {
+		notifyChatMembers(db, chatID, "chat_member_role_changed", map[string]interface{}{
+			"chat_id": chatID,
+			"user_id": memberID,
+			"role":    newRole,
+		})
+	}
+	sendSuccessResponse(w, map[string]interface{}{"chat_id": chatID, "user_id": targetID, "role": req.Role})
+}
+
+func kickChatMember(w http.ResponseWriter, db *sql.DB, chatID, userID int, role string, targetID int) {
+	if !canManageChat(role) {
+		sendErrorResponse(w, "Only chat admins can remove members", http.StatusForbidden)
+		return
+	}
+
+	targetRole, err := getChatMemberRole(db, chatID, targetID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	// Владельца исключить нельзя, админов исключает только владелец
+	if targetRole == models.ChatRoleOwner || (targetRole == models.ChatRoleAdmin && role != models.ChatRoleOwner) {
+		sendErrorResponse(w, "You can't remove this member", http.StatusForbidden)
+		return
+	}
+
+	if err := removeChatMember(db, chatID, targetID); err != nil {
+		log.Printf("❌ Error removing user %d from chat %d: %v", targetID, chatID, err)
+		sendErrorResponse(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("✅ User %d removed from chat %d by user %d", targetID, chatID, userID)
+
+	event := map[string]interface{}{
+		"chat_id":    chatID,
+		"user_id":    targetID,
+		"removed_by": userID,
+	}
+	notifyMemberRemoved(db, chatID, targetID, event)
+	sendSuccessResponse(w, event)
+}
+
+func leaveChat(w http.ResponseWriter, db *sql.DB, chatID, userID int) {
+	if err := removeChatMember(db, chatID, userID); err != nil {
+		log.Printf("❌ Error leaving chat %d: %v", chatID, err)
+		sendErrorResponse(w, "Failed to leave chat", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("✅ User %d left chat %d", userID, chatID)
+
+	event := map[string]interface{}{
+		"chat_id": chatID,
+		"user_id": userID,
+	}
+	notifyMemberRemoved(db, chatID, userID, event)
+	sendSuccessResponse(w, event)
+}
+
+// notifyMemberRemoved сообщает оставшимся участникам и самому удалённому
+// (его устройства убирают чат из списка)
+func notifyMemberRemoved(db *sql.DB, chatID, userID int, event map[string]interface{}) {
+	notifyChatMembers(db, chatID, "chat_member_removed", event)
+	SendToUser(userID, "chat_member_removed", event)
+}
+
+// orgChatRole - роль в чате организации по роли в организации
+func orgChatRole(orgRole string) string {
+	switch orgRole {
+	case "owner":
+		return models.ChatRoleOwner
+	case "admin":
+		return models.ChatRoleAdmin
+	}
+	return models.ChatRoleMember
+}
+
+// createOrganizationChat создаёт чат команды организации (или возвращает существующий)
+// и добавляет в него всех участников организации
+func createOrganizationChat(db *sql.DB, orgID, createdBy int) (int, error) {
+	var chatID int
+	err := db.QueryRow(ConvertPlaceholders("SELECT id FROM chats WHERE organization_id = ?"), orgID).Scan(&chatID)
+	if err == nil {
+		return chatID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	var name string
+	var logo sql.NullString
+	err = db.QueryRow(ConvertPlaceholders("SELECT name, logo FROM organizations WHERE id = ?"), orgID).Scan(&name, &logo)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.QueryRow(ConvertPlaceholders(`
+		INSERT INTO chats (type, title, avatar, organization_id, created_by, created_at)
+		VALUES ('group', ?, ?, ?, ?, ?)
+		ON CONFLICT (organization_id) WHERE organization_id IS NOT NULL DO NOTHING
+		RETURNING id
+	`), name, logo, orgID, createdBy, time.Now()).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		// Чат создан параллельным запросом, участников добавляет он
+		err = db.QueryRow(ConvertPlaceholders("SELECT id FROM chats WHERE organization_id = ?"), orgID).Scan(&chatID)
+		return chatID, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := syncOrganizationChat(db, orgID); err != nil {
+		return chatID, err
+	}
+	log.Printf("✅ Organization chat %d created for organization %d", chatID, orgID)
+	return chatID, nil
+}
+
+// syncOrganizationChat приводит участников чата организации к organization_members.
+// Вызывается после каждого изменения состава организации; без чата ничего не делает.
+func syncOrganizationChat(db *sql.DB, orgID int) error {
+	var chatID int
+	err := db.QueryRow(ConvertPlaceholders("SELECT id FROM chats WHERE organization_id = ?"), orgID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	loadRoles := func(query string, id int) (map[int]string, error) {
+		rows, err := db.Query(ConvertPlaceholders(query), id)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		roles := map[int]string{}
+		for rows.Next() {
+			var userID int
+			var role sql.NullString
+			if err := rows.Scan(&userID, &role); err != nil {
+				return nil, err
+			}
+			roles[userID] = role.String
+		}
+		return roles, rows.Err()
+	}
+
+	orgMembers, err := loadRoles("SELECT user_id, role FROM organization_members WHERE organization_id = ?", orgID)
+	if err != nil {
+		return err
+	}
+	chatMembers, err := loadRoles("SELECT user_id, role FROM chat_members WHERE chat_id = ?", chatID)
+	if err != nil {
+		return err
+	}
+
+	var added []int
+	for userID, orgRole := range orgMembers {
+		role := orgChatRole(orgRole)
+		current, ok := chatMembers[userID]
+		if !ok {
+			if _, err := addChatMember(db, chatID, userID, role, nil); err != nil {
+				log.Printf("⚠️ Warning: Failed to add user %d to organization chat %d: %v", userID, chatID, err)
+				continue
+			}
+			added = append(added, userID)
+			continue
+		}
+		if current != role {
+			db.Exec(ConvertPlaceholders(`
+				UPDATE chat_members SET role = ? WHERE chat_id = ? AND user_id = ?
+			`), role, chatID, userID)
+			notifyChatMembers(db, chatID, "chat_member_role_changed", map[string]interface{}{
+				"chat_id": chatID,
+				"user_id": userID,
+				"role":    role,
+			})
+		}
+	}
+	if len(added) > 0 {
+		notifyChatMembers(db, chatID, "chat_member_added", map[string]interface{}{
+			"chat_id":  chatID,
+			"user_ids": added,
+		})
+	}
+
+	for userID := range chatMembers {
+		if _, ok := orgMembers[userID]; ok {
+			continue
+		}
+		// Роли синхронизированы выше, передача владения не нужна
+		db.Exec(ConvertPlaceholders("DELETE FROM chat_members WHERE chat_id = ? AND user_id = ?"), chatID, userID)
+		db.Exec(ConvertPlaceholders("DELETE FROM chat_read_cursors WHERE chat_id = ? AND user_id = ?"), chatID, userID)
+		notifyMemberRemoved(db, chatID, userID, map[string]interface{}{
+			"chat_id": chatID,
+			"user_id": userID,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"backend/imageproc"
+	"backend/models"
+	"backend/storage"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// saveProcessedImage записывает очищенный оригинал ({base}{ext}) и его варианты
+// ({base}_{size}{ext}) в dir. При ошибке уже записанные файлы удаляются.
+func saveProcessedImage(dir, base string, result *imageproc.Result) error {
+	written := []string{}
+	write := func(name string, data []byte) error {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		written = append(written, path)
+		return nil
+	}
+
+	err := write(base+result.OriginalExt, result.Original)
+	for _, v := range result.Variants {
+		if err != nil {
+			break
+		}
+		err = write(imageproc.VariantFileName(base, v.Size, v.Ext), v.Data)
+	}
+
+	if err != nil {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return fmt.Errorf("failed to save image variants: %v", err)
+	}
+	return nil
+}
+
+// putProcessedImage записывает очищенный оригинал (keyDir/{base}{ext}) и его варианты
+// (keyDir/{base}_{size}{ext}) в хранилище. При ошибке уже записанные файлы удаляются.
+func putProcessedImage(s storage.Storage, keyDir, base string, result *imageproc.Result, public bool) error {
+	written := []string{}
+	put := func(name, contentType string, data []byte) error {
+		key := keyDir + "/" + name
+		if err := s.Put(key, bytes.NewReader(data), contentType, public); err != nil {
+			return err
+		}
+		written = append(written, key)
+		return nil
+	}
+
+	err := put(base+result.OriginalExt, result.OriginalMime, result.Original)
+	for _, v := range result.Variants {
+		if err != nil {
+			break
+		}
+		contentType := "image/jpeg"
+		if v.Format == "webp" {
+			contentType = "image/webp"
+		}
+		err = put(imageproc.VariantFileName(base, v.Size, v.Ext), contentType, v.Data)
+	}
+
+	if err != nil {
+		for _, key := range written {
+			s.Delete(key)
+		}
+		return fmt.Errorf("failed to save image variants: %v", err)
+	}
+	return nil
+}
+
+// deleteStoredImage удаляет файл из хранилища вместе с вариантами изображения
+func deleteStoredImage(s storage.Storage, key string) {
+	s.Delete(key)
+	for _, variant := range imageVariantPaths(key) {
+		s.Delete(variant)
+	}
+}
+
+// saveUploadedImage сохраняет обработанное изображение с вариантами,
+// а необработанное (GIF) - копией загруженного файла
+func saveUploadedImage(dir, base, filePath string, file io.Reader, processed *imageproc.Result) error {
+	if processed != nil {
+		return saveProcessedImage(dir, base, processed)
+	}
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+// publishUploadedImage переносит сохранённое в dir изображение и его варианты в
+// текущее хранилище (storage.Default) под ключами keyDir/... и возвращает URL
+// оригинала и вариантов. В локальном хранилище файлы остаются на месте.
+func publishUploadedImage(dir, keyDir, base, fileName string, processed *imageproc.Result) (string, map[string]models.ImageVariant, error) {
+	s := storage.Default()
+	if s.Name() != storage.BackendLocal {
+		names := []string{fileName}
+		if processed != nil {
+			for _, v := range processed.Variants {
+				names = append(names, imageproc.VariantFileName(base, v.Size, v.Ext))
+			}
+		}
+		for _, name := range names {
+			if err := putLocalFile(s, filepath.Join(dir, name), keyDir+"/"+name, true); err != nil {
+				for _, done := range names {
+					s.Delete(keyDir + "/" + done)
+				}
+				return "", nil, err
+			}
+		}
+		for _, name := range names {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return s.URL(keyDir + "/" + fileName), uploadVariants(processed, s.URL(keyDir), base), nil
+}
+
+// storedImageSize - место, занятое сохранённым изображением: оригинал с вариантами
+// или исходный файл, если изображение не перекодировалось (GIF)
+func storedImageSize(processed *imageproc.Result, uploadedSize int64) int64 {
+	if processed != nil {
+		return processed.TotalSize()
+	}
+	return uploadedSize
+}
+
+// putLocalFile копирует локальный файл в хранилище
+func putLocalFile(s storage.Storage, path, key string, public bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return s.Put(key, file, contentType, public)
+}
+
+// putLocalFileAs копирует локальный файл в хранилище как приватный с заданным типом
+// (файлы медиатеки отдаются через /api/media/file)
+func putLocalFileAs(s storage.Storage, path, key, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return s.Put(key, file, contentType, false)
+}
+
+// uploadVariants - варианты для файлов, раздаваемых по постоянным URL (urlDir/{base}_{size}{ext})
+func uploadVariants(processed *imageproc.Result, urlDir, base string) map[string]models.ImageVariant {
+	if processed == nil {
+		return nil
+	}
+	return imageVariants(processed, func(size, _, ext string) string {
+		return urlDir + "/" + imageproc.VariantFileName(base, size, ext)
+	})
+}
+
+// imageVariants собирает описание вариантов; urlFor строит URL файла по размеру и формату
+func imageVariants(result *imageproc.Result, urlFor func(size, format, ext string) string) map[string]models.ImageVariant {
+	variants := map[string]models.ImageVariant{}
+	for _, v := range result.Variants {
+		variant := variants[v.Size]
+		variant.Width, variant.Height = v.Width, v.Height
+		if v.Format == "webp" {
+			variant.WebP = urlFor(v.Size, v.Format, v.Ext)
+		} else {
+			variant.JPEG = urlFor(v.Size, v.Format, v.Ext)
+		}
+		variants[v.Size] = variant
+	}
+	return variants
+}
+
+// mediaVariantURLs дополняет сохранённые в user_media.variants размеры ссылками
+// на /api/media/file/{id}?size=...&format=...
+func mediaVariantURLs(mediaID int, variantsJSON string) map[string]models.ImageVariant {
+	if variantsJSON == "" {
+		return nil
+	}
+
+	variants := map[string]models.ImageVariant{}
+	if err := json.Unmarshal([]byte(variantsJSON), &variants); err != nil || len(variants) == 0 {
+		return nil
+	}
+
+	base := "/api/media/file/" + strconv.Itoa(mediaID)
+	for size, v := range variants {
+		v.WebP = base + "?size=" + size + "&format=webp"
+		v.JPEG = base + "?size=" + size + "&format=jpeg"
+		variants[size] = v
+	}
+	return variants
+}
+
+// variantPath - путь к файлу варианта рядом с оригиналом
+func variantPath(originalPath, size, format string) (string, string, bool) {
+	known := false
+	for _, s := range imageproc.Sizes {
+		if s.Name == size {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", "", false
+	}
+
+	base := strings.TrimSuffix(originalPath, filepath.Ext(originalPath))
+	switch format {
+	case "webp":
+		return imageproc.VariantFileName(base, size, ".webp"), "image/webp", true
+	case "jpeg", "jpg":
+		return imageproc.VariantFileName(base, size, ".jpg"), "image/jpeg", true
+	}
+	return "", "", false
+}
+
+// imageVariantPaths - все возможные пути вариантов оригинала ({base}_{size}.webp|.jpg)
+func imageVariantPaths(originalPath string) []string {
+	base := strings.TrimSuffix(originalPath, filepath.Ext(originalPath))
+	paths := make([]string, 0, len(imageproc.Sizes)*2)
+	for _, s := range imageproc.Sizes {
+		paths = append(paths, imageproc.VariantFileName(base, s.Name, ".webp"), imageproc.VariantFileName(base, s.Name, ".jpg"))
+	}
+	return paths
+}
+
+// removeImageVariants удаляет варианты оригинала ({base}_*)
+func removeImageVariants(originalPath string) {
+	for _, path := range imageVariantPaths(originalPath) {
+		os.Remove(path)
+	}
+}
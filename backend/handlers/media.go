@@ -2,21 +2,25 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"backend/imageproc"
 	"backend/models"
+	"backend/storage"
 
 	"github.com/google/uuid"
 )
@@ -28,6 +32,15 @@ const (
 	OptimizeVideo = true              // Включить оптимизацию видео
 )
 
+// mediaStorage - хранилище новых файлов медиатеки: S3, если он настроен, иначе
+// UploadDir (откуда локальные файлы читают GetMediaFile и воркеры видео)
+func mediaStorage() storage.Storage {
+	if storage.Enabled() {
+		return storage.Default()
+	}
+	return storage.NewLocalStorage(UploadDir)
+}
+
 type MediaHandler struct {
 	DB *sql.DB
 }
@@ -64,6 +77,9 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		mediaType = "photo" // По умолчанию
 	}
 
+	// private=true - файл отдаётся только по подписанной ссылке (медицинские документы и т.п.)
+	isPrivate := r.FormValue("private") == "true"
+
 	fmt.Printf("📋 [UPLOAD] Тип медиа: %s\n", mediaType)
 
 	// Определяем максимальный размер в зависимости от типа
@@ -101,66 +117,81 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("✅ [UPLOAD] MIME тип валиден: %s\n", mimeType)
 
-	// Генерируем уникальное имя файла
-	ext := filepath.Ext(header.Filename)
-	fileName := uuid.New().String() + ext
-
-	// Создаем путь к файлу
-	now := time.Now()
-	relativePath := filepath.Join("users", strconv.Itoa(userID), mediaType+"s",
-		strconv.Itoa(now.Year()), fmt.Sprintf("%02d", now.Month()), fileName)
-	fullPath := filepath.Join(UploadDir, relativePath)
-
-	fmt.Printf("📂 [UPLOAD] Путь сохранения: %s\n", fullPath)
+	// Фото перекодируются: поворот по EXIF, удаление EXIF/GPS, варианты thumb/medium/large.
+	// GIF сохраняется как есть (анимация).
+	var processed *imageproc.Result
+	if mediaType == "photo" {
+		processed, err = imageproc.Process(file)
+		if err != nil && err != imageproc.ErrUnsupported {
+			fmt.Printf("❌ [UPLOAD] Ошибка обработки изображения: %v\n", err)
+			sendErrorResponse(w, "Invalid image", http.StatusBadRequest)
+			return
+		}
+		file.Seek(0, 0)
+	}
 
-	// Создаем директории
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		fmt.Printf("❌ [UPLOAD] Ошибка создания директории: %v\n", err)
-		sendErrorResponse(w, "Failed to create directory", http.StatusInternalServerError)
+	// Резервируем место под всё, что будет сохранено: у фото это оригинал с вариантами
+	releaseQuota, ok := reserveStorageQuota(w, h.DB, userID, storedImageSize(processed, header.Size))
+	if !ok {
 		return
 	}
+	defer releaseQuota()
 
-	fmt.Printf("✅ [UPLOAD] Директория создана\n")
-
-	// Сохраняем файл
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		fmt.Printf("❌ [UPLOAD] Ошибка создания файла: %v\n", err)
-		sendErrorResponse(w, "Failed to save file", http.StatusInternalServerError)
-		return
+	// Генерируем уникальное имя файла
+	baseName := uuid.New().String()
+	ext := filepath.Ext(header.Filename)
+	if processed != nil {
+		ext = processed.OriginalExt
+		mimeType = processed.OriginalMime
 	}
-	defer dst.Close()
+	fileName := baseName + ext
 
-	fileSize, err := io.Copy(dst, file)
-	if err != nil {
-		fmt.Printf("❌ [UPLOAD] Ошибка копирования файла: %v\n", err)
-		os.Remove(fullPath) // Удаляем файл при ошибке
-		sendErrorResponse(w, "Failed to save file", http.StatusInternalServerError)
-		return
+	// Ключ файла одинаков для всех хранилищ
+	store := mediaStorage()
+	now := time.Now()
+	keyDir := path.Join("users", strconv.Itoa(userID), mediaType+"s",
+		strconv.Itoa(now.Year()), fmt.Sprintf("%02d", now.Month()))
+	relativePath := keyDir + "/" + fileName
+
+	fmt.Printf("📂 [UPLOAD] Путь сохранения: %s (%s)\n", relativePath, store.Name())
+
+	// Сохраняем файл. Файлы медиатеки отдаются через /api/media/file, поэтому в S3 они приватные.
+	// file_size учитывается в квоте, поэтому у фото включает варианты.
+	var fileSize int64
+	if processed != nil {
+		if err := putProcessedImage(store, keyDir, baseName, processed, false); err != nil {
+			fmt.Printf("❌ [UPLOAD] Ошибка сохранения вариантов: %v\n", err)
+			sendErrorResponse(w, "Failed to save file", http.StatusInternalServerError)
+			return
+		}
+		fileSize = storedImageSize(processed, header.Size)
+	} else {
+		if err := store.Put(relativePath, file, mimeType, false); err != nil {
+			fmt.Printf("❌ [UPLOAD] Ошибка сохранения файла: %v\n", err)
+			sendErrorResponse(w, "Failed to save file", http.StatusInternalServerError)
+			return
+		}
+		fileSize = header.Size
 	}
 
 	fmt.Printf("💾 [UPLOAD] Файл сохранен, размер: %d bytes\n", fileSize)
 
-	// Оптимизируем видео (если это видео)
+	// Видео транскодируется в фоне (media_jobs), ответ уходит сразу со статусом processing
+	status := models.MediaStatusReady
 	if mediaType == "video" {
-		optimizedPath, err := optimizeVideo(fullPath)
-		if err != nil {
-			fmt.Printf("❌ [UPLOAD] Ошибка оптимизации видео: %v\n", err)
-			os.Remove(fullPath)
-			sendErrorResponse(w, "Failed to optimize video", http.StatusInternalServerError)
-			return
-		}
-		// Обновляем путь и размер файла
-		fullPath = optimizedPath
-		relativePath = strings.TrimPrefix(fullPath, UploadDir+string(filepath.Separator))
-		fileName = filepath.Base(fullPath)
-		fileInfo, _ := os.Stat(fullPath)
-		fileSize = fileInfo.Size()
+		status = models.MediaStatusProcessing
 	}
 
 	// Получаем размеры изображения (если это фото)
 	var width, height *int
-	if mediaType == "photo" {
+	var variantsJSON *string
+	if processed != nil {
+		width, height = &processed.Width, &processed.Height
+		// В БД хранятся только размеры, URL вариантов строятся по ID
+		data, _ := json.Marshal(imageVariants(processed, func(string, string, string) string { return "" }))
+		v := string(data)
+		variantsJSON = &v
+	} else if mediaType == "photo" {
 		file.Seek(0, 0) // Возвращаемся в начало файла
 		img, _, err := image.DecodeConfig(file)
 		if err == nil {
@@ -173,22 +204,32 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 
 	// Сохраняем в БД
 	query := ConvertPlaceholders(`
-		INSERT INTO user_media (user_id, file_name, original_name, file_path, file_size, mime_type, media_type, width, height)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO user_media (user_id, file_name, original_name, file_path, file_size, mime_type, media_type, width, height, variants, status, is_private, storage)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
 	`)
 	fmt.Printf("💾 [UPLOAD] Сохранение в БД: user_id=%d, file_name=%s, media_type=%s\n", userID, fileName, mediaType)
 
-	result, err := h.DB.Exec(query, userID, fileName, header.Filename, relativePath, fileSize, mimeType, mediaType, width, height)
+	var mediaID int64
+	err = h.DB.QueryRow(query, userID, fileName, header.Filename, relativePath, fileSize, mimeType, mediaType, width, height, variantsJSON, status, isPrivate, store.Name()).Scan(&mediaID)
 	if err != nil {
 		fmt.Printf("❌ [UPLOAD] Ошибка сохранения в БД: %v\n", err)
-		os.Remove(fullPath) // Удаляем файл при ошибке БД
+		deleteStoredImage(store, relativePath) // Удаляем файл при ошибке БД
 		sendErrorResponse(w, "Failed to save to database", http.StatusInternalServerError)
 		return
 	}
-
-	mediaID, _ := result.LastInsertId()
 	fmt.Printf("✅ [UPLOAD] Запись в БД создана, ID=%d\n", mediaID)
 
+	if status == models.MediaStatusProcessing {
+		if err := enqueueMediaJob(h.DB, int(mediaID), userID); err != nil {
+			fmt.Printf("❌ [UPLOAD] Ошибка постановки в очередь: %v\n", err)
+			h.DB.Exec(ConvertPlaceholders("DELETE FROM user_media WHERE id = ?"), mediaID)
+			store.Delete(relativePath)
+			sendErrorResponse(w, "Failed to queue video processing", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Формируем ответ
 	media := models.UserMedia{
 		ID:           int(mediaID),
@@ -202,7 +243,14 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		Width:        width,
 		Height:       height,
 		UploadedAt:   now,
-		URL:          "/api/media/file/" + strconv.Itoa(int(mediaID)),
+		Status:       status,
+		Storage:      store.Name(),
+		IsPrivate:    isPrivate,
+	}
+	if variantsJSON != nil {
+		setMediaURLs(&media, *variantsJSON, "")
+	} else {
+		setMediaURLs(&media, "", "")
 	}
 
 	fmt.Printf("🎉 [UPLOAD] Загрузка завершена успешно! ID=%d, URL=%s\n", mediaID, media.URL)
@@ -228,19 +276,24 @@ func (h *MediaHandler) GetUserMedia(w http.ResponseWriter, r *http.Request) {
 	mediaType := r.URL.Query().Get("type")
 
 	// Формируем запрос
-	query := ConvertPlaceholders(`
-		SELECT id, user_id, file_name, original_name, file_path, file_size, mime_type, media_type, width, height, duration, uploaded_at
+	query := `
+		SELECT ` + userMediaColumns + `
 		FROM user_media
 		WHERE user_id = ?
-	`)
+	`
 	args := []interface{}{userID}
 
 	if mediaType != "" {
-		query = strings.TrimSuffix(query, ")") + " AND media_type = " + ConvertPlaceholders("?") + ")"
+		query += " AND media_type = ?"
 		args = append(args, mediaType)
 	}
 
-	query += " ORDER BY uploaded_at DESC"
+	// Приватные файлы видит только владелец
+	if requesterID, _ := r.Context().Value("userID").(int); requesterID != userID {
+		query += " AND COALESCE(is_private, FALSE) = FALSE"
+	}
+
+	query = ConvertPlaceholders(query + " ORDER BY uploaded_at DESC")
 
 	rows, err := h.DB.Query(query, args...)
 	if err != nil {
@@ -251,16 +304,10 @@ func (h *MediaHandler) GetUserMedia(w http.ResponseWriter, r *http.Request) {
 
 	var mediaList []models.UserMedia
 	for rows.Next() {
-		var media models.UserMedia
-		err := rows.Scan(
-			&media.ID, &media.UserID, &media.FileName, &media.OriginalName,
-			&media.FilePath, &media.FileSize, &media.MimeType, &media.MediaType,
-			&media.Width, &media.Height, &media.Duration, &media.UploadedAt,
-		)
+		media, err := scanUserMedia(rows)
 		if err != nil {
 			continue
 		}
-		media.URL = "/api/media/file/" + strconv.Itoa(media.ID)
 		mediaList = append(mediaList, media)
 	}
 
@@ -288,8 +335,14 @@ func (h *MediaHandler) GetMediaFile(w http.ResponseWriter, r *http.Request) {
 
 	// Получаем информацию о файле из БД
 	var media models.UserMedia
-	query := ConvertPlaceholders(`SELECT file_path, mime_type FROM user_media WHERE id = ?`)
-	err = h.DB.QueryRow(query, mediaID).Scan(&media.FilePath, &media.MimeType)
+	var posterPath, variantsJSON string
+	query := ConvertPlaceholders(`
+		SELECT file_path, original_name, mime_type, COALESCE(poster_path, ''),
+			COALESCE(storage, 'local'), COALESCE(is_private, FALSE), COALESCE(variants, '')
+		FROM user_media WHERE id = ?
+	`)
+	err = h.DB.QueryRow(query, mediaID).Scan(&media.FilePath, &media.OriginalName, &media.MimeType, &posterPath,
+		&media.Storage, &media.IsPrivate, &variantsJSON)
 	if err == sql.ErrNoRows {
 		http.NotFound(w, r)
 		return
@@ -299,9 +352,75 @@ func (h *MediaHandler) GetMediaFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Открываем файл
+	// Приватный файл отдаётся только по подписанной ссылке (signedMediaURL)
+	cacheControl := "public, max-age=31536000" // Кеш на год
+	if media.IsPrivate {
+		if !verifyMediaURL(mediaID, r.URL.Query().Get("expires"), r.URL.Query().Get("sig")) {
+			sendErrorResponse(w, "Link expired or invalid", http.StatusForbidden)
+			return
+		}
+		cacheControl = "private, no-store"
+	}
+
+	// ?poster=1 - кадр-обложка видео
+	if r.URL.Query().Get("poster") != "" {
+		if posterPath == "" {
+			http.NotFound(w, r)
+			return
+		}
+		media.FilePath, media.MimeType = posterPath, "image/jpeg"
+	}
+
+	// Файлы в S3 отдаются по короткоживущей ссылке бакета
+	if media.Storage == storage.BackendS3 {
+		if !storage.Enabled() {
+			sendErrorResponse(w, "File storage is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		downloadName := media.OriginalName
+		if media.FilePath == posterPath {
+			downloadName = ""
+		}
+		if size := r.URL.Query().Get("size"); size != "" {
+			key, _, ok := variantPath(media.FilePath, size, r.URL.Query().Get("format"))
+			if !ok {
+				sendErrorResponse(w, "Invalid size or format", http.StatusBadRequest)
+				return
+			}
+			// Варианты есть только у фото, загруженных после их появления
+			if variantsJSON != "" {
+				media.FilePath, downloadName = key, ""
+			}
+		}
+		url, err := storage.GlobalS3Client.PresignGet(media.FilePath, storage.PresignTTL(), downloadName)
+		if err != nil {
+			log.Printf("❌ [MEDIA] %v", err)
+			sendErrorResponse(w, "Failed to fetch media", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	// Открываем файл (?size=thumb|medium|large&format=webp|jpeg - вариант фото)
 	fullPath := filepath.Join(UploadDir, media.FilePath)
 	file, err := os.Open(fullPath)
+	if size := r.URL.Query().Get("size"); size != "" {
+		path, mimeType, ok := variantPath(fullPath, size, r.URL.Query().Get("format"))
+		if !ok {
+			sendErrorResponse(w, "Invalid size or format", http.StatusBadRequest)
+			return
+		}
+		// Для старых загрузок без вариантов отдаётся оригинал
+		if variant, verr := os.Open(path); verr == nil {
+			if file != nil {
+				file.Close()
+			}
+			file, err = variant, nil
+			media.MimeType = mimeType
+		}
+	}
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -310,7 +429,7 @@ func (h *MediaHandler) GetMediaFile(w http.ResponseWriter, r *http.Request) {
 
 	// Отдаем файл
 	w.Header().Set("Content-Type", media.MimeType)
-	w.Header().Set("Cache-Control", "public, max-age=31536000") // Кеш на год
+	w.Header().Set("Cache-Control", cacheControl)
 	io.Copy(w, file)
 }
 
@@ -337,10 +456,12 @@ func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем, что файл принадлежит пользователю
-	var filePath string
+	var filePath, posterPath, storageType string
 	var ownerID int
-	query := ConvertPlaceholders(`SELECT user_id, file_path FROM user_media WHERE id = ?`)
-	err = h.DB.QueryRow(query, mediaID).Scan(&ownerID, &filePath)
+	query := ConvertPlaceholders(`
+		SELECT user_id, file_path, COALESCE(poster_path, ''), COALESCE(storage, 'local') FROM user_media WHERE id = ?
+	`)
+	err = h.DB.QueryRow(query, mediaID).Scan(&ownerID, &filePath, &posterPath, &storageType)
 	if err == sql.ErrNoRows {
 		sendErrorResponse(w, "Media not found", http.StatusNotFound)
 		return
@@ -356,8 +477,24 @@ func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Удаляем файл
-	fullPath := filepath.Join(UploadDir, filePath)
-	os.Remove(fullPath)
+	if storageType == storage.BackendS3 {
+		if storage.Enabled() {
+			storage.GlobalS3Client.DeleteObject(filePath)
+			for _, key := range imageVariantPaths(filePath) {
+				storage.GlobalS3Client.DeleteObject(key)
+			}
+			if posterPath != "" {
+				storage.GlobalS3Client.DeleteObject(posterPath)
+			}
+		}
+	} else {
+		fullPath := filepath.Join(UploadDir, filePath)
+		os.Remove(fullPath)
+		removeImageVariants(fullPath)
+		if posterPath != "" {
+			os.Remove(filepath.Join(UploadDir, posterPath))
+		}
+	}
 
 	// Удаляем из БД
 	_, err = h.DB.Exec(ConvertPlaceholders("DELETE FROM user_media WHERE id = ?"), mediaID)
@@ -434,92 +571,3 @@ func isAllowedMimeType(mimeType, mediaType string) bool {
 	}
 	return false
 }
-
-// optimizeVideo оптимизирует видео с помощью FFmpeg (сохраняет разрешение, но сжимает)
-func optimizeVideo(inputPath string) (string, error) {
-	if !OptimizeVideo {
-		return inputPath, nil
-	}
-
-	// Проверяем наличие FFmpeg
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		fmt.Printf("⚠️ [VIDEO] FFmpeg не найден, пропускаем оптимизацию\n")
-		return inputPath, nil
-	}
-
-	// Получаем информацию о файле
-	inputInfo, err := os.Stat(inputPath)
-	if err != nil {
-		return "", err
-	}
-	inputSize := inputInfo.Size()
-
-	fmt.Printf("🎬 [VIDEO] Начало оптимизации: %s (%.2f MB)\n", filepath.Base(inputPath), float64(inputSize)/(1024*1024))
-
-	// Получаем разрешение оригинального видео
-	probeCmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=width,height",
-		"-of", "csv=p=0",
-		inputPath,
-	)
-	probeOutput, err := probeCmd.Output()
-	if err != nil {
-		fmt.Printf("⚠️ [VIDEO] Не удалось определить разрешение, используем оригинал\n")
-		return inputPath, nil
-	}
-
-	resolution := strings.TrimSpace(string(probeOutput))
-	fmt.Printf("📐 [VIDEO] Оригинальное разрешение: %s\n", resolution)
-
-	// Создаем временный файл для оптимизированного видео
-	outputPath := strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + "_optimized.mp4"
-
-	// FFmpeg команда (сохраняем разрешение, но агрессивно сжимаем)
-	args := []string{
-		"-i", inputPath,
-		"-c:v", "libx264",
-		"-preset", "medium", // Баланс скорость/качество
-		"-crf", "28", // Агрессивное сжатие (как в Telegram)
-		"-profile:v", "main", // Профиль для совместимости
-		"-level", "4.0", // Уровень для поддержки разных разрешений
-		"-pix_fmt", "yuv420p", // Формат пикселей
-		"-r", "30", // Максимум 30 FPS
-		"-c:a", "aac", // Кодек аудио
-		"-b:a", "64k", // Битрейт аудио
-		"-ar", "44100", // Частота дискретизации
-		"-ac", "2", // Стерео
-		"-movflags", "+faststart", // Оптимизация для веб
-		"-y",
-		outputPath,
-	}
-
-	fmt.Printf("⚙️ [VIDEO] FFmpeg команда: ffmpeg %s\n", strings.Join(args, " "))
-	fmt.Printf("⏳ [VIDEO] Обработка... (может занять время)\n")
-
-	// Запускаем FFmpeg
-	cmd := exec.Command("ffmpeg", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("❌ [VIDEO] Ошибка FFmpeg: %v\n%s\n", err, string(output))
-		return "", fmt.Errorf("FFmpeg error: %v", err)
-	}
-
-	// Проверяем результат
-	outputInfo, err := os.Stat(outputPath)
-	if err != nil {
-		return "", err
-	}
-	outputSize := outputInfo.Size()
-
-	// Вычисляем экономию
-	savings := float64(inputSize-outputSize) / float64(inputSize) * 100
-	fmt.Printf("✅ [VIDEO] Оптимизация завершена: %s (%.2f MB)\n", filepath.Base(outputPath), float64(outputSize)/(1024*1024))
-	fmt.Printf("📊 [VIDEO] Экономия: %.1f%% (%.2f MB)\n", savings, float64(inputSize-outputSize)/(1024*1024))
-
-	// Удаляем оригинал
-	os.Remove(inputPath)
-
-	return outputPath, nil
-}
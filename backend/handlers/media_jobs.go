@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/storage"
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// mediaJobPollInterval - как часто воркеры проверяют очередь без пробуждения
+	mediaJobPollInterval = 5 * time.Second
+	// mediaJobHeartbeat - как часто воркер продлевает locked_at своей задачи
+	// и как часто проверяются брошенные задачи
+	mediaJobHeartbeat = time.Minute
+	// mediaJobStaleAfter - задача в running без продления дольше этого считается
+	// брошенной (воркер или сервер упал)
+	mediaJobStaleAfter = 5 * time.Minute
+	// mediaJobRetryDelay - базовая задержка повтора, удваивается с каждой попыткой
+	mediaJobRetryDelay = 30 * time.Second
+)
+
+// mediaJobWake будит воркеров сразу после постановки задачи в очередь
+var mediaJobWake = make(chan struct{}, 1)
+
+// userMediaColumns - колонки user_media в порядке scanUserMedia
+const userMediaColumns = `id, user_id, file_name, original_name, file_path, file_size, mime_type, media_type,
+	width, height, duration, uploaded_at, COALESCE(variants, ''), COALESCE(status, 'ready'), COALESCE(poster_path, ''),
+	COALESCE(storage, 'local'), COALESCE(is_private, FALSE)`
+
+// scanUserMedia сканирует строку user_media (userMediaColumns) и заполняет URL
+func scanUserMedia(row interface {
+	Scan(dest ...interface{}) error
+}) (models.UserMedia, error) {
+	var media models.UserMedia
+	var variantsJSON, posterPath string
+	err := row.Scan(
+		&media.ID, &media.UserID, &media.FileName, &media.OriginalName,
+		&media.FilePath, &media.FileSize, &media.MimeType, &media.MediaType,
+		&media.Width, &media.Height, &media.Duration, &media.UploadedAt,
+		&variantsJSON, &media.Status, &posterPath, &media.Storage, &media.IsPrivate,
+	)
+	if err != nil {
+		return media, err
+	}
+
+	setMediaURLs(&media, variantsJSON, posterPath)
+	return media, nil
+}
+
+// setMediaURLs заполняет URL файла, вариантов и постера.
+// Ссылки на приватные файлы подписываются и действуют ограниченное время.
+func setMediaURLs(media *models.UserMedia, variantsJSON, posterPath string) {
+	media.URL = "/api/media/file/" + strconv.Itoa(media.ID)
+	media.Variants = mediaVariantURLs(media.ID, variantsJSON)
+	if media.IsPrivate {
+		media.URL = signedMediaURL(media.ID)
+		for size, v := range media.Variants {
+			v.WebP = media.URL + "&size=" + size + "&format=webp"
+			v.JPEG = media.URL + "&size=" + size + "&format=jpeg"
+			media.Variants[size] = v
+		}
+	}
+	if posterPath != "" {
+		if media.IsPrivate {
+			media.PosterURL = media.URL + "&poster=1"
+		} else {
+			media.PosterURL = media.URL + "?poster=1"
+		}
+	}
+}
+
+// enqueueMediaJob ставит видео в очередь на транскодирование
+func enqueueMediaJob(db *sql.DB, mediaID, userID int) error {
+	_, err := db.Exec(ConvertPlaceholders(`
+		INSERT INTO media_jobs (media_id, user_id, job_type) VALUES (?, ?, 'video_transcode')
+	`), mediaID, userID)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case mediaJobWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// StartMediaWorkers запускает воркеры очереди media_jobs. Задачи забираются
+// через FOR UPDATE SKIP LOCKED, поэтому воркеры (и реплики) не берут одну задачу дважды.
+// Работающий воркер продлевает locked_at; задачи без продления (упавший воркер
+// или реплика) периодически возвращаются в очередь.
+func StartMediaWorkers(db *sql.DB, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	requeueStaleMediaJobs(db)
+	go func() {
+		ticker := time.NewTicker(mediaJobHeartbeat)
+		defer ticker.Stop()
+		for range ticker.C {
+			requeueStaleMediaJobs(db)
+		}
+	}()
+
+	for i := 1; i <= workers; i++ {
+		go func(worker int) {
+			ticker := time.NewTicker(mediaJobPollInterval)
+			defer ticker.Stop()
+
+			for {
+				// Обрабатываем всё, что готово, затем ждём пробуждения или тика
+				for runNextMediaJob(db, worker) {
+				}
+
+				select {
+				case <-mediaJobWake:
+				case <-ticker.C:
+				}
+			}
+		}(i)
+	}
+
+	log.Printf("✅ Media workers started (%d)", workers)
+}
+
+// requeueStaleMediaJobs возвращает в очередь задачи, брошенные упавшим воркером
+func requeueStaleMediaJobs(db *sql.DB) {
+	result, err := db.Exec(ConvertPlaceholders(`
+		UPDATE media_jobs SET status = 'queued', locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < ?
+	`), time.Now().Add(-mediaJobStaleAfter))
+	if err != nil {
+		log.Printf("❌ Media jobs: requeue error: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🔄 Media jobs: returned %d stale jobs to the queue", n)
+		select {
+		case mediaJobWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// startMediaJobHeartbeat продлевает locked_at задачи, пока она выполняется.
+// Возвращает функцию остановки.
+func startMediaJobHeartbeat(db *sql.DB, jobID int) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(mediaJobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := db.Exec(ConvertPlaceholders(`
+					UPDATE media_jobs SET locked_at = NOW() WHERE id = ? AND status = 'running'
+				`), jobID)
+				if err != nil {
+					log.Printf("⚠️ Media jobs: heartbeat error for job %d: %v", jobID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// mediaJobTask - забранная воркером задача
+type mediaJobTask struct {
+	ID          int
+	MediaID     int
+	UserID      int
+	Attempts    int
+	MaxAttempts int
+}
+
+// runNextMediaJob забирает и выполняет одну задачу; false - очередь пуста
+func runNextMediaJob(db *sql.DB, worker int) bool {
+	var job mediaJobTask
+	err := db.QueryRow(ConvertPlaceholders(`
+		UPDATE media_jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM media_jobs
+			WHERE status = 'queued' AND run_after <= NOW()
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, media_id, user_id, attempts, max_attempts
+	`)).Scan(&job.ID, &job.MediaID, &job.UserID, &job.Attempts, &job.MaxAttempts)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Printf("❌ Media worker %d: claim error: %v", worker, err)
+		return false
+	}
+
+	log.Printf("🎬 Media worker %d: job %d (media %d, attempt %d/%d)", worker, job.ID, job.MediaID, job.Attempts, job.MaxAttempts)
+
+	stopHeartbeat := startMediaJobHeartbeat(db, job.ID)
+	err = processVideoJob(db, job)
+	stopHeartbeat()
+	if err != nil {
+		log.Printf("❌ Media worker %d: job %d failed: %v", worker, job.ID, err)
+		failMediaJob(db, job, err)
+		return true
+	}
+
+	db.Exec(ConvertPlaceholders(`
+		UPDATE media_jobs
+		SET status = 'done', progress = 100, last_error = NULL, locked_at = NULL, updated_at = NOW(), completed_at = NOW()
+		WHERE id = ?
+	`), job.ID)
+
+	if media, err := scanUserMedia(db.QueryRow(ConvertPlaceholders(`SELECT `+userMediaColumns+` FROM user_media WHERE id = ?`), job.MediaID)); err == nil {
+		SendToUser(job.UserID, "media_ready", media)
+	}
+	log.Printf("✅ Media worker %d: job %d done", worker, job.ID)
+	return true
+}
+
+// failMediaJob планирует повтор с экспоненциальной задержкой или, если попытки
+// исчерпаны, помечает задачу и медиафайл как failed
+func failMediaJob(db *sql.DB, job mediaJobTask, jobErr error) {
+	if job.Attempts < job.MaxAttempts {
+		delay := mediaJobRetryDelay * time.Duration(1<<(job.Attempts-1))
+		db.Exec(ConvertPlaceholders(`
+			UPDATE media_jobs
+			SET status = 'queued', progress = 0, last_error = ?, locked_at = NULL, run_after = ?, updated_at = NOW()
+			WHERE id = ?
+		`), jobErr.Error(), time.Now().Add(delay), job.ID)
+		return
+	}
+
+	db.Exec(ConvertPlaceholders(`
+		UPDATE media_jobs
+		SET status = 'failed', last_error = ?, locked_at = NULL, updated_at = NOW(), completed_at = NOW()
+		WHERE id = ?
+	`), jobErr.Error(), job.ID)
+	db.Exec(ConvertPlaceholders(`UPDATE user_media SET status = 'failed' WHERE id = ?`), job.MediaID)
+
+	SendToUser(job.UserID, "media_failed", map[string]interface{}{
+		"media_id": job.MediaID,
+		"error":    "Не удалось обработать видео",
+	})
+}
+
+// processVideoJob транскодирует видео, снимает кадр-обложку и записывает
+// длительность и размеры в user_media. Видео из S3 скачивается во временный
+// файл, результат загружается обратно в бакет.
+func processVideoJob(db *sql.DB, job mediaJobTask) error {
+	var filePath, storageType string
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT file_path, COALESCE(storage, 'local') FROM user_media WHERE id = ?
+	`), job.MediaID).Scan(&filePath, &storageType)
+	if err == sql.ErrNoRows {
+		// Медиа удалено до обработки - делать нечего
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	inS3 := storageType == "s3"
+	inputPath := filepath.Join(UploadDir, filePath)
+	if inS3 {
+		if !storage.Enabled() {
+			return fmt.Errorf("media %d is stored in S3, but S3 is not configured", job.MediaID)
+		}
+		jobsDir := filepath.Join(TempUploadDir, "jobs")
+		if err := os.MkdirAll(jobsDir, 0755); err != nil {
+			return err
+		}
+		inputPath = filepath.Join(jobsDir, fmt.Sprintf("%d%s", job.ID, filepath.Ext(filePath)))
+		if err := storage.GlobalS3Client.DownloadFile(filePath, inputPath); err != nil {
+			return err
+		}
+		defer os.Remove(inputPath)
+	}
+	if _, err := os.Stat(inputPath); err != nil {
+		return err
+	}
+
+	info, err := probeVideo(inputPath)
+	if err != nil {
+		log.Printf("⚠️ [VIDEO] ffprobe: %v", err)
+	}
+
+	lastProgress := 0
+	outputPath, err := optimizeVideo(inputPath, info.Duration, func(progress int) {
+		if progress-lastProgress < 5 {
+			return
+		}
+		lastProgress = progress
+		db.Exec(ConvertPlaceholders(`UPDATE media_jobs SET progress = ?, locked_at = NOW(), updated_at = NOW() WHERE id = ?`), progress, job.ID)
+		SendToUser(job.UserID, "media_progress", map[string]interface{}{
+			"media_id": job.MediaID,
+			"progress": progress,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Кадр-обложка не обязателен: без него видео всё равно готово
+	posterLocal, err := extractPosterFrame(outputPath, info.Duration)
+	if err != nil {
+		log.Printf("⚠️ [VIDEO] Постер не создан: %v", err)
+		posterLocal = ""
+	}
+
+	// Пути в user_media: относительно UploadDir или ключи объектов в S3
+	newFilePath := strings.TrimPrefix(outputPath, UploadDir+string(filepath.Separator))
+	var posterPath *string
+	if posterLocal != "" {
+		rel := strings.TrimPrefix(posterLocal, UploadDir+string(filepath.Separator))
+		posterPath = &rel
+	}
+	// removeOutputs удаляет результаты, если запись не удалось обновить
+	removeOutputs := func() {
+		if outputPath != inputPath {
+			os.Remove(outputPath)
+		}
+		if posterLocal != "" {
+			os.Remove(posterLocal)
+		}
+	}
+
+	if inS3 {
+		base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+		newFilePath = filePath
+		if outputPath != inputPath {
+			newFilePath = base + "_optimized.mp4"
+			if err := storage.GlobalS3Client.PutPrivateFile(outputPath, newFilePath, "video/mp4"); err != nil {
+				removeOutputs()
+				return err
+			}
+		}
+		if posterLocal != "" {
+			key := base + "_poster.jpg"
+			if err := storage.GlobalS3Client.PutPrivateFile(posterLocal, key, "image/jpeg"); err != nil {
+				log.Printf("⚠️ [VIDEO] Постер не загружен в S3: %v", err)
+				posterPath = nil
+			} else {
+				posterPath = &key
+			}
+		}
+
+		localOutputs := removeOutputs
+		removeOutputs = func() {
+			if newFilePath != filePath {
+				storage.GlobalS3Client.DeleteObject(newFilePath)
+			}
+			if posterPath != nil {
+				storage.GlobalS3Client.DeleteObject(*posterPath)
+			}
+		}
+		defer localOutputs()
+	}
+
+	var width, height, duration *int
+	if info.Width > 0 && info.Height > 0 {
+		width, height = &info.Width, &info.Height
+	}
+	if info.Duration > 0 {
+		d := int(math.Round(info.Duration))
+		duration = &d
+	}
+
+	mimeType := "video/mp4"
+	if outputPath == inputPath {
+		// FFmpeg недоступен - файл остался как есть
+		db.QueryRow(ConvertPlaceholders(`SELECT mime_type FROM user_media WHERE id = ?`), job.MediaID).Scan(&mimeType)
+	}
+
+	var fileSize int64
+	if stat, err := os.Stat(outputPath); err == nil {
+		fileSize = stat.Size()
+	}
+
+	result, err := db.Exec(ConvertPlaceholders(`
+		UPDATE user_media
+		SET file_name = ?, file_path = ?, file_size = ?, mime_type = ?, width = ?, height = ?, duration = ?,
+		    poster_path = ?, status = 'ready'
+		WHERE id = ?
+	`), filepath.Base(newFilePath), newFilePath, fileSize, mimeType, width, height, duration, posterPath, job.MediaID)
+	updated := int64(0)
+	if err == nil {
+		updated, _ = result.RowsAffected()
+	}
+	if err != nil || updated == 0 {
+		// Ошибка или медиа удалено во время обработки - новые файлы не нужны
+		removeOutputs()
+		return err
+	}
+
+	// Оригинал удаляется только после того, как запись указывает на новый файл
+	switch {
+	case inS3 && newFilePath != filePath:
+		storage.GlobalS3Client.DeleteObject(filePath)
+	case !inS3 && outputPath != inputPath:
+		os.Remove(inputPath)
+	}
+	return nil
+}
+
+// videoInfo - параметры видео из ffprobe
+type videoInfo struct {
+	Width    int
+	Height   int
+	Duration float64 // секунды
+}
+
+// probeVideo читает размеры и длительность видео через ffprobe
+func probeVideo(path string) (videoInfo, error) {
+	var info videoInfo
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return info, err
+	}
+
+	output, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height:format=duration",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return info, err
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return info, err
+	}
+
+	if len(probe.Streams) > 0 {
+		info.Width, info.Height = probe.Streams[0].Width, probe.Streams[0].Height
+	}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	return info, nil
+}
+
+// optimizeVideo оптимизирует видео с помощью FFmpeg (сохраняет разрешение, но сжимает).
+// onProgress получает процент готовности по данным -progress. Оригинал не удаляется.
+func optimizeVideo(inputPath string, duration float64, onProgress func(int)) (string, error) {
+	if !OptimizeVideo {
+		return inputPath, nil
+	}
+
+	// Проверяем наличие FFmpeg
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		fmt.Printf("⚠️ [VIDEO] FFmpeg не найден, пропускаем оптимизацию\n")
+		return inputPath, nil
+	}
+
+	// Получаем информацию о файле
+	inputInfo, err := os.Stat(inputPath)
+	if err != nil {
+		return "", err
+	}
+	inputSize := inputInfo.Size()
+
+	fmt.Printf("🎬 [VIDEO] Начало оптимизации: %s (%.2f MB, %.1f сек)\n", filepath.Base(inputPath), float64(inputSize)/(1024*1024), duration)
+
+	outputPath := strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + "_optimized.mp4"
+
+	// FFmpeg команда (сохраняем разрешение, но агрессивно сжимаем)
+	args := []string{
+		"-i", inputPath,
+		"-c:v", "libx264",
+		"-preset", "medium", // Баланс скорость/качество
+		"-crf", "28", // Агрессивное сжатие (как в Telegram)
+		"-profile:v", "main", // Профиль для совместимости
+		"-level", "4.0", // Уровень для поддержки разных разрешений
+		"-pix_fmt", "yuv420p", // Формат пикселей
+		"-r", "30", // Максимум 30 FPS
+		"-c:a", "aac", // Кодек аудио
+		"-b:a", "64k", // Битрейт аудио
+		"-ar", "44100", // Частота дискретизации
+		"-ac", "2", // Стерео
+		"-movflags", "+faststart", // Оптимизация для веб
+		"-progress", "pipe:1", // Прогресс в stdout (key=value)
+		"-nostats",
+		"-y",
+		outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("FFmpeg error: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// out_time_ms на самом деле в микросекундах
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_ms=")
+		if !ok || duration <= 0 || onProgress == nil {
+			continue
+		}
+		if us, err := strconv.ParseFloat(value, 64); err == nil {
+			onProgress(int(math.Min(99, us/1e6/duration*100)))
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		fmt.Printf("❌ [VIDEO] Ошибка FFmpeg: %v\n%s\n", err, stderr.String())
+		os.Remove(outputPath)
+		return "", fmt.Errorf("FFmpeg error: %v", err)
+	}
+
+	outputInfo, err := os.Stat(outputPath)
+	if err != nil {
+		return "", err
+	}
+	outputSize := outputInfo.Size()
+
+	savings := float64(inputSize-outputSize) / float64(inputSize) * 100
+	fmt.Printf("✅ [VIDEO] Оптимизация завершена: %s (%.2f MB)\n", filepath.Base(outputPath), float64(outputSize)/(1024*1024))
+	fmt.Printf("📊 [VIDEO] Экономия: %.1f%% (%.2f MB)\n", savings, float64(inputSize-outputSize)/(1024*1024))
+
+	return outputPath, nil
+}
+
+// extractPosterFrame сохраняет JPEG-кадр рядом с видео ({base}_poster.jpg)
+func extractPosterFrame(videoPath string, duration float64) (string, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", err
+	}
+
+	// Первая секунда часто чёрная - берём 1с, но не дальше середины короткого ролика
+	at := 1.0
+	if duration > 0 && duration < 2 {
+		at = duration / 2
+	}
+
+	posterPath := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "_poster.jpg"
+	output, err := exec.Command("ffmpeg",
+		"-ss", strconv.FormatFloat(at, 'f', 2, 64),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-vf", "scale='min(1280,iw)':-2",
+		"-q:v", "3",
+		"-y",
+		posterPath,
+	).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, string(output))
+	}
+	return posterPath, nil
+}
+
+// GetMediaStatus - состояние обработки медиа и последней задачи (GET /api/media/status/{id})
+func (h *MediaHandler) GetMediaStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mediaID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/media/status/"))
+	if err != nil {
+		sendErrorResponse(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := scanUserMedia(h.DB.QueryRow(ConvertPlaceholders(`SELECT `+userMediaColumns+` FROM user_media WHERE id = ?`), mediaID))
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch media", http.StatusInternalServerError)
+		return
+	}
+	if media.UserID != userID {
+		sendErrorResponse(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	response := map[string]interface{}{"media": media}
+
+	var job models.MediaJob
+	var lastError sql.NullString
+	var completedAt sql.NullTime
+	err = h.DB.QueryRow(ConvertPlaceholders(`
+		SELECT id, media_id, job_type, status, progress, attempts, max_attempts, last_error, created_at, updated_at, completed_at
+		FROM media_jobs WHERE media_id = ? ORDER BY id DESC LIMIT 1
+	`), mediaID).Scan(&job.ID, &job.MediaID, &job.JobType, &job.Status, &job.Progress, &job.Attempts,
+		&job.MaxAttempts, &lastError, &job.CreatedAt, &job.UpdatedAt, &completedAt)
+	if err == nil {
+		job.LastError = lastError.String
+		if completedAt.Valid {
+			job.CompletedAt = &completedAt.Time
+		}
+		response["job"] = job
+	} else if err != sql.ErrNoRows {
+		sendErrorResponse(w, "Failed to fetch job", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, response)
+}
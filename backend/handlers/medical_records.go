@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"database"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var validMedicalRecordTypes = map[string]bool{
+	models.MedicalRecordVaccination:   true,
+	models.MedicalRecordDeworming:     true,
+	models.MedicalRecordSterilization: true,
+	models.MedicalRecordDiagnosis:     true,
+	models.MedicalRecordTreatment:     true,
+}
+
+const medicalRecordColumns = `
+	m.id, m.pet_id, m.record_type, m.title, m.description,
+	left(m.performed_at::text, 10), left(m.next_due_at::text, 10), m.vet_name,
+	m.clinic_id, coalesce(o.name, ''), m.attachments, m.visibility,
+	m.created_by, m.updated_by, m.created_at, m.updated_at`
+
+// PetMedicalRecordsHandler - медицинские записи питомца
+// GET /api/pets/{id}/medical - список (приватные видны владельцу, редакторам и выдавшей клинике)
+// POST /api/pets/{id}/medical - новая запись (владелец или сотрудник ветклиники)
+func PetMedicalRecordsHandler(w http.ResponseWriter, r *http.Request, petID int) {
+	switch r.Method {
+	case http.MethodGet:
+		getPetMedicalRecords(w, r, petID)
+	case http.MethodPost:
+		createMedicalRecord(w, r, petID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// MedicalRecordHandler - операции с одной записью
+// PUT /api/medical-records/{id}
+// DELETE /api/medical-records/{id}
+// POST /api/medical-records/{id}/visibility {"visibility": "public"}
+func MedicalRecordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/medical-records/"), "/"), "/")
+	recordID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		sendErrorResponse(w, "Неверный ID записи", http.StatusBadRequest)
+		return
+	}
+
+	record, err := loadMedicalRecord(recordID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Запись не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pet, err := loadPet(record.PetID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "visibility" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		setMedicalRecordVisibility(w, r, userID, &pet, record)
+		return
+	}
+
+	if !canEditMedicalRecord(userID, &pet, record) {
+		sendErrorResponse(w, "Нет прав на изменение этой записи", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		updateMedicalRecord(w, r, userID, record)
+	case http.MethodDelete:
+		_, err := database.DB.Exec(ConvertPlaceholders("DELETE FROM pet_medical_records WHERE id = ?"), recordID)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка удаления записи: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🗑️ Medical record %d of pet %d deleted by user %d", recordID, record.PetID, userID)
+		sendSuccessResponse(w, map[string]string{"message": "Запись удалена"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getPetMedicalRecords(w http.ResponseWriter, r *http.Request, petID int) {
+	userID, _ := r.Context().Value("userID").(int)
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+
+	query := `
+		SELECT ` + medicalRecordColumns + `
+		FROM pet_medical_records m
+		LEFT JOIN organizations o ON o.id = m.clinic_id
+		WHERE m.pet_id = ?`
+	args := []interface{}{petID}
+
+	// Владелец и редакторы видят всё, сотрудники клиники - ещё и записи своей клиники
+	isEditor := userID > 0 && canEditPet(userID, &pet)
+	if !isEditor {
+		query += ` AND (m.visibility = ? OR m.clinic_id IN (
+			SELECT om.organization_id FROM organization_members om WHERE om.user_id = ?
+		))`
+		args = append(args, models.MedicalVisibilityPublic, userID)
+	}
+	if recordType := r.URL.Query().Get("type"); recordType != "" {
+		query += ` AND m.record_type = ?`
+		args = append(args, recordType)
+	}
+	query += ` ORDER BY m.performed_at DESC, m.id DESC`
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения медицинских записей: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	records := []models.MedicalRecord{}
+	for rows.Next() {
+		record, err := scanMedicalRecord(rows)
+		if err != nil {
+			log.Printf("❌ Error scanning medical record: %v", err)
+			continue
+		}
+		if userID > 0 {
+			record.CanEdit = canEditMedicalRecord(userID, &pet, record)
+		}
+		records = append(records, *record)
+	}
+
+	sendSuccessResponse(w, records)
+}
+
+func createMedicalRecord(w http.ResponseWriter, r *http.Request, petID int) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+
+	var req models.MedicalRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	// Запись от имени клиники может выдать только её сотрудник, свою - владелец питомца
+	if req.ClinicID != nil {
+		if !isClinicStaff(userID, *req.ClinicID) {
+			sendErrorResponse(w, "Нет прав выдавать записи от имени этой клиники", http.StatusForbidden)
+			return
+		}
+	} else if !canEditPet(userID, &pet) {
+		sendErrorResponse(w, "Нет прав на добавление записей этому питомцу", http.StatusForbidden)
+		return
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = models.MedicalVisibilityPrivate
+	}
+	if msg := validateMedicalRecord(&req); msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !checkMedicalRecordAttachments(w, &req, userID) {
+		return
+	}
+
+	attachmentsJSON, _ := json.Marshal(req.Attachments)
+
+	var recordID int
+	err = database.DB.QueryRow(ConvertPlaceholders(`
+		INSERT INTO pet_medical_records (pet_id, record_type, title, description, performed_at, next_due_at,
+		                                 vet_name, clinic_id, attachments, visibility, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), petID, req.RecordType, req.Title, req.Description, req.PerformedAt, req.NextDueAt,
+		req.VetName, req.ClinicID, string(attachmentsJSON), req.Visibility, userID).Scan(&recordID)
+	if err != nil {
+		log.Printf("❌ Error creating medical record for pet %d: %v", petID, err)
+		sendErrorResponse(w, "Ошибка создания записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	record, err := loadMedicalRecord(recordID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения записи", http.StatusInternalServerError)
+		return
+	}
+	record.CanEdit = true
+
+	// Владелец узнаёт о записи, внесённой клиникой
+	if record.ClinicID != nil {
+		notifHandler := &NotificationsHandler{DB: database.DB}
+		message := fmt.Sprintf("%s добавила медицинскую запись для %s: %s", record.ClinicName, pet.Name, record.Title)
+		if err := notifHandler.CreateNotification(pet.UserID, userID, "medical_record", "pet", petID, message); err != nil {
+			log.Printf("⚠️ Failed to notify owner of pet %d about medical record: %v", petID, err)
+		}
+	}
+
+	log.Printf("🩺 Medical record %d (%s) added to pet %d by user %d", recordID, record.RecordType, petID, userID)
+	sendSuccessResponse(w, record)
+}
+
+func updateMedicalRecord(w http.ResponseWriter, r *http.Request, userID int, record *models.MedicalRecord) {
+	var req models.MedicalRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	// Клиника записи не меняется: иначе запись можно было бы "переподписать"
+	req.ClinicID = record.ClinicID
+	if req.Visibility == "" {
+		req.Visibility = record.Visibility
+	}
+	if msg := validateMedicalRecord(&req); msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	// Вложения записи подписываются от имени её автора
+	if !checkMedicalRecordAttachments(w, &req, record.CreatedBy) {
+		return
+	}
+
+	attachmentsJSON, _ := json.Marshal(req.Attachments)
+
+	_, err := database.DB.Exec(ConvertPlaceholders(`
+		UPDATE pet_medical_records
+		SET record_type = ?, title = ?, description = ?, performed_at = ?, next_due_at = ?,
+		    vet_name = ?, attachments = ?, visibility = ?, updated_by = ?, updated_at = NOW()
+		WHERE id = ?
+	`), req.RecordType, req.Title, req.Description, req.PerformedAt, req.NextDueAt,
+		req.VetName, string(attachmentsJSON), req.Visibility, userID, record.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка обновления записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := loadMedicalRecord(record.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения записи", http.StatusInternalServerError)
+		return
+	}
+	updated.CanEdit = true
+
+	log.Printf("✅ Medical record %d updated by user %d", record.ID, userID)
+	sendSuccessResponse(w, updated)
+}
+
+// setMedicalRecordVisibility - видимость меняет владелец (или редактор) питомца,
+// в том числе для записей клиники, содержимое которых ему недоступно
+func setMedicalRecordVisibility(w http.ResponseWriter, r *http.Request, userID int, pet *models.Pet, record *models.MedicalRecord) {
+	if !canEditPet(userID, pet) {
+		sendErrorResponse(w, "Видимость записи меняет только владелец питомца", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Visibility string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if req.Visibility != models.MedicalVisibilityPrivate && req.Visibility != models.MedicalVisibilityPublic {
+		sendErrorResponse(w, "Видимость должна быть private или public", http.StatusBadRequest)
+		return
+	}
+
+	_, err := database.DB.Exec(ConvertPlaceholders(`
+		UPDATE pet_medical_records SET visibility = ? WHERE id = ?
+	`), req.Visibility, record.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка изменения видимости: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	record.Visibility = req.Visibility
+	record.CanEdit = canEditMedicalRecord(userID, pet, record)
+	sendSuccessResponse(w, record)
+}
+
+func loadMedicalRecord(recordID int) (*models.MedicalRecord, error) {
+	row := database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT `+medicalRecordColumns+`
+		FROM pet_medical_records m
+		LEFT JOIN organizations o ON o.id = m.clinic_id
+		WHERE m.id = ?
+	`), recordID)
+	return scanMedicalRecord(row)
+}
+
+func scanMedicalRecord(row interface{ Scan(...interface{}) error }) (*models.MedicalRecord, error) {
+	var m models.MedicalRecord
+	var nextDueAt sql.NullString
+	var clinicID, updatedBy sql.NullInt64
+	var attachmentsJSON string
+
+	err := row.Scan(
+		&m.ID, &m.PetID, &m.RecordType, &m.Title, &m.Description,
+		&m.PerformedAt, &nextDueAt, &m.VetName,
+		&clinicID, &m.ClinicName, &attachmentsJSON, &m.Visibility,
+		&m.CreatedBy, &updatedBy, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextDueAt.Valid {
+		m.NextDueAt = &nextDueAt.String
+	}
+	if clinicID.Valid {
+		id := int(clinicID.Int64)
+		m.ClinicID = &id
+	}
+	if updatedBy.Valid {
+		id := int(updatedBy.Int64)
+		m.UpdatedBy = &id
+	}
+	m.Attachments = []models.Attachment{}
+	json.Unmarshal([]byte(attachmentsJSON), &m.Attachments)
+	// Записи сканируются только после проверки доступа к питомцу
+	signPrivateMediaAttachments(database.DB, m.Attachments, m.CreatedBy)
+
+	return &m, nil
+}
+
+// canEditMedicalRecord - запись клиники меняют только её сотрудники, остальные - редакторы питомца
+func canEditMedicalRecord(userID int, pet *models.Pet, record *models.MedicalRecord) bool {
+	if record.ClinicID != nil {
+		return isClinicStaff(userID, *record.ClinicID)
+	}
+	return canEditPet(userID, pet)
+}
+
+// isClinicStaff - пользователь с правом manage_medical_records, состоящий
+// в активной ветклинике с правом редактирования
+func isClinicStaff(userID, clinicID int) bool {
+	var isMember bool
+	database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT EXISTS(
+			SELECT 1 FROM organization_members om
+			JOIN organizations o ON o.id = om.organization_id
+			WHERE om.organization_id = ? AND om.user_id = ? AND om.can_edit = TRUE
+			  AND o.type = 'vet_clinic' AND o.status = 'active'
+		)
+	`), clinicID, userID).Scan(&isMember)
+
+	return isMember && middleware.UserHasPermission(userID, "manage_medical_records")
+}
+
+// checkMedicalRecordAttachments - к записи прикрепляются только файлы медиатеки её автора
+func checkMedicalRecordAttachments(w http.ResponseWriter, req *models.MedicalRecordRequest, authorID int) bool {
+	owned, err := mediaAttachmentsOwnedBy(database.DB, req.Attachments, authorID)
+	if err != nil {
+		log.Printf("❌ Error checking medical record attachments: %v", err)
+		sendErrorResponse(w, "Ошибка проверки вложений", http.StatusInternalServerError)
+		return false
+	}
+	if !owned {
+		sendErrorResponse(w, "Можно прикреплять только файлы из медиатеки автора записи", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func validateMedicalRecord(req *models.MedicalRecordRequest) string {
+	req.Title = strings.TrimSpace(req.Title)
+
+	if !validMedicalRecordTypes[req.RecordType] {
+		return "Недопустимый тип записи"
+	}
+	if req.Title == "" {
+		return "Укажите название процедуры или диагноз"
+	}
+	performedAt, err := time.Parse("2006-01-02", req.PerformedAt)
+	if err != nil {
+		return "Дата процедуры должна быть в формате ГГГГ-ММ-ДД"
+	}
+	if performedAt.After(time.Now()) {
+		return "Дата процедуры не может быть в будущем"
+	}
+	if req.NextDueAt != nil && *req.NextDueAt == "" {
+		req.NextDueAt = nil
+	}
+	if req.NextDueAt != nil {
+		if _, err := time.Parse("2006-01-02", *req.NextDueAt); err != nil {
+			return "Дата следующей процедуры должна быть в формате ГГГГ-ММ-ДД"
+		}
+	}
+	if req.Visibility != models.MedicalVisibilityPrivate && req.Visibility != models.MedicalVisibilityPublic {
+		return "Видимость должна быть private или public"
+	}
+	if req.Attachments == nil {
+		req.Attachments = []models.Attachment{}
+	}
+	// Подписанные ссылки на приватные файлы временные - в записи хранится постоянный URL
+	normalizeMediaAttachments(req.Attachments)
+	return ""
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/storage"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -32,9 +33,8 @@ func GetChatsHandler(db *sql.DB) http.HandlerFunc {
 			SELECT 
 				c.id, c.user1_id, c.user2_id, c.last_message_id, c.last_message_at, c.created_at,
 				u.id as other_user_id, u.name, u.last_name, u.avatar, 
-				CASE WHEN ua.last_seen IS NOT NULL AND ua.last_seen > NOW() - INTERVAL '5 minutes' THEN 1 ELSE 0 END as is_online,
-				ua.last_seen,
-				m.id as msg_id, m.sender_id, m.content, m.is_read, m.created_at as msg_created_at,
+				ua.last_seen, COALESCE(u.show_online, 'yes'),
+				m.id as msg_id, m.sender_id, m.content, m.is_read, m.delivered_at, m.created_at as msg_created_at,
 				COALESCE((
 					SELECT COUNT(*) 
 					FROM messages 
@@ -64,23 +64,25 @@ func GetChatsHandler(db *sql.DB) http.HandlerFunc {
 
 		var chats []models.Chat
 		for rows.Next() {
-			var chat models.Chat
+			chat := models.Chat{Type: models.ChatTypeDirect}
 			var otherUser models.User
 			var lastMessage models.Message
 			var msgID, msgSenderID sql.NullInt64
 			var msgContent sql.NullString
 			var msgIsRead sql.NullBool
+			var msgDeliveredAt sql.NullTime
 			var msgCreatedAt sql.NullString
 			var unreadCount int
 			var avatar sql.NullString
 			var lastSeen sql.NullString
+			var showOnline string
 
 			err := rows.Scan(
 				&chat.ID, &chat.User1ID, &chat.User2ID,
 				&chat.LastMessageID, &chat.LastMessageAt, &chat.CreatedAt,
 				&otherUser.ID, &otherUser.Name, &otherUser.LastName,
-				&avatar, &otherUser.IsOnline, &lastSeen,
-				&msgID, &msgSenderID, &msgContent, &msgIsRead, &msgCreatedAt,
+				&avatar, &lastSeen, &showOnline,
+				&msgID, &msgSenderID, &msgContent, &msgIsRead, &msgDeliveredAt, &msgCreatedAt,
 				&unreadCount,
 			)
 			if err != nil {
@@ -98,6 +100,7 @@ func GetChatsHandler(db *sql.DB) http.HandlerFunc {
 					otherUser.LastSeen = &parsedTime
 				}
 			}
+			otherUser.IsOnline = IsUserOnline(otherUser.ID)
 
 			chat.OtherUser = &otherUser
 			chat.UnreadCount = unreadCount
@@ -114,22 +117,54 @@ func GetChatsHandler(db *sql.DB) http.HandlerFunc {
 				if msgIsRead.Valid {
 					lastMessage.IsRead = msgIsRead.Bool
 				}
+				if msgDeliveredAt.Valid {
+					lastMessage.DeliveredAt = &msgDeliveredAt.Time
+				}
 				if msgCreatedAt.Valid {
 					// Парсим строку в time.Time
 					if t, err := time.Parse("2006-01-02 15:04:05", msgCreatedAt.String); err == nil {
 						lastMessage.CreatedAt = &t
 					}
 				}
+				setMessageStatus(&lastMessage)
+				if lastMessage.SenderID == userID && showOnline == "no" {
+					hideReceipts(&lastMessage)
+				}
 				chat.LastMessage = &lastMessage
 			}
 
 			chats = append(chats, chat)
 		}
 
+		// Групповые чаты и чаты организаций
+		groups, err := getGroupChats(db, userID, 0)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to fetch group chats: %v", err)
+		}
+		chats = append(chats, groups...)
+		sortChatsByActivity(chats)
+
 		if chats == nil {
 			chats = []models.Chat{}
 		}
 
+		// Курсоры прочтения участников ("просмотрено до сообщения X")
+		cursors, err := getChatReadCursors(db, userID, 0)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to fetch read cursors: %v", err)
+		}
+		for i := range chats {
+			chats[i].ReadCursors = cursors[chats[i].ID]
+			if chats[i].Type == models.ChatTypeGroup && chats[i].LastMessage != nil {
+				last := []models.Message{*chats[i].LastMessage}
+				applyGroupReadStatus(last, chats[i].ReadCursors, userID)
+				chats[i].LastMessage.Status = last[0].Status
+			}
+		}
+
+		// Список чатов загружен - входящие сообщения доставлены
+		go markMessagesDelivered(db, userID, 0, 0)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(chats)
 	}
@@ -167,17 +202,30 @@ func GetChatMessagesHandler(db *sql.DB) http.HandlerFunc {
 
 		log.Printf("✅ User %d is in chat %d, fetching messages...", userID, chatID)
 
-		// Получаем сообщения
-		query := ConvertPlaceholders(`
+		// Страница - последние limit сообщений (по умолчанию 50), cursor листает к более старым
+		limit, cursor, err := parseCursorPage(r, 50, 100)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+
+		// Получаем сообщения от новых к старым, ниже разворачиваем в хронологический порядок
+		query := `
 			SELECT 
-				m.id, m.chat_id, m.sender_id, m.receiver_id, 
-				m.content, m.is_read, m.read_at, m.created_at
+				m.id, m.chat_id, m.sender_id, COALESCE(m.receiver_id, 0), 
+				m.content, m.is_read, m.read_at, m.delivered_at, m.created_at
 			FROM messages m
 			WHERE m.chat_id = ?
-			ORDER BY m.created_at ASC
-		`)
+		`
+		args := []interface{}{chatID}
+		if cursor != nil {
+			query += ` AND ` + cursorCondition("m.created_at", "m.id", false)
+			args = append(args, cursor.CreatedAt, cursor.ID)
+		}
+		query += ` ORDER BY m.created_at DESC, m.id DESC LIMIT ?`
+		args = append(args, limit+1)
 
-		rows, err := db.Query(query, chatID)
+		rows, err := db.Query(ConvertPlaceholders(query), args...)
 		if err != nil {
 			log.Printf("❌ Error fetching messages: %v", err)
 			http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
@@ -192,10 +240,11 @@ func GetChatMessagesHandler(db *sql.DB) http.HandlerFunc {
 			rowCount++
 			var msg models.Message
 			var readAtStr, createdAtStr sql.NullString
+			var deliveredAt sql.NullTime
 
 			err := rows.Scan(
 				&msg.ID, &msg.ChatID, &msg.SenderID, &msg.ReceiverID,
-				&msg.Content, &msg.IsRead, &readAtStr, &createdAtStr,
+				&msg.Content, &msg.IsRead, &readAtStr, &deliveredAt, &createdAtStr,
 			)
 			if err != nil {
 				log.Printf("❌ Error scanning message row %d: %v", rowCount, err)
@@ -242,6 +291,10 @@ func GetChatMessagesHandler(db *sql.DB) http.HandlerFunc {
 					}
 				}
 			}
+			if deliveredAt.Valid {
+				msg.DeliveredAt = &deliveredAt.Time
+			}
+			setMessageStatus(&msg)
 			log.Printf("✅ Scanned message %d: ID=%d, Content=%s", rowCount, msg.ID, msg.Content)
 
 			// FIXME: Moved sender/attachments loading outside loop to avoid SQLite deadlock
@@ -249,8 +302,50 @@ func GetChatMessagesHandler(db *sql.DB) http.HandlerFunc {
 			log.Printf("✅ Message %d added to list", msg.ID)
 		}
 
+		// Лишнее сообщение означает, что есть более старая страница
+		nextCursor := ""
+		if len(messages) > limit {
+			messages = messages[:limit]
+			if oldest := messages[len(messages)-1]; oldest.CreatedAt != nil {
+				nextCursor = encodeCursor(*oldest.CreatedAt, oldest.ID)
+			}
+		}
+
+		// Клиент ожидает хронологический порядок
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+
 		log.Printf("✅ Scanned %d messages, now loading senders and attachments...", len(messages))
 
+		// Доставку и прочтение своих сообщений видно, только если собеседник показывает активность
+		peersVisible := map[int]bool{}
+		hasGroupMessages := false
+		for i := range messages {
+			if messages[i].ReceiverID == 0 {
+				hasGroupMessages = true
+				continue
+			}
+			if messages[i].SenderID != userID {
+				continue
+			}
+			visible, checked := peersVisible[messages[i].ReceiverID]
+			if !checked {
+				visible = showsOnlineStatus(db, messages[i].ReceiverID)
+				peersVisible[messages[i].ReceiverID] = visible
+			}
+			if !visible {
+				hideReceipts(&messages[i])
+			}
+		}
+		// В группе сообщение прочитано, если его просмотрел кто-то из участников
+		if hasGroupMessages {
+			cursors, err := getChatReadCursors(db, userID, chatID)
+			if err == nil {
+				applyGroupReadStatus(messages, cursors[chatID], userID)
+			}
+		}
+
 		// Загружаем отправителей и attachments после закрытия rows
 		for i := range messages {
 			log.Printf("🔍 Loading data for message %d", messages[i].ID)
@@ -262,14 +357,19 @@ func GetChatMessagesHandler(db *sql.DB) http.HandlerFunc {
 			messages[i].Attachments = attachments
 		}
 
-		// Отмечаем все сообщения как прочитанные
-		go markMessagesAsRead(db, chatID, userID)
+		// Открыта последняя страница - пользователь увидел сообщения до самого нового.
+		// Более старые страницы курсор прочтения не двигают.
+		if cursor == nil && len(messages) > 0 {
+			go markMessagesAsRead(db, chatID, userID, messages[len(messages)-1].ID)
+		}
 
 		if messages == nil {
 			messages = []models.Message{}
 		}
 		log.Printf("✅ Returning %d messages for chat %d", len(messages), chatID)
 
+		// Ответ - массив без обёртки, поэтому курсор только в заголовке
+		setNextCursor(w, nextCursor)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(messages)
 	}
@@ -284,8 +384,10 @@ func SendMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// receiver_id - личное сообщение, chat_id - сообщение в существующий (в т.ч. групповой) чат
 		var req struct {
 			ReceiverID int    `json:"receiver_id"`
+			ChatID     int    `json:"chat_id"`
 			Content    string `json:"content"`
 		}
 
@@ -294,38 +396,25 @@ func SendMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		if req.ReceiverID == 0 || req.Content == "" {
+		if req.Content == "" {
 			http.Error(w, "Receiver ID and content are required", http.StatusBadRequest)
 			return
 		}
 
-		if req.ReceiverID == userID {
-			http.Error(w, "Cannot send message to yourself", http.StatusBadRequest)
-			return
-		}
-
-		// Проверяем, существует ли получатель
-		receiverExists, err := userExists(db, req.ReceiverID)
-		if err != nil || !receiverExists {
-			http.Error(w, "Receiver not found", http.StatusNotFound)
-			return
-		}
-
-		// Ищем или создаем чат
-		chatID, err := getOrCreateChat(db, userID, req.ReceiverID)
-		if err != nil {
-			log.Printf("❌ Error getting/creating chat: %v", err)
-			http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		target, status, errMsg := resolveMessageTarget(db, userID, req.ChatID, req.ReceiverID)
+		if target == nil {
+			http.Error(w, errMsg, status)
 			return
 		}
+		chatID := target.ChatID
 
 		// Создаем сообщение
 		var messageID int
-		err = db.QueryRow(ConvertPlaceholders(`
+		err := db.QueryRow(ConvertPlaceholders(`
 			INSERT INTO messages (chat_id, sender_id, receiver_id, content, created_at)
 			VALUES (?, ?, ?, ?, ?)
 			RETURNING id
-		`), chatID, userID, req.ReceiverID, req.Content, time.Now()).Scan(&messageID)
+		`), chatID, userID, target.receiverArg(), req.Content, time.Now()).Scan(&messageID)
 
 		if err != nil {
 			log.Printf("❌ Error creating message: %v", err)
@@ -352,7 +441,10 @@ func SendMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		log.Printf("✅ Message sent: user %d -> user %d in chat %d", userID, req.ReceiverID, chatID)
+		log.Printf("✅ Message sent: user %d -> chat %d (%d recipients)", userID, chatID, len(target.Recipients))
+
+		// Получатели видят сообщение сразу, без опроса
+		target.notify(message)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(message)
@@ -368,13 +460,7 @@ func GetUnreadCountHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		var count int
-		err := db.QueryRow(ConvertPlaceholders(`
-			SELECT COUNT(*) 
-			FROM messages 
-			WHERE receiver_id = ? AND is_read = FALSE
-		`), userID).Scan(&count)
-
+		count, err := countUnreadMessages(db, userID)
 		if err != nil {
 			log.Printf("❌ Error counting unread messages: %v", err)
 			sendErrorResponse(w, "Failed to count unread messages", http.StatusInternalServerError)
@@ -398,22 +484,26 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Получаем receiver_id
+		// Получаем receiver_id (личное сообщение) или chat_id (существующий чат)
 		receiverIDStr := r.FormValue("receiver_id")
-		if receiverIDStr == "" {
+		chatIDStr := r.FormValue("chat_id")
+		if receiverIDStr == "" && chatIDStr == "" {
 			http.Error(w, "Receiver ID is required", http.StatusBadRequest)
 			return
 		}
 
-		receiverID, err := strconv.Atoi(receiverIDStr)
-		if err != nil {
-			http.Error(w, "Invalid receiver ID", http.StatusBadRequest)
-			return
+		var receiverID, requestedChatID int
+		if receiverIDStr != "" {
+			if receiverID, err = strconv.Atoi(receiverIDStr); err != nil {
+				http.Error(w, "Invalid receiver ID", http.StatusBadRequest)
+				return
+			}
 		}
-
-		if receiverID == userID {
-			http.Error(w, "Cannot send message to yourself", http.StatusBadRequest)
-			return
+		if chatIDStr != "" {
+			if requestedChatID, err = strconv.Atoi(chatIDStr); err != nil {
+				http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+				return
+			}
 		}
 
 		// Получаем текст сообщения (опционально)
@@ -426,20 +516,23 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Проверяем, существует ли получатель
-		receiverExists, err := userExists(db, receiverID)
-		if err != nil || !receiverExists {
-			http.Error(w, "Receiver not found", http.StatusNotFound)
+		target, status, errMsg := resolveMessageTarget(db, userID, requestedChatID, receiverID)
+		if target == nil {
+			http.Error(w, errMsg, status)
 			return
 		}
+		chatID := target.ChatID
 
-		// Ищем или создаем чат
-		chatID, err := getOrCreateChat(db, userID, receiverID)
-		if err != nil {
-			log.Printf("❌ Error getting/creating chat: %v", err)
-			http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		// Вложения сообщений учитываются в квоте хранилища отправителя
+		var totalSize int64
+		for _, fileHeader := range files {
+			totalSize += fileHeader.Size
+		}
+		releaseQuota, ok := reserveStorageQuota(w, db, userID, totalSize)
+		if !ok {
 			return
 		}
+		defer releaseQuota()
 
 		// Создаем сообщение
 		var messageID int
@@ -447,7 +540,7 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 			INSERT INTO messages (chat_id, sender_id, receiver_id, content, created_at)
 			VALUES (?, ?, ?, ?, ?)
 			RETURNING id
-		`), chatID, userID, receiverID, content, time.Now()).Scan(&messageID)
+		`), chatID, userID, target.receiverArg(), content, time.Now()).Scan(&messageID)
 
 		if err != nil {
 			log.Printf("❌ Error creating message: %v", err)
@@ -478,7 +571,7 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 			log.Printf("📎 File type detected: %s (Content-Type: %s)", fileType, contentType)
 
 			// Сохраняем файл
-			filePath, err := saveUploadedFile(file, fileHeader.Filename)
+			filePath, err := saveMessageAttachment(file, fileHeader.Filename, contentType, chatID)
 			if err != nil {
 				log.Printf("❌ Error saving file: %v", err)
 				continue
@@ -500,7 +593,7 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 			attachments = append(attachments, models.MessageAttachment{
 				ID:        attachID,
 				MessageID: messageID,
-				FilePath:  filePath,
+				FilePath:  messageAttachmentURL(filePath),
 				FileType:  fileType,
 				FileSize:  int(fileHeader.Size),
 				CreatedAt: time.Now(),
@@ -529,7 +622,9 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 		// Добавляем attachments к сообщению
 		message.Attachments = attachments
 
-		log.Printf("✅ Media message sent: user %d -> user %d in chat %d (%d attachments)", userID, receiverID, chatID, len(attachments))
+		log.Printf("✅ Media message sent: user %d -> chat %d (%d attachments)", userID, chatID, len(attachments))
+
+		target.notify(message)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(message)
@@ -538,6 +633,77 @@ func SendMediaMessageHandler(db *sql.DB) http.HandlerFunc {
 
 // Вспомогательные функции
 
+// messageTarget - чат, в который отправляется сообщение
+type messageTarget struct {
+	ChatID     int
+	ReceiverID int   // 0 - групповой чат
+	Recipients []int // кому доставить new_message
+}
+
+// receiverArg - значение receiver_id для INSERT (NULL в групповом чате)
+func (t *messageTarget) receiverArg() interface{} {
+	if t.ReceiverID == 0 {
+		return nil
+	}
+	return t.ReceiverID
+}
+
+// notify отправляет новое сообщение и счётчик непрочитанных получателям
+func (t *messageTarget) notify(message *models.Message) {
+	for _, recipientID := range t.Recipients {
+		NotifyNewMessage(recipientID, message)
+		NotifyUnreadCount(recipientID)
+	}
+}
+
+// resolveMessageTarget находит чат по chatID (личный или групповой) или создаёт
+// личный чат с receiverID. При ошибке возвращает nil, HTTP статус и текст ошибки.
+func resolveMessageTarget(db *sql.DB, senderID, chatID, receiverID int) (*messageTarget, int, string) {
+	if chatID == 0 {
+		if receiverID == 0 {
+			return nil, http.StatusBadRequest, "Receiver ID is required"
+		}
+		if receiverID == senderID {
+			return nil, http.StatusBadRequest, "Cannot send message to yourself"
+		}
+		// Проверяем, существует ли получатель
+		exists, err := userExists(db, receiverID)
+		if err != nil || !exists {
+			return nil, http.StatusNotFound, "Receiver not found"
+		}
+		// Ищем или создаем чат
+		chatID, err := getOrCreateChat(db, senderID, receiverID)
+		if err != nil {
+			log.Printf("❌ Error getting/creating chat: %v", err)
+			return nil, http.StatusInternalServerError, "Failed to create chat"
+		}
+		return &messageTarget{ChatID: chatID, ReceiverID: receiverID, Recipients: []int{receiverID}}, http.StatusOK, ""
+	}
+
+	if !isUserInChat(db, chatID, senderID) {
+		return nil, http.StatusForbidden, "Access denied"
+	}
+	info, err := getChatInfo(db, chatID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load chat"
+	}
+	participants, err := chatParticipantIDs(db, chatID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load chat"
+	}
+
+	target := &messageTarget{ChatID: chatID}
+	for _, id := range participants {
+		if id != senderID {
+			target.Recipients = append(target.Recipients, id)
+		}
+	}
+	if info.Type != models.ChatTypeGroup && len(target.Recipients) == 1 {
+		target.ReceiverID = target.Recipients[0]
+	}
+	return target, http.StatusOK, ""
+}
+
 func getOrCreateChat(db *sql.DB, user1ID, user2ID int) (int, error) {
 	// Нормализуем порядок пользователей (меньший ID всегда первый)
 	if user1ID > user2ID {
@@ -573,9 +739,12 @@ func getOrCreateChat(db *sql.DB, user1ID, user2ID int) (int, error) {
 func isUserInChat(db *sql.DB, chatID, userID int) bool {
 	var count int
 	err := db.QueryRow(ConvertPlaceholders(`
-		SELECT COUNT(*) FROM chats 
-		WHERE id = ? AND (user1_id = ? OR user2_id = ?)
-	`), chatID, userID, userID).Scan(&count)
+		SELECT COUNT(*) FROM chats c
+		WHERE c.id = ? AND (
+			c.user1_id = ? OR c.user2_id = ?
+			OR EXISTS (SELECT 1 FROM chat_members cm WHERE cm.chat_id = c.id AND cm.user_id = ?)
+		)
+	`), chatID, userID, userID, userID).Scan(&count)
 
 	return err == nil && count > 0
 }
@@ -583,16 +752,17 @@ func isUserInChat(db *sql.DB, chatID, userID int) bool {
 func getMessageByID(db *sql.DB, messageID int) (*models.Message, error) {
 	var msg models.Message
 	err := db.QueryRow(ConvertPlaceholders(`
-		SELECT id, chat_id, sender_id, receiver_id, content, is_read, read_at, created_at
+		SELECT id, chat_id, sender_id, COALESCE(receiver_id, 0), content, is_read, read_at, delivered_at, created_at
 		FROM messages WHERE id = ?
 	`), messageID).Scan(
 		&msg.ID, &msg.ChatID, &msg.SenderID, &msg.ReceiverID,
-		&msg.Content, &msg.IsRead, &msg.ReadAt, &msg.CreatedAt,
+		&msg.Content, &msg.IsRead, &msg.ReadAt, &msg.DeliveredAt, &msg.CreatedAt,
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	setMessageStatus(&msg)
 
 	// Получаем отправителя
 	sender, err := getUserByID(db, msg.SenderID)
@@ -640,12 +810,28 @@ func getMessageAttachments(db *sql.DB, messageID int) ([]models.MessageAttachmen
 			log.Printf("⚠️ Error scanning attachment: %v", err)
 			continue
 		}
+		attachment.FilePath = messageAttachmentURL(attachment.FilePath)
 		attachments = append(attachments, attachment)
 	}
 
 	return attachments, nil
 }
 
+// saveMessageAttachment сохраняет вложение сообщения. Если настроен S3, файл
+// кладётся в бакет без публичного доступа и возвращается путь "s3://ключ",
+// иначе - в локальную папку uploads/messages.
+func saveMessageAttachment(file multipart.File, filename, contentType string, chatID int) (string, error) {
+	if !storage.Enabled() {
+		return saveUploadedFile(file, filename)
+	}
+
+	key := fmt.Sprintf("messages/%d/%s%s", chatID, uuid.New().String(), filepath.Ext(filename))
+	if err := storage.GlobalS3Client.PutPrivateObject(file, key, contentType); err != nil {
+		return "", err
+	}
+	return "s3://" + key, nil
+}
+
 func saveUploadedFile(file multipart.File, filename string) (string, error) {
 	// Генерируем уникальное имя файла
 	ext := filepath.Ext(filename)
@@ -683,18 +869,6 @@ func getUnreadCount(db *sql.DB, chatID, userID int) (int, error) {
 	return count, err
 }
 
-func markMessagesAsRead(db *sql.DB, chatID, userID int) {
-	_, err := db.Exec(ConvertPlaceholders(`
-		UPDATE messages 
-		SET is_read = TRUE, read_at = ?
-		WHERE chat_id = ? AND receiver_id = ? AND is_read = FALSE
-	`), time.Now(), chatID, userID)
-
-	if err != nil {
-		log.Printf("⚠️ Warning: Failed to mark messages as read: %v", err)
-	}
-}
-
 func userExists(db *sql.DB, userID int) (bool, error) {
 	var count int
 	err := db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM users WHERE id = ?"), userID).Scan(&count)
@@ -742,11 +916,8 @@ func getUserByID(db *sql.DB, userID int) (*models.User, error) {
 	// Устанавливаем last_seen если есть
 	if lastSeen.Valid {
 		user.LastSeen = &lastSeen.Time
-		// Проверяем онлайн статус (активен в последние 5 минут)
-		user.IsOnline = time.Since(lastSeen.Time) < 5*time.Minute
-	} else {
-		user.IsOnline = false
 	}
+	user.IsOnline = IsUserOnline(user.ID)
 
 	return &user, nil
 }
@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportActionPermissions - какое право нужно для каждого действия модератора
+var reportActionPermissions = map[string]string{
+	models.ReportActionHidePost:      "moderate_posts",
+	models.ReportActionDeleteComment: "moderate_comments",
+	models.ReportActionWarnUser:      "ban_users",
+	models.ReportActionDismiss:       "view_reports",
+}
+
+// ModerationReportsHandler возвращает очередь жалоб для модераторов
+// (маршрут закрыт правом view_reports)
+// GET /api/moderation/reports?status=pending&target_type=post&reason=spam&assigned_to=me
+func ModerationReportsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		currentUserID, ok := r.Context().Value("userID").(int)
+		if !ok {
+			sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+
+		status := q.Get("status")
+		if status == "" {
+			status = models.ReportStatusPending
+		}
+
+		limit := 50
+		if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+		offset := 0
+		if o, err := strconv.Atoi(q.Get("offset")); err == nil && o >= 0 {
+			offset = o
+		}
+
+		query := `
+			SELECT r.id, r.reporter_id, r.target_type, r.target_id, r.reason, r.description,
+			       r.status, r.assigned_to, r.assigned_at, r.resolved_by, r.resolved_at,
+			       r.resolution, r.moderator_note, r.created_at,
+			       u.name, u.last_name, u.avatar
+			FROM reports r
+			LEFT JOIN users u ON u.id = r.reporter_id
+			WHERE 1=1
+		`
+		args := []interface{}{}
+
+		if status != "all" {
+			query += " AND r.status = ?"
+			args = append(args, status)
+		}
+
+		if targetType := q.Get("target_type"); targetType != "" {
+			query += " AND r.target_type = ?"
+			args = append(args, targetType)
+		}
+
+		if reason := q.Get("reason"); reason != "" {
+			query += " AND r.reason = ?"
+			args = append(args, reason)
+		}
+
+		switch assignedTo := q.Get("assigned_to"); assignedTo {
+		case "":
+		case "me":
+			query += " AND r.assigned_to = ?"
+			args = append(args, currentUserID)
+		case "none":
+			query += " AND r.assigned_to IS NULL"
+		default:
+			moderatorID, err := strconv.Atoi(assignedTo)
+			if err != nil {
+				sendErrorResponse(w, "Неверный параметр assigned_to", http.StatusBadRequest)
+				return
+			}
+			query += " AND r.assigned_to = ?"
+			args = append(args, moderatorID)
+		}
+
+		// Очередь: сначала самые старые жалобы
+		query += " ORDER BY r.created_at ASC LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+
+		rows, err := db.Query(ConvertPlaceholders(query), args...)
+		if err != nil {
+			log.Printf("❌ Error fetching reports: %v", err)
+			sendErrorResponse(w, "Ошибка получения жалоб: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		reports := []models.Report{}
+		for rows.Next() {
+			report, err := scanReport(rows, true)
+			if err != nil {
+				log.Printf("❌ Error scanning report: %v", err)
+				continue
+			}
+			reports = append(reports, report)
+		}
+
+		sendSuccessResponse(w, reports)
+	}
+}
+
+// ModerationReportHandler - действия с конкретной жалобой
+// (маршрут закрыт правом view_reports, действия проверяют своё право отдельно)
+// GET  /api/moderation/reports/{id}
+// POST /api/moderation/reports/{id}/assign
+// POST /api/moderation/reports/{id}/resolve
+func ModerationReportHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUserID, ok := r.Context().Value("userID").(int)
+		if !ok {
+			sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/moderation/reports/"), "/")
+		parts := strings.Split(path, "/")
+
+		reportID, err := strconv.Atoi(parts[0])
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID жалобы", http.StatusBadRequest)
+			return
+		}
+
+		action := ""
+		if len(parts) > 1 {
+			action = parts[1]
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			getReport(w, db, reportID)
+		case action == "assign" && r.Method == http.MethodPost:
+			assignReport(w, r, db, currentUserID, reportID)
+		case action == "resolve" && r.Method == http.MethodPost:
+			resolveReport(w, r, db, currentUserID, reportID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// getReport возвращает одну жалобу
+func getReport(w http.ResponseWriter, db *sql.DB, reportID int) {
+	report, err := loadReport(db, reportID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Жалоба не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, report)
+}
+
+// assignReport назначает жалобу модератору (по умолчанию - себе)
+func assignReport(w http.ResponseWriter, r *http.Request, db *sql.DB, currentUserID, reportID int) {
+	var req models.AssignReportRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+	}
+
+	moderatorID := currentUserID
+	if req.ModeratorID != nil {
+		moderatorID = *req.ModeratorID
+	}
+
+	if moderatorID != currentUserID && !middleware.UserHasPermission(moderatorID, "view_reports") {
+		sendErrorResponse(w, "Пользователь не является модератором", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec(ConvertPlaceholders(`
+		UPDATE reports SET status = ?, assigned_to = ?, assigned_at = ?
+		WHERE id = ? AND status IN (?, ?)
+	`), models.ReportStatusInReview, moderatorID, time.Now(), reportID,
+		models.ReportStatusPending, models.ReportStatusInReview)
+	if err != nil {
+		log.Printf("❌ Error assigning report %d: %v", reportID, err)
+		sendErrorResponse(w, "Ошибка назначения жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		sendErrorResponse(w, "Жалоба не найдена или уже рассмотрена", http.StatusNotFound)
+		return
+	}
+
+	var adminEmail, moderatorName string
+	db.QueryRow(ConvertPlaceholders("SELECT email FROM users WHERE id = ?"), currentUserID).Scan(&adminEmail)
+	db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), moderatorID).Scan(&moderatorName)
+
+	CreateAdminLog(
+		currentUserID,
+		adminEmail,
+		models.ActionAssignReport,
+		models.TargetReport,
+		reportID,
+		moderatorName,
+		fmt.Sprintf("Assigned to moderator %d", moderatorID),
+		r.RemoteAddr,
+		r.Header.Get("User-Agent"),
+	)
+
+	report, err := loadReport(db, reportID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения жалобы", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, report)
+}
+
+// resolveReport применяет действие модератора и закрывает жалобу
+// (а также все остальные открытые жалобы на тот же объект)
+func resolveReport(w http.ResponseWriter, r *http.Request, db *sql.DB, currentUserID, reportID int) {
+	var req models.ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	permission, ok := reportActionPermissions[req.Action]
+	if !ok {
+		sendErrorResponse(w, "Неизвестное действие", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.HasPermission(r, permission) {
+		middleware.WriteForbidden(w, permission)
+		return
+	}
+
+	report, err := loadReport(db, reportID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Жалоба не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if report.Status != models.ReportStatusPending && report.Status != models.ReportStatusInReview {
+		sendErrorResponse(w, "Жалоба уже рассмотрена", http.StatusConflict)
+		return
+	}
+
+	// Действие над объектом и закрытие жалоб - в одной транзакции:
+	// объект не меняется без закрытия жалобы и наоборот
+	tx, err := db.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Ошибка закрытия жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var actionType, targetType, targetName string
+	targetID := report.TargetID
+	warnUserID := 0
+
+	switch req.Action {
+	case models.ReportActionHidePost:
+		if report.TargetType != "post" {
+			sendErrorResponse(w, "Жалоба не относится к посту", http.StatusBadRequest)
+			return
+		}
+		// Скрытый пост видит только автор; в ленты, поиск и на стену он не попадает
+		_, err := tx.Exec(ConvertPlaceholders(`
+			UPDATE posts SET status = 'hidden', updated_at = NOW() WHERE id = ? AND is_deleted = FALSE
+		`), report.TargetID)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка скрытия поста: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		actionType, targetType = models.ActionHidePost, models.TargetPost
+		targetName = fmt.Sprintf("Пост #%d", report.TargetID)
+
+	case models.ReportActionDeleteComment:
+		if report.TargetType != "comment" {
+			sendErrorResponse(w, "Жалоба не относится к комментарию", http.StatusBadRequest)
+			return
+		}
+		if _, err := tx.Exec(ConvertPlaceholders("DELETE FROM comments WHERE id = ?"), report.TargetID); err != nil {
+			sendErrorResponse(w, "Ошибка удаления комментария: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		actionType, targetType = models.ActionDeleteComment, models.TargetComment
+		targetName = fmt.Sprintf("Комментарий #%d", report.TargetID)
+
+	case models.ReportActionWarnUser:
+		// Предупреждение отправляется после фиксации транзакции
+		offenderID, err := reportTargetUserID(db, report)
+		if err != nil {
+			sendErrorResponse(w, "Не удалось определить автора контента", http.StatusBadRequest)
+			return
+		}
+		warnUserID = offenderID
+		actionType, targetType, targetID = models.ActionWarnUser, models.TargetUser, offenderID
+		db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), offenderID).Scan(&targetName)
+
+	case models.ReportActionDismiss:
+		actionType, targetType = models.ActionDismissReport, models.TargetReport
+		targetID = report.ID
+		targetName = fmt.Sprintf("%s #%d", report.TargetType, report.TargetID)
+	}
+
+	newStatus := models.ReportStatusResolved
+	if req.Action == models.ReportActionDismiss {
+		newStatus = models.ReportStatusDismissed
+	}
+
+	// Закрываем все открытые жалобы на этот объект
+	rows, err := tx.Query(ConvertPlaceholders(`
+		UPDATE reports
+		SET status = ?, resolved_by = ?, resolved_at = ?, resolution = ?, moderator_note = ?
+		WHERE target_type = ? AND target_id = ? AND status IN (?, ?)
+		RETURNING id, reporter_id
+	`), newStatus, currentUserID, time.Now(), req.Action, req.Note,
+		report.TargetType, report.TargetID, models.ReportStatusPending, models.ReportStatusInReview)
+	if err != nil {
+		log.Printf("❌ Error resolving report %d: %v", reportID, err)
+		sendErrorResponse(w, "Ошибка закрытия жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type closedReport struct {
+		id         int
+		reporterID int
+	}
+	var closed []closedReport
+	for rows.Next() {
+		var c closedReport
+		if err := rows.Scan(&c.id, &c.reporterID); err != nil {
+			rows.Close()
+			sendErrorResponse(w, "Ошибка закрытия жалобы: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		closed = append(closed, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		sendErrorResponse(w, "Ошибка закрытия жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Жалобу успел закрыть другой модератор - его решение не перезаписываем
+	if len(closed) == 0 {
+		sendErrorResponse(w, "Жалоба уже рассмотрена", http.StatusConflict)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("❌ Error committing report %d resolution: %v", reportID, err)
+		sendErrorResponse(w, "Ошибка закрытия жалобы: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if warnUserID != 0 {
+		message := "Модератор вынес вам предупреждение за нарушение правил платформы"
+		if req.Note != "" {
+			message += ": " + req.Note
+		}
+		notifHandler := &NotificationsHandler{DB: db}
+		if err := notifHandler.CreateNotification(warnUserID, currentUserID, "moderation_warning", report.TargetType, report.TargetID, message); err != nil {
+			log.Printf("⚠️ Failed to notify user %d about warning: %v", warnUserID, err)
+		}
+		CreateUserLog(db, warnUserID, "moderation_warning", message, r.RemoteAddr, r.Header.Get("User-Agent"))
+	}
+
+	// Лог модератора
+	var adminEmail string
+	db.QueryRow(ConvertPlaceholders("SELECT email FROM users WHERE id = ?"), currentUserID).Scan(&adminEmail)
+
+	details := fmt.Sprintf("Report #%d (%s), closed reports: %d", report.ID, report.Reason, len(closed))
+	if req.Note != "" {
+		details += ", Note: " + req.Note
+	}
+	CreateAdminLog(
+		currentUserID,
+		adminEmail,
+		actionType,
+		targetType,
+		targetID,
+		targetName,
+		details,
+		r.RemoteAddr,
+		r.Header.Get("User-Agent"),
+	)
+
+	// Уведомляем авторов жалоб о результате
+	message := "Ваша жалоба рассмотрена: меры приняты"
+	if newStatus == models.ReportStatusDismissed {
+		message = "Ваша жалоба рассмотрена: нарушений не обнаружено"
+	}
+	notifHandler := &NotificationsHandler{DB: db}
+	for _, c := range closed {
+		if err := notifHandler.CreateNotification(c.reporterID, currentUserID, "report_resolved", "report", c.id, message); err != nil {
+			log.Printf("⚠️ Failed to notify reporter %d: %v", c.reporterID, err)
+		}
+	}
+
+	log.Printf("✅ Report %d resolved by moderator %d: action=%s, closed=%d", reportID, currentUserID, req.Action, len(closed))
+
+	updated, err := loadReport(db, reportID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения жалобы", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, updated)
+}
+
+// reportTargetUserID определяет пользователя, ответственного за объект жалобы
+func reportTargetUserID(db *sql.DB, report models.Report) (int, error) {
+	var userID int
+	switch report.TargetType {
+	case "user":
+		return report.TargetID, nil
+	case "post":
+		err := db.QueryRow(ConvertPlaceholders(`
+			SELECT author_id FROM posts WHERE id = ? AND author_type = 'user'
+		`), report.TargetID).Scan(&userID)
+		return userID, err
+	case "comment":
+		err := db.QueryRow(ConvertPlaceholders("SELECT user_id FROM comments WHERE id = ?"), report.TargetID).Scan(&userID)
+		return userID, err
+	case "pet":
+		err := db.QueryRow(ConvertPlaceholders("SELECT user_id FROM pets WHERE id = ?"), report.TargetID).Scan(&userID)
+		return userID, err
+	}
+	return 0, fmt.Errorf("unsupported target type: %s", report.TargetType)
+}
+
+// loadReport загружает жалобу по ID
+func loadReport(db *sql.DB, reportID int) (models.Report, error) {
+	row := db.QueryRow(ConvertPlaceholders(`
+		SELECT id, reporter_id, target_type, target_id, reason, description,
+		       status, assigned_to, assigned_at, resolved_by, resolved_at,
+		       resolution, moderator_note, created_at
+		FROM reports
+		WHERE id = ?
+	`), reportID)
+
+	return scanReport(row, false)
+}
+
+// scanReport сканирует строку таблицы reports (опционально с данными автора жалобы)
+func scanReport(row interface {
+	Scan(dest ...interface{}) error
+}, withReporter bool) (models.Report, error) {
+	var report models.Report
+	var description, resolution, modNote sql.NullString
+	var assignedTo, resolvedBy sql.NullInt64
+	var assignedAt, resolvedAt sql.NullTime
+
+	dest := []interface{}{
+		&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.Reason, &description,
+		&report.Status, &assignedTo, &assignedAt, &resolvedBy, &resolvedAt,
+		&resolution, &modNote, &report.CreatedAt,
+	}
+
+	var reporterName, reporterLastName, reporterAvatar sql.NullString
+	if withReporter {
+		dest = append(dest, &reporterName, &reporterLastName, &reporterAvatar)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return report, err
+	}
+
+	if description.Valid {
+		report.Description = description.String
+	}
+	if assignedTo.Valid {
+		id := int(assignedTo.Int64)
+		report.AssignedTo = &id
+	}
+	if assignedAt.Valid {
+		report.AssignedAt = &assignedAt.Time
+	}
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		report.ResolvedBy = &id
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	if resolution.Valid {
+		report.Resolution = &resolution.String
+	}
+	if modNote.Valid && modNote.String != "" {
+		report.ModNote = &modNote.String
+	}
+
+	if withReporter && reporterName.Valid {
+		report.Reporter = &models.User{
+			ID:       report.ReporterID,
+			Name:     reporterName.String,
+			LastName: reporterLastName.String,
+			Avatar:   reporterAvatar.String,
+		}
+	}
+
+	return report, nil
+}
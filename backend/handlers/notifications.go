@@ -55,18 +55,29 @@ func (h *NotificationsHandler) GetNotifications(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	query := convertPlaceholdersNotif(`
+	limit, cursor, err := parseCursorPage(r, 50, 100)
+	if err != nil {
+		http.Error(w, `{"success":false,"error":"Invalid cursor"}`, http.StatusBadRequest)
+		return
+	}
+
+	query := `
 		SELECT n.id, n.user_id, n.type, n.actor_id, n.entity_type, n.entity_id, 
 		       n.message, n.is_read, n.created_at,
 		       u.id, u.name, u.last_name, u.email, u.avatar
 		FROM notifications n
 		LEFT JOIN users u ON n.actor_id = u.id
 		WHERE n.user_id = ?
-		ORDER BY n.created_at DESC
-		LIMIT 50
-	`)
+	`
+	args := []interface{}{userID}
+	if cursor != nil {
+		query += ` AND ` + cursorCondition("n.created_at", "n.id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	query += ` ORDER BY n.created_at DESC, n.id DESC LIMIT ?`
+	args = append(args, limit+1)
 
-	rows, err := h.DB.Query(query, userID)
+	rows, err := h.DB.Query(convertPlaceholdersNotif(query), args...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -108,11 +119,14 @@ func (h *NotificationsHandler) GetNotifications(w http.ResponseWriter, r *http.R
 		notifications = append(notifications, n)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    notifications,
-	})
+	nextCursor := ""
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	sendPageResponse(w, notifications, nextCursor)
 }
 
 // GetUnreadCount - получить количество непрочитанных уведомлений
@@ -152,7 +152,10 @@ func GetOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendJSONSuccess(w, org)
+	sendJSONSuccess(w, struct {
+		models.Organization
+		FollowersCount int `json:"followers_count"`
+	}{org, countFollowers(database.DB, models.FollowTargetOrganization, org.ID)})
 }
 
 // GetAllOrganizationsHandler получает все активные организации
@@ -208,6 +211,96 @@ func GetAllOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONSuccess(w, organizations)
 }
 
+// GetNearbyOrganizationsHandler ищет активные организации в радиусе от точки (по умолчанию приюты)
+// GET /api/organizations/nearby?lat=55.75&lon=37.61&radius_km=10&type=shelter|all
+func GetNearbyOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	geo, err := parseGeoQuery(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if geo == nil {
+		sendJSONError(w, http.StatusBadRequest, "lat and lon are required")
+		return
+	}
+
+	typeFilter := r.URL.Query().Get("type")
+	if typeFilter == "" {
+		typeFilter = "shelter"
+	}
+
+	box, boxArgs := geo.boxCondition("geo_lat", "geo_lon")
+	distance := distanceSQL("geo_lat", "geo_lon")
+
+	query := `
+		SELECT 
+			id, name, short_name, type, logo, bio,
+			address_city, address_region, address_full, geo_lat, geo_lon,
+			is_verified, created_at, ` + distance + ` AS distance_km
+		FROM organizations
+		WHERE status = 'active'
+		  AND ` + box + `
+		  AND ` + distance + ` <= ?
+	`
+	args := []interface{}{geo.Lat, geo.Lat, geo.Lon}
+	args = append(args, boxArgs...)
+	args = append(args, geo.Lat, geo.Lat, geo.Lon, geo.RadiusKm)
+
+	if typeFilter != "all" {
+		query += " AND type = ?"
+		args = append(args, typeFilter)
+	}
+
+	query += " ORDER BY distance_km ASC LIMIT 100"
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to get organizations: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	organizations := []map[string]interface{}{}
+	for rows.Next() {
+		var id int
+		var name string
+		var shortName, orgType, logo, bio, city, region, address sql.NullString
+		var lat, lon, distanceKm float64
+		var isVerified bool
+		var createdAt time.Time
+
+		err := rows.Scan(&id, &name, &shortName, &orgType, &logo, &bio, &city, &region, &address,
+			&lat, &lon, &isVerified, &createdAt, &distanceKm)
+		if err != nil {
+			continue
+		}
+
+		organizations = append(organizations, map[string]interface{}{
+			"id":             id,
+			"name":           name,
+			"short_name":     shortName.String,
+			"type":           orgType.String,
+			"logo":           logo.String,
+			"bio":            bio.String,
+			"address_city":   city.String,
+			"address_region": region.String,
+			"address_full":   address.String,
+			"geo_lat":        lat,
+			"geo_lon":        lon,
+			"is_verified":    isVerified,
+			"created_at":     createdAt,
+			"distance_km":    distanceKm,
+		})
+	}
+
+	sendJSONSuccess(w, organizations)
+}
+
 // UpdateOrganizationHandler обновляет организацию
 func UpdateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
@@ -545,6 +638,10 @@ func AddMemberHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := syncOrganizationChat(database.DB, req.OrganizationID); err != nil {
+		log.Printf("⚠️ Failed to sync organization %d chat: %v", req.OrganizationID, err)
+	}
+
 	sendJSONSuccess(w, map[string]interface{}{"message": "Member added successfully"})
 }
 
@@ -609,6 +706,10 @@ func UpdateMemberHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := syncOrganizationChat(database.DB, orgID); err != nil {
+		log.Printf("⚠️ Failed to sync organization %d chat: %v", orgID, err)
+	}
+
 	sendJSONSuccess(w, map[string]interface{}{"message": "Member updated successfully"})
 }
 
@@ -671,9 +772,104 @@ func RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := syncOrganizationChat(database.DB, orgID); err != nil {
+		log.Printf("⚠️ Failed to sync organization %d chat: %v", orgID, err)
+	}
+
 	sendJSONSuccess(w, map[string]interface{}{"message": "Member removed successfully"})
 }
 
+// OrganizationChatHandler - чат команды организации, участники которого
+// синхронизируются с organization_members:
+// GET ?organization_id=1 - ID чата (для участников организации)
+// POST {"organization_id": 1} - создать чат (can_manage_members)
+// DELETE {"organization_id": 1} - отвязать чат: он остаётся обычной группой
+func OrganizationChatHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	if userID == 0 {
+		sendJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var orgID int
+	if r.Method == http.MethodGet {
+		orgID, _ = strconv.Atoi(r.URL.Query().Get("organization_id"))
+	} else {
+		var req struct {
+			OrganizationID int `json:"organization_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+			return
+		}
+		orgID = req.OrganizationID
+	}
+	if orgID == 0 {
+		sendJSONError(w, http.StatusBadRequest, "organization_id is required")
+		return
+	}
+
+	var canManage bool
+	err := database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT can_manage_members FROM organization_members
+		WHERE organization_id = ? AND user_id = ?
+	`), orgID, userID).Scan(&canManage)
+	if err == sql.ErrNoRows {
+		sendJSONError(w, http.StatusForbidden, "You are not a member of this organization")
+		return
+	}
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var chatID sql.NullInt64
+		database.DB.QueryRow(ConvertPlaceholders("SELECT id FROM chats WHERE organization_id = ?"), orgID).Scan(&chatID)
+		sendJSONSuccess(w, map[string]interface{}{"chat_id": nullIntPtr(chatID)})
+
+	case http.MethodPost:
+		if !canManage {
+			sendJSONError(w, http.StatusForbidden, "You don't have permission to manage members")
+			return
+		}
+		chatID, err := createOrganizationChat(database.DB, orgID, userID)
+		if err != nil {
+			sendJSONError(w, http.StatusInternalServerError, "Failed to create chat: "+err.Error())
+			return
+		}
+		notifyChatMembers(database.DB, chatID, "chat_added", map[string]int{"chat_id": chatID})
+		sendJSONSuccess(w, map[string]interface{}{"chat_id": chatID})
+
+	case http.MethodDelete:
+		if !canManage {
+			sendJSONError(w, http.StatusForbidden, "You don't have permission to manage members")
+			return
+		}
+		var chatID int
+		err := database.DB.QueryRow(ConvertPlaceholders(`
+			UPDATE chats SET organization_id = NULL WHERE organization_id = ? RETURNING id
+		`), orgID).Scan(&chatID)
+		if err == sql.ErrNoRows {
+			sendJSONError(w, http.StatusNotFound, "Organization chat not found")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, http.StatusInternalServerError, "Failed to unlink chat: "+err.Error())
+			return
+		}
+		notifyChatMembers(database.DB, chatID, "chat_updated", map[string]interface{}{
+			"chat_id":         chatID,
+			"organization_id": nil,
+		})
+		sendJSONSuccess(w, map[string]interface{}{"chat_id": chatID})
+
+	default:
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
 // GetMyOrganizationsHandler возвращает организации пользователя где он owner или admin
 func GetMyOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
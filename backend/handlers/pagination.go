@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NextCursorHeader - заголовок с курсором следующей страницы.
+// Дублирует поле next_cursor для ответов без обёртки (например, сообщения чата).
+const NextCursorHeader = "X-Next-Cursor"
+
+// pageCursor - позиция в списке: (created_at, id) последнего отданного элемента.
+// id разрешает совпадения created_at, поэтому элементы не дублируются и не теряются,
+// даже если между запросами появился новый контент.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+
+	// Для ранжированной ленты: снимок выдачи и позиция в нём.
+	// Chrono - снимок закончился, дальше лента идёт по (created_at, id).
+	Snapshot int64 `json:"s,omitempty"`
+	Offset   int   `json:"o,omitempty"`
+	Chrono   bool  `json:"c,omitempty"`
+
+	// Для поиска по радиусу: выдача идёт по (distance_km, id)
+	DistanceKm float64 `json:"d,omitempty"`
+}
+
+// encodeCursor возвращает непрозрачный курсор для клиента
+func encodeCursor(createdAt time.Time, id int) string {
+	data, _ := json.Marshal(pageCursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// encodeCursorString - то же для моделей, где created_at хранится строкой (RFC3339)
+func encodeCursorString(createdAt string, id int) string {
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return ""
+	}
+	return encodeCursor(t, id)
+}
+
+// encodeDistanceCursor - курсор выдачи по расстоянию от точки поиска
+func encodeDistanceCursor(distanceKm float64, id int) string {
+	data, _ := json.Marshal(pageCursor{DistanceKm: distanceKm, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// parseCursorPage читает ?limit= и ?cursor= из запроса.
+// Некорректный limit заменяется на defaultLimit, некорректный cursor - ошибка.
+func parseCursorPage(r *http.Request, defaultLimit, maxLimit int) (int, *pageCursor, error) {
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= maxLimit {
+			limit = parsedLimit
+		}
+	}
+
+	cursorStr := r.URL.Query().Get("cursor")
+	if cursorStr == "" {
+		return limit, nil, nil
+	}
+
+	cursor, err := decodeCursor(cursorStr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return limit, cursor, nil
+}
+
+// cursorCondition - условие "после курсора" для сортировки по (created_at, id).
+// Параметры: cursor.CreatedAt, cursor.ID.
+func cursorCondition(createdAtColumn, idColumn string, ascending bool) string {
+	if ascending {
+		return fmt.Sprintf("(%s, %s) > (?, ?)", createdAtColumn, idColumn)
+	}
+	return fmt.Sprintf("(%s, %s) < (?, ?)", createdAtColumn, idColumn)
+}
+
+// setNextCursor выставляет заголовок X-Next-Cursor (пустой курсор - последняя страница)
+func setNextCursor(w http.ResponseWriter, nextCursor string) {
+	if nextCursor != "" {
+		w.Header().Set(NextCursorHeader, nextCursor)
+	}
+}
+
+// sendPageResponse - sendSuccessResponse с курсором следующей страницы
+func sendPageResponse(w http.ResponseWriter, data interface{}, nextCursor string) {
+	setNextCursor(w, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Response{
+		Success:    true,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
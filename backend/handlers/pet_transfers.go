@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"backend/models"
+	"database"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const petTransferColumns = `
+	t.id, t.pet_id, t.from_user_id, t.from_organization_id, t.to_user_id, t.to_organization_id,
+	t.offered_by, t.message, t.status, t.responded_by, t.created_at, t.responded_at,
+	coalesce(p.name, ''), coalesce(p.photo, '')`
+
+// PetTransfersHandler - входящие и исходящие предложения передачи питомцев
+// GET /api/pet-transfers?direction=incoming|outgoing&status=pending
+func PetTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	// Передачи организаций видят её редакторы
+	editableOrgs := `SELECT organization_id FROM organization_members WHERE user_id = ? AND can_edit = TRUE`
+
+	query := `SELECT ` + petTransferColumns + ` FROM pet_transfers t LEFT JOIN pets p ON p.id = t.pet_id WHERE `
+	args := []interface{}{}
+	if r.URL.Query().Get("direction") == "outgoing" {
+		query += `(t.from_user_id = ? AND t.from_organization_id IS NULL OR t.from_organization_id IN (` + editableOrgs + `))`
+	} else {
+		query += `(t.to_user_id = ? OR t.to_organization_id IN (` + editableOrgs + `))`
+	}
+	args = append(args, userID, userID)
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.PetTransferPending
+	}
+	if status != "all" {
+		query += ` AND t.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY t.created_at DESC, t.id DESC LIMIT 100`
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения передач: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	transfers := []models.PetTransfer{}
+	for rows.Next() {
+		t, err := scanPetTransfer(rows)
+		if err != nil {
+			log.Printf("❌ Error scanning pet transfer: %v", err)
+			continue
+		}
+		transfers = append(transfers, *t)
+	}
+
+	sendSuccessResponse(w, transfers)
+}
+
+// PetTransferHandler - ответ на предложение передачи
+// POST /api/pet-transfers/{id}/accept - получатель принимает питомца
+// POST /api/pet-transfers/{id}/decline - получатель отказывается
+// POST /api/pet-transfers/{id}/cancel - отправитель отзывает предложение
+func PetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pet-transfers/"), "/"), "/")
+	if len(parts) != 2 {
+		sendErrorResponse(w, "Неверный URL", http.StatusBadRequest)
+		return
+	}
+	transferID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		sendErrorResponse(w, "Неверный ID передачи", http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := loadPetTransfer(transferID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Передача не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch parts[1] {
+	case "accept", "decline":
+		if !isTransferRecipient(userID, transfer) {
+			sendErrorResponse(w, "Предложение адресовано не вам", http.StatusForbidden)
+			return
+		}
+	case "cancel":
+		pet, err := loadPet(transfer.PetID)
+		if err != nil || !canEditPet(userID, &pet) {
+			sendErrorResponse(w, "Нет прав на отзыв предложения", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	respondToPetTransfer(w, userID, transfer, parts[1])
+}
+
+// handlePetTransferOffer - POST /api/pets/{id}/transfer
+func handlePetTransferOffer(w http.ResponseWriter, r *http.Request, petID int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+	if !canEditPet(userID, &pet) {
+		sendErrorResponse(w, "Нет прав на передачу этого питомца", http.StatusForbidden)
+		return
+	}
+
+	var req models.CreatePetTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if (req.ToUserID == nil) == (req.ToOrganizationID == nil) {
+		sendErrorResponse(w, "Укажите получателя: to_user_id или to_organization_id", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if req.ToUserID != nil {
+		if pet.OrganizationID == nil && *req.ToUserID == pet.UserID {
+			sendErrorResponse(w, "Питомец уже принадлежит этому пользователю", http.StatusBadRequest)
+			return
+		}
+		database.DB.QueryRow(ConvertPlaceholders("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)"), *req.ToUserID).Scan(&exists)
+	} else {
+		if pet.OrganizationID != nil && *req.ToOrganizationID == *pet.OrganizationID {
+			sendErrorResponse(w, "Питомец уже принадлежит этой организации", http.StatusBadRequest)
+			return
+		}
+		database.DB.QueryRow(ConvertPlaceholders("SELECT EXISTS(SELECT 1 FROM organizations WHERE id = ? AND status = 'active')"), *req.ToOrganizationID).Scan(&exists)
+	}
+	if !exists {
+		sendErrorResponse(w, "Получатель не найден", http.StatusNotFound)
+		return
+	}
+
+	var transferID int
+	err = database.DB.QueryRow(ConvertPlaceholders(`
+		INSERT INTO pet_transfers (pet_id, from_user_id, from_organization_id, to_user_id, to_organization_id, offered_by, message, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), petID, pet.UserID, pet.OrganizationID, req.ToUserID, req.ToOrganizationID, userID,
+		strings.TrimSpace(req.Message), models.PetTransferPending).Scan(&transferID)
+	if err != nil {
+		// idx_pet_transfers_pending: у питомца может быть только одно открытое предложение
+		if strings.Contains(err.Error(), "idx_pet_transfers_pending") {
+			sendErrorResponse(w, "У питомца уже есть неподтверждённое предложение передачи", http.StatusConflict)
+			return
+		}
+		sendErrorResponse(w, "Ошибка создания передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	transfer, err := loadPetTransfer(transferID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения передачи", http.StatusInternalServerError)
+		return
+	}
+
+	notifyPetTransfer(userID, transfer, fmt.Sprintf("Вам предлагают принять питомца %s", pet.Name), true)
+
+	log.Printf("🔁 Pet %d transfer %d offered by user %d", petID, transferID, userID)
+	sendSuccessResponse(w, transfer)
+}
+
+// handlePetProvenance - GET /api/pets/{id}/provenance, история владельцев и кураторов
+func handlePetProvenance(w http.ResponseWriter, r *http.Request, petID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+	if !canEditPet(userID, &pet) {
+		sendErrorResponse(w, "Нет прав на просмотр истории владения", http.StatusForbidden)
+		return
+	}
+
+	rows, err := database.DB.Query(ConvertPlaceholders(`
+		SELECT h.id, h.pet_id, h.from_user_id, trim(coalesce(fu.name, '') || ' ' || coalesce(fu.last_name, '')),
+		       h.from_organization_id, coalesce(fo.name, ''), h.from_curator_id,
+		       h.to_user_id, trim(coalesce(tu.name, '') || ' ' || coalesce(tu.last_name, '')),
+		       h.to_organization_id, coalesce(tor.name, ''),
+		       h.reason, h.transfer_id, h.created_at
+		FROM pet_ownership_history h
+		LEFT JOIN users fu ON fu.id = h.from_user_id
+		LEFT JOIN organizations fo ON fo.id = h.from_organization_id
+		LEFT JOIN users tu ON tu.id = h.to_user_id
+		LEFT JOIN organizations tor ON tor.id = h.to_organization_id
+		WHERE h.pet_id = ?
+		ORDER BY h.created_at DESC, h.id DESC
+	`), petID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения истории владения: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	records := []models.PetOwnershipRecord{}
+	for rows.Next() {
+		var h models.PetOwnershipRecord
+		var fromOrg, fromCurator, toOrg, transferID sql.NullInt64
+		err := rows.Scan(
+			&h.ID, &h.PetID, &h.FromUserID, &h.FromUserName,
+			&fromOrg, &h.FromOrganization, &fromCurator,
+			&h.ToUserID, &h.ToUserName,
+			&toOrg, &h.ToOrganization,
+			&h.Reason, &transferID, &h.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("❌ Error scanning ownership record: %v", err)
+			continue
+		}
+		h.FromOrganizationID = nullIntPtr(fromOrg)
+		h.FromCuratorID = nullIntPtr(fromCurator)
+		h.ToOrganizationID = nullIntPtr(toOrg)
+		h.TransferID = nullIntPtr(transferID)
+		records = append(records, h)
+	}
+
+	sendSuccessResponse(w, records)
+}
+
+func respondToPetTransfer(w http.ResponseWriter, userID int, transfer *models.PetTransfer, action string) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Ошибка обработки передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRow(ConvertPlaceholders(`
+		SELECT status FROM pet_transfers WHERE id = ? FOR UPDATE
+	`), transfer.ID).Scan(&status); err != nil {
+		sendErrorResponse(w, "Ошибка обработки передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status != models.PetTransferPending {
+		sendErrorResponse(w, "Предложение уже обработано", http.StatusConflict)
+		return
+	}
+
+	newStatus := map[string]string{
+		"accept":  models.PetTransferAccepted,
+		"decline": models.PetTransferDeclined,
+		"cancel":  models.PetTransferCancelled,
+	}[action]
+
+	if newStatus == models.PetTransferAccepted {
+		// Владелец мог смениться после отправки предложения (например, усыновление)
+		var currentUser int
+		var currentOrg sql.NullInt64
+		if err := tx.QueryRow(ConvertPlaceholders(`
+			SELECT user_id, organization_id FROM pets WHERE id = ? FOR UPDATE
+		`), transfer.PetID).Scan(&currentUser, &currentOrg); err != nil {
+			sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+			return
+		}
+		sameOwner := currentUser == transfer.FromUserID
+		if transfer.FromOrganizationID != nil {
+			sameOwner = currentOrg.Valid && int(currentOrg.Int64) == *transfer.FromOrganizationID
+		} else if currentOrg.Valid {
+			sameOwner = false
+		}
+		if !sameOwner {
+			sendErrorResponse(w, "Владелец питомца изменился, предложение недействительно", http.StatusConflict)
+			return
+		}
+
+		// Питомец организации закрепляется за принявшим сотрудником
+		toUserID := userID
+		if transfer.ToUserID != nil {
+			toUserID = *transfer.ToUserID
+		}
+		if err := transferPetOwnership(tx, transfer.PetID, toUserID, transfer.ToOrganizationID, userID, "transfer", &transfer.ID); err != nil {
+			log.Printf("❌ Pet transfer %d failed: %v", transfer.ID, err)
+			sendErrorResponse(w, "Ошибка передачи питомца: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := tx.Exec(ConvertPlaceholders(`
+		UPDATE pet_transfers SET status = ?, responded_by = ?, responded_at = NOW() WHERE id = ?
+	`), newStatus, userID, transfer.ID); err != nil {
+		sendErrorResponse(w, "Ошибка обработки передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Ошибка обработки передачи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch newStatus {
+	case models.PetTransferAccepted:
+		notifyPetTransfer(userID, transfer, fmt.Sprintf("Питомец %s передан новому владельцу", transfer.PetName), false)
+	case models.PetTransferDeclined:
+		notifyPetTransfer(userID, transfer, fmt.Sprintf("Получатель отказался принять питомца %s", transfer.PetName), false)
+	}
+
+	updated, err := loadPetTransfer(transfer.ID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения передачи", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Pet transfer %d: %s by user %d", transfer.ID, newStatus, userID)
+	sendSuccessResponse(w, updated)
+}
+
+// transferPetOwnership меняет владельца питомца внутри транзакции и записывает provenance.
+// ID питомца не меняется, поэтому посты с attached_pets, post_pets и объявления
+// продолжают ссылаться на ту же карточку. Куратор прежнего владельца снимается.
+func transferPetOwnership(tx *sql.Tx, petID, toUserID int, toOrgID *int, actorID int, reason string, transferID *int) error {
+	var fromUser int
+	var fromOrg, fromCurator sql.NullInt64
+	if err := tx.QueryRow(ConvertPlaceholders(`
+		SELECT user_id, organization_id, curator_id FROM pets WHERE id = ? FOR UPDATE
+	`), petID).Scan(&fromUser, &fromOrg, &fromCurator); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ConvertPlaceholders(`
+		INSERT INTO pet_ownership_history (pet_id, from_user_id, from_organization_id, from_curator_id,
+		                                   to_user_id, to_organization_id, reason, transfer_id, changed_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), petID, fromUser, nullIntPtr(fromOrg), nullIntPtr(fromCurator), toUserID, toOrgID, reason, transferID, actorID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ConvertPlaceholders(`
+		UPDATE pets SET user_id = ?, organization_id = ?, curator_id = NULL WHERE id = ?
+	`), toUserID, toOrgID, petID); err != nil {
+		return err
+	}
+
+	// organization_pets повторяет pets.organization_id для выборок по организации
+	if _, err := tx.Exec(ConvertPlaceholders("DELETE FROM organization_pets WHERE pet_id = ?"), petID); err != nil {
+		return err
+	}
+	if toOrgID != nil {
+		if _, err := tx.Exec(ConvertPlaceholders(`
+			INSERT INTO organization_pets (organization_id, pet_id) VALUES (?, ?)
+		`), *toOrgID, petID); err != nil {
+			return err
+		}
+	}
+
+	// Остальные открытые предложения по питомцу теряют смысл
+	if _, err := tx.Exec(ConvertPlaceholders(`
+		UPDATE pet_transfers SET status = 'cancelled', responded_at = NOW()
+		WHERE pet_id = ? AND status = 'pending' AND id <> coalesce(?, 0)
+	`), petID, transferID); err != nil {
+		return err
+	}
+
+	changes := []models.PetChange{
+		{Field: "user_id", OldValue: strconv.Itoa(fromUser), NewValue: strconv.Itoa(toUserID)},
+		{Field: "organization_id", OldValue: nullIntString(fromOrg), NewValue: intPtrString(toOrgID)},
+		{Field: "curator_id", OldValue: nullIntString(fromCurator)},
+	}
+	for _, change := range changes {
+		if change.OldValue == change.NewValue {
+			continue
+		}
+		if _, err := tx.Exec(ConvertPlaceholders(`
+			INSERT INTO pet_changes (pet_id, changed_by, field, old_value, new_value)
+			VALUES (?, ?, ?, ?, ?)
+		`), petID, actorID, change.Field, change.OldValue, change.NewValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadPetTransfer(transferID int) (*models.PetTransfer, error) {
+	row := database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT `+petTransferColumns+`
+		FROM pet_transfers t
+		LEFT JOIN pets p ON p.id = t.pet_id
+		WHERE t.id = ?
+	`), transferID)
+	return scanPetTransfer(row)
+}
+
+func scanPetTransfer(row interface{ Scan(...interface{}) error }) (*models.PetTransfer, error) {
+	var t models.PetTransfer
+	var fromOrg, toUser, toOrg, respondedBy sql.NullInt64
+
+	err := row.Scan(
+		&t.ID, &t.PetID, &t.FromUserID, &fromOrg, &toUser, &toOrg,
+		&t.OfferedBy, &t.Message, &t.Status, &respondedBy, &t.CreatedAt, &t.RespondedAt,
+		&t.PetName, &t.PetPhoto,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.FromOrganizationID = nullIntPtr(fromOrg)
+	t.ToUserID = nullIntPtr(toUser)
+	t.ToOrganizationID = nullIntPtr(toOrg)
+	t.RespondedBy = nullIntPtr(respondedBy)
+	return &t, nil
+}
+
+// isTransferRecipient - сам получатель или редактор организации-получателя
+func isTransferRecipient(userID int, transfer *models.PetTransfer) bool {
+	if transfer.ToUserID != nil {
+		return *transfer.ToUserID == userID
+	}
+	return transfer.ToOrganizationID != nil && isOrganizationEditor(database.DB, userID, *transfer.ToOrganizationID)
+}
+
+// notifyPetTransfer уведомляет получателя (toRecipient) или отправителя предложения.
+// Для организации-получателя уведомление получает её владелец.
+func notifyPetTransfer(actorID int, transfer *models.PetTransfer, message string, toRecipient bool) {
+	targetID := transfer.OfferedBy
+	if toRecipient {
+		if transfer.ToUserID != nil {
+			targetID = *transfer.ToUserID
+		} else {
+			database.DB.QueryRow(ConvertPlaceholders("SELECT owner_user_id FROM organizations WHERE id = ?"), *transfer.ToOrganizationID).Scan(&targetID)
+		}
+	}
+
+	notifHandler := &NotificationsHandler{DB: database.DB}
+	if err := notifHandler.CreateNotification(targetID, actorID, "pet_transfer", "pet", transfer.PetID, message); err != nil {
+		log.Printf("⚠️ Failed to notify user %d about pet transfer %d: %v", targetID, transfer.ID, err)
+	}
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func nullIntString(v sql.NullInt64) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.FormatInt(v.Int64, 10)
+}
+
+func intPtrString(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func UserPetsHandler(w http.ResponseWriter, r *http.Request) {
@@ -59,17 +60,46 @@ func PetsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func PetHandler(w http.ResponseWriter, r *http.Request) {
-	// Извлекаем ID из URL
+	// Извлекаем ID из URL: /api/pets/{id}[/history|/medical|/transfer|/provenance]
 	path := strings.TrimPrefix(r.URL.Path, "/api/pets/")
-	id, err := strconv.Atoi(path)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	id, err := strconv.Atoi(parts[0])
 	if err != nil {
 		sendErrorResponse(w, "Неверный ID питомца", http.StatusBadRequest)
 		return
 	}
 
+	if len(parts) == 2 && parts[1] == "medical" {
+		PetMedicalRecordsHandler(w, r, id)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "transfer" {
+		handlePetTransferOffer(w, r, id)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "provenance" {
+		handlePetProvenance(w, r, id)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "history" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		getPetHistory(w, r, id)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		getPet(w, r, id)
+	case http.MethodPut:
+		updatePet(w, r, id, true)
+	case http.MethodPatch:
+		updatePet(w, r, id, false)
 	case http.MethodDelete:
 		deletePet(w, r, id)
 	default:
@@ -157,11 +187,40 @@ func getCuratedPets(w http.ResponseWriter, _ *http.Request, userID int) {
 	sendSuccessResponse(w, pets)
 }
 
-func getPet(w http.ResponseWriter, _ *http.Request, petID int) {
-	query := `SELECT id, user_id, name, species, photo, created_at FROM pets WHERE id = ?`
+// petColumns - полная карточка питомца; NULL в необязательных полях отдаётся пустой строкой
+const petColumns = `
+	p.id, p.user_id, p.name, coalesce(p.species, ''), coalesce(p.breed, ''), coalesce(p.gender, ''),
+	coalesce(left(p.birth_date::text, 10), ''), coalesce(p.color, ''), coalesce(p.size, ''), coalesce(p.photo, ''),
+	coalesce(p.status, ''), coalesce(p.city, ''), coalesce(p.region, ''), coalesce(p.urgent::int = 1, FALSE),
+	coalesce(p.story, ''), coalesce(p.contact_name, ''), coalesce(p.contact_phone, ''),
+	p.organization_id, coalesce(o.name, ''), coalesce(o.type, ''), p.created_at`
+
+// Допустимые значения полей карточки (совпадают с формой редактирования)
+var (
+	validPetStatuses = map[string]bool{"": true, "home": true, "looking_for_home": true, "lost": true, "found": true, "needs_help": true}
+	validPetGenders  = map[string]bool{"": true, "male": true, "female": true}
+	validPetSizes    = map[string]bool{"": true, "small": true, "medium": true, "large": true}
+)
 
+func loadPet(petID int) (models.Pet, error) {
 	var pet models.Pet
-	err := database.DB.QueryRow(ConvertPlaceholders(query), petID).Scan(&pet.ID, &pet.UserID, &pet.Name, &pet.Species, &pet.Photo, &pet.CreatedAt)
+	err := database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT `+petColumns+`
+		FROM pets p
+		LEFT JOIN organizations o ON o.id = p.organization_id
+		WHERE p.id = ?
+	`), petID).Scan(
+		&pet.ID, &pet.UserID, &pet.Name, &pet.Species, &pet.Breed, &pet.Gender,
+		&pet.BirthDate, &pet.Color, &pet.Size, &pet.Photo,
+		&pet.Status, &pet.City, &pet.Region, &pet.Urgent,
+		&pet.Story, &pet.ContactName, &pet.ContactPhone,
+		&pet.OrganizationID, &pet.OrganizationName, &pet.OrganizationType, &pet.CreatedAt,
+	)
+	return pet, err
+}
+
+func getPet(w http.ResponseWriter, _ *http.Request, petID int) {
+	pet, err := loadPet(petID)
 	if err != nil {
 		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
 		return
@@ -183,30 +242,282 @@ func createPet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		sendErrorResponse(w, "Имя питомца не может быть пустым", http.StatusBadRequest)
+	pet := models.Pet{
+		UserID:       userID,
+		Name:         strings.TrimSpace(req.Name),
+		Species:      req.Species,
+		Breed:        req.Breed,
+		Gender:       req.Gender,
+		BirthDate:    req.BirthDate,
+		Color:        req.Color,
+		Size:         req.Size,
+		Photo:        req.Photo,
+		Status:       req.Status,
+		City:         req.City,
+		Region:       req.Region,
+		Urgent:       req.Urgent,
+		Story:        req.Story,
+		ContactName:  req.ContactName,
+		ContactPhone: req.ContactPhone,
+	}
+	if msg := validatePet(&pet); msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	query := `INSERT INTO pets (user_id, name, species, photo) VALUES (?, ?, ?, ?)`
-	result, err := database.DB.Exec(ConvertPlaceholders(query), userID, req.Name, req.Species, req.Photo)
+	var id int
+	err := database.DB.QueryRow(ConvertPlaceholders(`
+		INSERT INTO pets (user_id, name, species, breed, gender, birth_date, color, size, photo,
+		                  status, city, region, urgent, story, contact_name, contact_phone)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), userID, pet.Name, pet.Species, pet.Breed, pet.Gender, nullIfEmpty(pet.BirthDate), pet.Color, pet.Size, pet.Photo,
+		pet.Status, pet.City, pet.Region, boolToInt(pet.Urgent), pet.Story, pet.ContactName, pet.ContactPhone).Scan(&id)
 	if err != nil {
 		sendErrorResponse(w, "Ошибка добавления питомца: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-
 	// Получаем созданного питомца
-	var pet models.Pet
-	query = `SELECT id, user_id, name, species, photo, created_at FROM pets WHERE id = ?`
-	err = database.DB.QueryRow(ConvertPlaceholders(query), id).Scan(&pet.ID, &pet.UserID, &pet.Name, &pet.Species, &pet.Photo, &pet.CreatedAt)
+	created, err := loadPet(id)
 	if err != nil {
 		sendErrorResponse(w, "Ошибка получения питомца", http.StatusInternalServerError)
 		return
 	}
 
-	sendSuccessResponse(w, pet)
+	sendSuccessResponse(w, created)
+}
+
+// updatePet - PUT (replace = true) или PATCH карточки питомца.
+// Изменённые поля записываются в pet_changes в той же транзакции.
+func updatePet(w http.ResponseWriter, r *http.Request, petID int, replace bool) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+
+	if !canEditPet(userID, &pet) {
+		sendErrorResponse(w, "Нет прав на редактирование этого питомца", http.StatusForbidden)
+		return
+	}
+
+	var req models.UpdatePetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	changes := []models.PetChange{}
+	applyPetField(&changes, "name", &pet.Name, req.Name, replace)
+	applyPetField(&changes, "species", &pet.Species, req.Species, replace)
+	applyPetField(&changes, "breed", &pet.Breed, req.Breed, replace)
+	applyPetField(&changes, "gender", &pet.Gender, req.Gender, replace)
+	applyPetField(&changes, "birth_date", &pet.BirthDate, req.BirthDate, replace)
+	applyPetField(&changes, "color", &pet.Color, req.Color, replace)
+	applyPetField(&changes, "size", &pet.Size, req.Size, replace)
+	applyPetField(&changes, "photo", &pet.Photo, req.Photo, replace)
+	applyPetField(&changes, "status", &pet.Status, req.Status, replace)
+	applyPetField(&changes, "city", &pet.City, req.City, replace)
+	applyPetField(&changes, "region", &pet.Region, req.Region, replace)
+	applyPetField(&changes, "story", &pet.Story, req.Story, replace)
+	applyPetField(&changes, "contact_name", &pet.ContactName, req.ContactName, replace)
+	applyPetField(&changes, "contact_phone", &pet.ContactPhone, req.ContactPhone, replace)
+
+	urgent := pet.Urgent
+	if req.Urgent != nil {
+		urgent = *req.Urgent
+	} else if replace {
+		urgent = false
+	}
+	if urgent != pet.Urgent {
+		changes = append(changes, models.PetChange{Field: "urgent", OldValue: strconv.FormatBool(pet.Urgent), NewValue: strconv.FormatBool(urgent)})
+		pet.Urgent = urgent
+	}
+
+	pet.Name = strings.TrimSpace(pet.Name)
+	if msg := validatePet(&pet); msg != "" {
+		sendErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if len(changes) == 0 {
+		sendSuccessResponse(w, pet)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Ошибка обновления питомца: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(ConvertPlaceholders(`
+		UPDATE pets
+		SET name = ?, species = ?, breed = ?, gender = ?, birth_date = ?, color = ?, size = ?, photo = ?,
+		    status = ?, city = ?, region = ?, urgent = ?, story = ?, contact_name = ?, contact_phone = ?
+		WHERE id = ?
+	`), pet.Name, pet.Species, pet.Breed, pet.Gender, nullIfEmpty(pet.BirthDate), pet.Color, pet.Size, pet.Photo,
+		pet.Status, pet.City, pet.Region, boolToInt(pet.Urgent), pet.Story, pet.ContactName, pet.ContactPhone, petID)
+	if err != nil {
+		log.Printf("❌ updatePet: Ошибка обновления питомца %d: %v", petID, err)
+		sendErrorResponse(w, "Ошибка обновления питомца: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, change := range changes {
+		_, err = tx.Exec(ConvertPlaceholders(`
+			INSERT INTO pet_changes (pet_id, changed_by, field, old_value, new_value)
+			VALUES (?, ?, ?, ?, ?)
+		`), petID, userID, change.Field, change.OldValue, change.NewValue)
+		if err != nil {
+			log.Printf("❌ updatePet: Ошибка записи истории питомца %d: %v", petID, err)
+			sendErrorResponse(w, "Ошибка сохранения истории изменений", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Ошибка обновления питомца: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ updatePet: Питомец %d обновлён пользователем %d (%d полей)", petID, userID, len(changes))
+
+	updated, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения питомца", http.StatusInternalServerError)
+		return
+	}
+	sendSuccessResponse(w, updated)
+}
+
+// getPetHistory возвращает историю изменений карточки (для тех, кто может её редактировать)
+func getPetHistory(w http.ResponseWriter, r *http.Request, petID int) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	pet, err := loadPet(petID)
+	if err != nil {
+		sendErrorResponse(w, "Питомец не найден", http.StatusNotFound)
+		return
+	}
+
+	if !canEditPet(userID, &pet) {
+		sendErrorResponse(w, "Нет прав на просмотр истории этого питомца", http.StatusForbidden)
+		return
+	}
+
+	rows, err := database.DB.Query(ConvertPlaceholders(`
+		SELECT c.id, c.pet_id, coalesce(c.changed_by, 0), coalesce(trim(u.name || ' ' || coalesce(u.last_name, '')), ''),
+		       c.field, c.old_value, c.new_value, c.created_at
+		FROM pet_changes c
+		LEFT JOIN users u ON u.id = c.changed_by
+		WHERE c.pet_id = ?
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT 200
+	`), petID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения истории: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	changes := []models.PetChange{}
+	for rows.Next() {
+		var c models.PetChange
+		if err := rows.Scan(&c.ID, &c.PetID, &c.ChangedBy, &c.ChangedByName, &c.Field, &c.OldValue, &c.NewValue, &c.CreatedAt); err != nil {
+			log.Printf("❌ getPetHistory: Ошибка чтения строки: %v", err)
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	sendSuccessResponse(w, changes)
+}
+
+// canEditPet - владелец или участник организации питомца с правом can_edit
+func canEditPet(userID int, pet *models.Pet) bool {
+	if pet.UserID == userID {
+		return true
+	}
+	if pet.OrganizationID == nil {
+		return false
+	}
+
+	var canEdit bool
+	database.DB.QueryRow(ConvertPlaceholders(`
+		SELECT can_edit FROM organization_members
+		WHERE organization_id = ? AND user_id = ?
+	`), *pet.OrganizationID, userID).Scan(&canEdit)
+	return canEdit
+}
+
+// applyPetField переносит значение из запроса в карточку и фиксирует изменение.
+// nil означает "не менять" для PATCH и "очистить" для PUT.
+func applyPetField(changes *[]models.PetChange, field string, current *string, value *string, replace bool) {
+	next := *current
+	if value != nil {
+		next = strings.TrimSpace(*value)
+	} else if replace {
+		next = ""
+	}
+
+	if next != *current {
+		*changes = append(*changes, models.PetChange{Field: field, OldValue: *current, NewValue: next})
+		*current = next
+	}
+}
+
+// validatePet возвращает текст ошибки или пустую строку
+func validatePet(pet *models.Pet) string {
+	if pet.Name == "" {
+		return "Имя питомца не может быть пустым"
+	}
+	if !validPetStatuses[pet.Status] {
+		return "Недопустимый статус питомца"
+	}
+	if !validPetGenders[pet.Gender] {
+		return "Недопустимый пол питомца"
+	}
+	if !validPetSizes[pet.Size] {
+		return "Недопустимый размер питомца"
+	}
+	if pet.BirthDate != "" {
+		birthDate, err := time.Parse("2006-01-02", pet.BirthDate)
+		if err != nil {
+			return "Дата рождения должна быть в формате ГГГГ-ММ-ДД"
+		}
+		if birthDate.After(time.Now()) {
+			return "Дата рождения не может быть в будущем"
+		}
+	}
+	return ""
+}
+
+// boolToInt - urgent хранится как 0/1 (наследие SQLite), 1 принимается и boolean-колонкой
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
 }
 
 func deletePet(w http.ResponseWriter, r *http.Request, petID int) {
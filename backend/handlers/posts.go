@@ -197,22 +197,26 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("🔍 getAllPosts: userID=%d, filter=%s", userID, filter)
 
-	// Получаем параметры пагинации
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20 // По умолчанию 20 постов
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
-			limit = parsedLimit
-		}
+	// Получаем параметры пагинации (по умолчанию 20 постов)
+	limit, cursor, err := parseCursorPage(r, 20, 100)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
 	}
 
 	// Получаем город пользователя для фильтра "city"
 	var userCity string
-	if filter == "city" && userID > 0 {
+	if (filter == "city" || filter == "for-you") && userID > 0 {
 		database.DB.QueryRow(ConvertPlaceholders("SELECT location FROM users WHERE id = ?"), userID).Scan(&userCity)
 		log.Printf("🏙️ User city: %s", userCity)
 	}
 
+	// "Для вас" - ранжированная лента из снимка, см. feed_ranking.go
+	if filter == "for-you" {
+		getRankedFeed(w, r, userID, userCity, limit, cursor)
+		return
+	}
+
 	// Базовый запрос
 	query := `
 		SELECT p.id, p.author_id, p.author_type, p.content, p.attached_pets, 
@@ -240,16 +244,25 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 
 	switch filter {
 	case "following":
-		// Только посты от друзей (не свои)
+		// Посты друзей и пользователей, на которых подписан (не свои), и организаций из подписок
 		if userID > 0 {
-			query += ` AND p.author_type = 'user' AND p.author_id != ? AND EXISTS (
-				SELECT 1 FROM friendships f 
-				WHERE ((f.user_id = ? AND f.friend_id = p.author_id) 
-					OR (f.friend_id = ? AND f.user_id = p.author_id))
-					AND f.status = 'accepted'
+			query += ` AND (
+				(p.author_type = 'user' AND p.author_id != ? AND (
+					EXISTS (
+						SELECT 1 FROM friendships f 
+						WHERE ((f.user_id = ? AND f.friend_id = p.author_id) 
+							OR (f.friend_id = ? AND f.user_id = p.author_id))
+							AND f.status = 'accepted'
+					) OR p.author_id IN (
+						SELECT target_id FROM follows WHERE follower_id = ? AND target_type = 'user'
+					)
+				)) OR
+				(p.author_type = 'organization' AND p.author_id IN (
+					SELECT target_id FROM follows WHERE follower_id = ? AND target_type = 'organization'
+				))
 			)`
-			args = append(args, userID, userID, userID)
-			log.Printf("🔍 Following filter: excluding userID=%d, checking friendships", userID)
+			args = append(args, userID, userID, userID, userID, userID)
+			log.Printf("🔍 Following filter: excluding userID=%d, checking friendships and follows", userID)
 		}
 	case "city":
 		// Только посты из города пользователя
@@ -263,8 +276,15 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	query += ` ORDER BY is_friend DESC, p.created_at DESC LIMIT ?`
-	args = append(args, limit)
+	// Курсорная пагинация по (created_at, id): новые посты не сдвигают страницы
+	if cursor != nil {
+		query += ` AND ` + cursorCondition("p.created_at", "p.id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Берём на один пост больше, чтобы понять, есть ли следующая страница
+	query += ` ORDER BY p.created_at DESC, p.id DESC LIMIT ?`
+	args = append(args, limit+1)
 
 	// Конвертируем плейсхолдеры для PostgreSQL
 	query = ConvertPlaceholders(query)
@@ -282,6 +302,44 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	posts, err := scanFeedPosts(rows)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка чтения данных: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[len(posts)-1]
+		nextCursor = encodeCursorString(last.CreatedAt, last.ID)
+	}
+
+	// ✅ ОПТИМИЗАЦИЯ: Загружаем питомцев одним запросом для всех постов
+	posts = loadPetsForPostsBatch(posts)
+
+	// ✅ ОПТИМИЗАЦИЯ: Загружаем опросы одним запросом для всех постов
+	includePolls := r.URL.Query().Get("include_polls")
+	if includePolls == "true" {
+		posts = loadPollsForPostsBatch(posts, userID)
+	}
+
+	// ✅ Проверяем права на редактирование для каждого поста
+	for i := range posts {
+		posts[i].CanEdit = checkCanEditPost(userID, &posts[i])
+	}
+
+	posts = loadAttachmentVariantsBatch(posts)
+	sendPageResponse(w, posts, nextCursor)
+}
+
+// scanFeedPosts читает строки ленты: колонки поста, автор-организация,
+// автор-пользователь, comments_count и is_friend (см. запрос в getAllPosts)
+func scanFeedPosts(rows *sql.Rows) ([]models.Post, error) {
 	var posts []models.Post
 	for rows.Next() {
 		var post models.Post
@@ -301,9 +359,9 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 			&isFriend,
 		)
 		if err != nil {
-			sendErrorResponse(w, "Ошибка чтения данных: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
+		post.IsFriend = isFriend == 1
 
 		// Десериализуем JSON массивы
 		json.Unmarshal([]byte(attachedPetsJSON), &post.AttachedPets)
@@ -350,25 +408,7 @@ func getAllPosts(w http.ResponseWriter, r *http.Request) {
 		posts = append(posts, post)
 	}
 
-	if posts == nil {
-		posts = []models.Post{}
-	}
-
-	// ✅ ОПТИМИЗАЦИЯ: Загружаем питомцев одним запросом для всех постов
-	posts = loadPetsForPostsBatch(posts)
-
-	// ✅ ОПТИМИЗАЦИЯ: Загружаем опросы одним запросом для всех постов
-	includePolls := r.URL.Query().Get("include_polls")
-	if includePolls == "true" {
-		posts = loadPollsForPostsBatch(posts, userID)
-	}
-
-	// ✅ Проверяем права на редактирование для каждого поста
-	for i := range posts {
-		posts[i].CanEdit = checkCanEditPost(userID, &posts[i])
-	}
-
-	sendSuccessResponse(w, posts)
+	return posts, rows.Err()
 }
 
 // getDrafts получает черновики пользователя
@@ -421,6 +461,7 @@ func getDrafts(w http.ResponseWriter, r *http.Request) {
 		drafts[i].CanEdit = checkCanEditPost(userID, &drafts[i])
 	}
 
+	drafts = loadAttachmentVariantsBatch(drafts)
 	sendSuccessResponse(w, drafts)
 }
 
@@ -444,28 +485,35 @@ func getUserPosts(w http.ResponseWriter, r *http.Request, userID int) {
 	// Простой запрос только ID постов (без JOIN)
 	log.Printf("🔍 getUserPosts: Fetching post IDs...")
 
-	// Получаем параметры пагинации из query
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 20 // По умолчанию 20 постов
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
-			limit = parsedLimit
-		}
+	// Получаем параметры пагинации из query (по умолчанию 20 постов)
+	limit, cursor, err := parseCursorPage(r, 20, 50)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
 	}
 
-	offset := 0 // По умолчанию с начала
-	if offsetStr != "" {
+	// offset оставлен для старых клиентов, при наличии cursor игнорируется
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" && cursor == nil {
 		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
 			offset = parsedOffset
 		}
 	}
 
-	log.Printf("🔍 getUserPosts: Pagination - limit=%d, offset=%d", limit, offset)
+	log.Printf("🔍 getUserPosts: Pagination - limit=%d, offset=%d, cursor=%v", limit, offset, cursor != nil)
 
-	simpleQuery := `SELECT id FROM posts WHERE author_id = ? AND author_type = 'user' AND is_deleted = FALSE ORDER BY created_at DESC LIMIT ? OFFSET ?`
-	rows, err := database.DB.Query(ConvertPlaceholders(simpleQuery), userID, limit, offset)
+	// Скрытые модератором посты видит только сам автор
+	simpleQuery := `SELECT id, created_at FROM posts
+		WHERE author_id = ? AND author_type = 'user' AND is_deleted = FALSE AND (status <> 'hidden' OR author_id = ?)`
+	args := []interface{}{userID, currentUserID}
+	if cursor != nil {
+		simpleQuery += ` AND ` + cursorCondition("created_at", "id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	simpleQuery += ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit+1, offset)
+
+	rows, err := database.DB.Query(ConvertPlaceholders(simpleQuery), args...)
 	if err != nil {
 		log.Printf("❌ getUserPosts: Query error: %v", err)
 		sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
@@ -474,13 +522,22 @@ func getUserPosts(w http.ResponseWriter, r *http.Request, userID int) {
 	defer rows.Close()
 
 	var postIDs []int
+	var lastCreatedAt time.Time
+	nextCursor := ""
 	for rows.Next() {
 		var id int
-		if err := rows.Scan(&id); err != nil {
+		var createdAt time.Time
+		if err := rows.Scan(&id, &createdAt); err != nil {
 			log.Printf("❌ getUserPosts: Scan error: %v", err)
 			continue
 		}
+		// Лишняя строка означает, что есть следующая страница
+		if len(postIDs) == limit {
+			nextCursor = encodeCursor(lastCreatedAt, postIDs[len(postIDs)-1])
+			break
+		}
 		postIDs = append(postIDs, id)
+		lastCreatedAt = createdAt
 	}
 	log.Printf("✅ getUserPosts: Found %d post IDs", len(postIDs))
 
@@ -579,7 +636,8 @@ func getUserPosts(w http.ResponseWriter, r *http.Request, userID int) {
 	log.Printf("✅ getUserPosts: Edit permissions checked")
 
 	log.Printf("✅ getUserPosts: Sending response with %d posts", len(posts))
-	sendSuccessResponse(w, posts)
+	posts = loadAttachmentVariantsBatch(posts)
+	sendPageResponse(w, posts, nextCursor)
 }
 
 // getPetPosts получает посты, в которых упоминается питомец
@@ -587,6 +645,12 @@ func getPetPosts(w http.ResponseWriter, r *http.Request, petID int) {
 	// Получаем текущего пользователя из контекста
 	currentUserID, _ := r.Context().Value("userID").(int)
 
+	limit, cursor, err := parseCursorPage(r, 20, 100)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
+	}
+
 	query := `
 		SELECT p.id, p.author_id, p.author_type, p.content, p.attached_pets, 
 		       p.attachments, p.tags, p.status, p.scheduled_at, p.created_at, p.updated_at,
@@ -598,10 +662,17 @@ func getPetPosts(w http.ResponseWriter, r *http.Request, petID int) {
 		LEFT JOIN organizations o ON p.author_id = o.id AND p.author_type = 'organization'
 		INNER JOIN post_pets pp ON p.id = pp.post_id
 		WHERE pp.pet_id = ? AND p.is_deleted = FALSE AND p.status = 'published'
-		ORDER BY p.created_at DESC
 	`
+	args := []interface{}{petID}
 
-	rows, err := database.DB.Query(query, petID)
+	if cursor != nil {
+		query += ` AND ` + cursorCondition("p.created_at", "p.id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	query += ` ORDER BY p.created_at DESC, p.id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
 	if err != nil {
 		sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -622,6 +693,13 @@ func getPetPosts(w http.ResponseWriter, r *http.Request, petID int) {
 		posts = []models.Post{}
 	}
 
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[len(posts)-1]
+		nextCursor = encodeCursorString(last.CreatedAt, last.ID)
+	}
+
 	// Загружаем опросы для всех постов
 	posts = loadPollsForPosts(posts, currentUserID)
 
@@ -630,7 +708,8 @@ func getPetPosts(w http.ResponseWriter, r *http.Request, petID int) {
 		posts[i].CanEdit = checkCanEditPost(currentUserID, &posts[i])
 	}
 
-	sendSuccessResponse(w, posts)
+	posts = loadAttachmentVariantsBatch(posts)
+	sendPageResponse(w, posts, nextCursor)
 }
 
 // getOrganizationPosts получает посты организации
@@ -638,6 +717,12 @@ func getOrganizationPosts(w http.ResponseWriter, r *http.Request, orgID int) {
 	// Получаем текущего пользователя из контекста
 	currentUserID, _ := r.Context().Value("userID").(int)
 
+	limit, cursor, err := parseCursorPage(r, 20, 100)
+	if err != nil {
+		sendErrorResponse(w, "Неверный курсор", http.StatusBadRequest)
+		return
+	}
+
 	query := `
 		SELECT p.id, p.author_id, p.author_type, p.content, p.attached_pets, 
 		       p.attachments, p.tags, p.status, p.scheduled_at, p.created_at, p.updated_at,
@@ -648,10 +733,17 @@ func getOrganizationPosts(w http.ResponseWriter, r *http.Request, orgID int) {
 		LEFT JOIN users u ON p.author_id = u.id AND p.author_type = 'user'
 		LEFT JOIN organizations o ON p.author_id = o.id AND p.author_type = 'organization'
 		WHERE p.author_id = ? AND p.author_type = 'organization' AND p.is_deleted = FALSE AND p.status = 'published'
-		ORDER BY p.created_at DESC
 	`
+	args := []interface{}{orgID}
 
-	rows, err := database.DB.Query(query, orgID)
+	if cursor != nil {
+		query += ` AND ` + cursorCondition("p.created_at", "p.id", false)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	query += ` ORDER BY p.created_at DESC, p.id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := database.DB.Query(ConvertPlaceholders(query), args...)
 	if err != nil {
 		sendErrorResponse(w, "Ошибка получения постов: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -672,6 +764,13 @@ func getOrganizationPosts(w http.ResponseWriter, r *http.Request, orgID int) {
 		posts = []models.Post{}
 	}
 
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[len(posts)-1]
+		nextCursor = encodeCursorString(last.CreatedAt, last.ID)
+	}
+
 	// Загружаем опросы для всех постов
 	posts = loadPollsForPosts(posts, currentUserID)
 
@@ -680,7 +779,8 @@ func getOrganizationPosts(w http.ResponseWriter, r *http.Request, orgID int) {
 		posts[i].CanEdit = checkCanEditPost(currentUserID, &posts[i])
 	}
 
-	sendSuccessResponse(w, posts)
+	posts = loadAttachmentVariantsBatch(posts)
+	sendPageResponse(w, posts, nextCursor)
 }
 
 // createPost создаёт новый пост
@@ -714,11 +814,29 @@ func createPost(w http.ResponseWriter, r *http.Request) {
 		status = req.Status
 	}
 
+	if status != "published" && status != "scheduled" && status != "draft" {
+		sendErrorResponse(w, "Неверный статус поста", http.StatusBadRequest)
+		return
+	}
+
 	var scheduledAt *string
 	if req.ScheduledAt != nil {
 		scheduledAt = req.ScheduledAt
 	}
 
+	// Отложенный пост должен иметь корректное время публикации в будущем
+	if status == "scheduled" {
+		if scheduledAt == nil {
+			sendErrorResponse(w, "Для отложенного поста укажите scheduled_at", http.StatusBadRequest)
+			return
+		}
+		publishAt := parseTime(*scheduledAt)
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			sendErrorResponse(w, "Время публикации должно быть в будущем", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Определяем автора поста
 	authorType := "user"
 	authorID := userID
@@ -793,6 +911,11 @@ func createPost(w http.ResponseWriter, r *http.Request) {
 	}
 	CreateUserLog(database.DB, userID, "post_create", details, ipAddress, userAgent)
 
+	// Подписчики организации узнают о посте сразу; отложенные - при публикации
+	if status == "published" && authorType == "organization" {
+		go notifyFollowersAboutPost(database.DB, int(postID), authorType, authorID, userID)
+	}
+
 	sendSuccessResponse(w, post)
 }
 
@@ -1003,6 +1126,11 @@ func getPostByID(postID int, userID int) (models.Post, error) {
 
 	log.Printf("✅ getPostByID: Found post id=%d, author_type=%s, author_id=%d", post.ID, post.AuthorType, post.AuthorID)
 
+	// Скрытый модератором пост доступен только автору
+	if post.Status == "hidden" && !checkCanEditPost(userID, &post) {
+		return post, sql.ErrNoRows
+	}
+
 	// Парсим JSON поля
 	if attachedPetsJSON.Valid && attachedPetsJSON.String != "" {
 		json.Unmarshal([]byte(attachedPetsJSON.String), &post.AttachedPets)
@@ -1073,6 +1201,7 @@ func getPostByID(postID int, userID int) (models.Post, error) {
 		post.Poll = poll
 	}
 
+	post = loadAttachmentVariantsBatch([]models.Post{post})[0]
 	return post, nil
 }
 
@@ -4,7 +4,10 @@ import (
 	"backend/models"
 	"database"
 	"fmt"
+	"log"
 	"strings"
+
+	"github.com/lib/pq"
 )
 
 // loadPollsForPostsBatch - оптимизированная загрузка опросов одним запросом
@@ -227,3 +230,52 @@ func loadPetsForPostsBatch(posts []models.Post) []models.Post {
 
 	return posts
 }
+
+// loadAttachmentVariantsBatch заполняет варианты изображений у вложений из медиатеки
+// (/api/media/file/{id}) по user_media.variants - одним запросом для всех постов.
+// Варианты, присланные клиентом, для таких вложений не используются; у вложений
+// с другими ссылками остаются варианты, сохранённые вместе с постом.
+// Приватные файлы медиатеки в постах вариантов не получают.
+func loadAttachmentVariantsBatch(posts []models.Post) []models.Post {
+	var ids pq.Int64Array
+	for _, post := range posts {
+		for _, attachment := range post.Attachments {
+			if id, ok := mediaIDFromURL(attachment.URL); ok {
+				ids = append(ids, int64(id))
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return posts
+	}
+
+	rows, err := database.DB.Query(ConvertPlaceholders(`
+		SELECT id, COALESCE(variants, '') FROM user_media
+		WHERE id = ANY(?) AND COALESCE(is_private, FALSE) = FALSE
+	`), ids)
+	if err != nil {
+		log.Printf("⚠️ Failed to load attachment variants: %v", err)
+		return posts
+	}
+	defer rows.Close()
+
+	variants := map[int]map[string]models.ImageVariant{}
+	for rows.Next() {
+		var id int
+		var variantsJSON string
+		if err := rows.Scan(&id, &variantsJSON); err != nil {
+			continue
+		}
+		variants[id] = mediaVariantURLs(id, variantsJSON)
+	}
+
+	for i := range posts {
+		for j := range posts[i].Attachments {
+			attachment := &posts[i].Attachments[j]
+			if id, ok := mediaIDFromURL(attachment.URL); ok {
+				attachment.Variants = variants[id]
+			}
+		}
+	}
+	return posts
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/storage"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// mediaURLSecret - ключ подписи ссылок на приватные файлы (MEDIA_URL_SECRET, иначе JWT_SECRET)
+func mediaURLSecret() []byte {
+	if secret := os.Getenv("MEDIA_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// ValidateMediaURLSecret проверяет, что ключ подписи задан: с пустым ключом
+// подпись ссылки на приватный файл может вычислить кто угодно
+func ValidateMediaURLSecret() error {
+	if len(mediaURLSecret()) == 0 {
+		return errors.New("MEDIA_URL_SECRET or JWT_SECRET must be set to sign private media URLs")
+	}
+	return nil
+}
+
+func mediaURLSignature(mediaID int, expires int64) string {
+	mac := hmac.New(sha256.New, mediaURLSecret())
+	mac.Write([]byte(strconv.Itoa(mediaID) + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedMediaURL - ссылка на приватный файл, действующая storage.PresignTTL().
+// Для файлов в S3 GetMediaFile по ней перенаправляет на presigned GET бакета.
+func signedMediaURL(mediaID int) string {
+	expires := time.Now().Add(storage.PresignTTL()).Unix()
+	return "/api/media/file/" + strconv.Itoa(mediaID) +
+		"?expires=" + strconv.FormatInt(expires, 10) + "&sig=" + mediaURLSignature(mediaID, expires)
+}
+
+// verifyMediaURL проверяет подпись и срок ссылки на приватный файл
+func verifyMediaURL(mediaID int, expiresStr, sig string) bool {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires || len(mediaURLSecret()) == 0 {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(mediaURLSignature(mediaID, expires)))
+}
+
+// mediaIDFromURL извлекает ID из ссылки вида /api/media/file/{id}[?...]
+func mediaIDFromURL(url string) (int, bool) {
+	rest, ok := strings.CutPrefix(url, "/api/media/file/")
+	if !ok {
+		return 0, false
+	}
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest = rest[:i]
+	}
+	id, err := strconv.Atoi(rest)
+	return id, err == nil
+}
+
+// normalizeMediaAttachments убирает из ссылок на медиатеку подпись и срок,
+// чтобы в БД не сохранялись временные URL
+func normalizeMediaAttachments(attachments []models.Attachment) {
+	for i := range attachments {
+		if id, ok := mediaIDFromURL(attachments[i].URL); ok {
+			attachments[i].URL = "/api/media/file/" + strconv.Itoa(id)
+		}
+	}
+}
+
+// attachmentMediaIDs - ID файлов медиатеки среди вложений
+func attachmentMediaIDs(attachments []models.Attachment) pq.Int64Array {
+	var ids pq.Int64Array
+	for _, attachment := range attachments {
+		if id, ok := mediaIDFromURL(attachment.URL); ok {
+			ids = append(ids, int64(id))
+		}
+	}
+	return ids
+}
+
+// mediaAttachmentsOwnedBy проверяет, что все вложения из медиатеки - файлы ownerID.
+// Иначе к своей записи можно было бы прикрепить чужой приватный файл и получить на него ссылку.
+func mediaAttachmentsOwnedBy(db *sql.DB, attachments []models.Attachment, ownerID int) (bool, error) {
+	ids := attachmentMediaIDs(attachments)
+	if len(ids) == 0 {
+		return true, nil
+	}
+	var foreign bool
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT EXISTS(
+			SELECT 1 FROM unnest(?::bigint[]) AS a(id)
+			LEFT JOIN user_media um ON um.id = a.id
+			WHERE um.user_id IS DISTINCT FROM ?
+		)
+	`), ids, ownerID).Scan(&foreign)
+	if err != nil {
+		return false, err
+	}
+	return !foreign, nil
+}
+
+// signPrivateMediaAttachments подписывает ссылки на приватные файлы медиатеки,
+// принадлежащие автору объекта (ownerID). Вызывается только после проверки,
+// что текущий пользователь видит объект с вложениями.
+func signPrivateMediaAttachments(db *sql.DB, attachments []models.Attachment, ownerID int) {
+	ids := attachmentMediaIDs(attachments)
+	if len(ids) == 0 {
+		return
+	}
+
+	rows, err := db.Query(ConvertPlaceholders(`
+		SELECT id FROM user_media
+		WHERE id = ANY(?) AND user_id = ? AND is_private = TRUE
+	`), ids, ownerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load private attachments: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	private := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("⚠️ Failed to scan private attachment: %v", err)
+			return
+		}
+		private[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("⚠️ Failed to load private attachments: %v", err)
+		return
+	}
+
+	for i := range attachments {
+		if id, ok := mediaIDFromURL(attachments[i].URL); ok && private[id] {
+			attachments[i].URL = signedMediaURL(id)
+		}
+	}
+}
+
+// messageAttachmentURL - вложения сообщений в S3 (file_path = "s3://ключ") приватные
+// и отдаются участникам чата по presigned GET
+func messageAttachmentURL(filePath string) string {
+	key, ok := strings.CutPrefix(filePath, "s3://")
+	if !ok || !storage.Enabled() {
+		return filePath
+	}
+	url, err := storage.GlobalS3Client.PresignGet(key, storage.PresignTTL(), "")
+	if err != nil {
+		return ""
+	}
+	return url
+}
@@ -88,17 +88,11 @@ func GetUserRolesHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// GrantRoleHandler назначает роль пользователю
+// GrantRoleHandler назначает роль пользователю (маршрут закрыт правом manage_roles)
 func GrantRoleHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUserID := r.Context().Value("userID").(int)
 
-		// Проверяем, что текущий пользователь - superadmin или moderator
-		if !hasRole(db, currentUserID, models.RoleSuperAdmin) && !hasRole(db, currentUserID, models.RoleModerator) {
-			http.Error(w, "Access denied: insufficient permissions", http.StatusForbidden)
-			return
-		}
-
 		var req struct {
 			UserID    int     `json:"user_id"`
 			Role      string  `json:"role"`
@@ -195,17 +189,11 @@ func GrantRoleHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// RevokeRoleHandler отзывает роль у пользователя
+// RevokeRoleHandler отзывает роль у пользователя (маршрут закрыт правом manage_roles)
 func RevokeRoleHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUserID := r.Context().Value("userID").(int)
 
-		// Проверяем, что текущий пользователь - superadmin или moderator
-		if !hasRole(db, currentUserID, models.RoleSuperAdmin) && !hasRole(db, currentUserID, models.RoleModerator) {
-			http.Error(w, "Access denied: insufficient permissions", http.StatusForbidden)
-			return
-		}
-
 		var req struct {
 			UserID int    `json:"user_id"`
 			Role   string `json:"role"`
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"backend/models"
+	"database"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduledPublishBatchSize - сколько постов публикуется за один проход планировщика
+const scheduledPublishBatchSize = 100
+
+// StartScheduledPostsPublisher запускает фоновую публикацию отложенных постов.
+// Состояние хранится только в БД (status + scheduled_at), поэтому после
+// перезапуска планировщик просто догоняет просроченные посты. Перевод в
+// 'published' делается одним UPDATE ... WHERE status = 'scheduled', так что
+// каждый пост публикуется ровно один раз даже при нескольких репликах.
+func StartScheduledPostsPublisher(db *sql.DB, interval time.Duration) {
+	go func() {
+		log.Printf("⏰ Scheduled posts publisher started (interval: %s)", interval)
+
+		// Сразу публикуем всё, что просрочилось пока сервер был выключен
+		publishDueScheduledPosts(db)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			publishDueScheduledPosts(db)
+		}
+	}()
+}
+
+// publishDueScheduledPosts публикует все посты, время которых наступило
+func publishDueScheduledPosts(db *sql.DB) {
+	for {
+		rows, err := db.Query(ConvertPlaceholders(`
+			UPDATE posts
+			SET status = 'published', created_at = scheduled_at, updated_at = NOW()
+			WHERE id IN (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND is_deleted = FALSE
+				  AND scheduled_at IS NOT NULL AND scheduled_at <= NOW()
+				ORDER BY scheduled_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			) AND status = 'scheduled'
+			RETURNING id, author_id, author_type
+		`), scheduledPublishBatchSize)
+		if err != nil {
+			log.Printf("❌ Scheduled posts publisher: query error: %v", err)
+			return
+		}
+
+		published := 0
+		for rows.Next() {
+			var postID, authorID int
+			var authorType string
+			if err := rows.Scan(&postID, &authorID, &authorType); err != nil {
+				log.Printf("⚠️ Scheduled posts publisher: scan error: %v", err)
+				continue
+			}
+			published++
+
+			log.Printf("✅ Scheduled post %d published (author %s #%d)", postID, authorType, authorID)
+
+			BroadcastToAll("post_published", map[string]interface{}{
+				"post_id":     postID,
+				"author_id":   authorID,
+				"author_type": authorType,
+			})
+
+			// Автор отложенного поста не в сети, уведомление отправляется от имени владельца
+			// (только для постов организаций)
+			go notifyFollowersAboutPost(db, postID, authorType, authorID, 0)
+		}
+		rows.Close()
+
+		if published < scheduledPublishBatchSize {
+			return
+		}
+	}
+}
+
+// ScheduledPostsHandler возвращает отложенные посты текущего пользователя
+// (свои и от имени организаций, где у него есть право публикации)
+func ScheduledPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	query := ConvertPlaceholders(`
+		SELECT p.id, p.author_id, p.author_type, p.content, p.attached_pets,
+		       p.attachments, p.tags, p.status, p.scheduled_at, p.created_at, p.updated_at,
+		       u.name, u.email, u.avatar,
+		       o.name as org_name, o.short_name as org_short_name, o.logo as org_logo,
+		       (SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count
+		FROM posts p
+		LEFT JOIN users u ON p.author_id = u.id AND p.author_type = 'user'
+		LEFT JOIN organizations o ON p.author_id = o.id AND p.author_type = 'organization'
+		WHERE p.status = 'scheduled' AND p.is_deleted = FALSE
+		  AND (
+		      (p.author_type = 'user' AND p.author_id = ?) OR
+		      (p.author_type = 'organization' AND p.author_id IN (
+		          SELECT organization_id FROM organization_members
+		          WHERE user_id = ? AND can_post = TRUE
+		      ))
+		  )
+		ORDER BY p.scheduled_at ASC
+	`)
+
+	rows, err := database.DB.Query(query, userID, userID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения отложенных постов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			sendErrorResponse(w, "Ошибка чтения данных: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		posts = append(posts, post)
+	}
+
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	posts = loadPollsForPosts(posts, userID)
+	posts = loadAttachmentVariantsBatch(posts)
+	for i := range posts {
+		posts[i].CanEdit = checkCanEditPost(userID, &posts[i])
+	}
+
+	sendSuccessResponse(w, posts)
+}
+
+// ScheduledPostHandler - перенос (PUT) или отмена (DELETE) отложенного поста
+// /api/posts/scheduled/{id}
+func ScheduledPostHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/posts/scheduled/")
+	postID, err := strconv.Atoi(path)
+	if err != nil {
+		sendErrorResponse(w, "Неверный ID поста", http.StatusBadRequest)
+		return
+	}
+
+	post, err := getPostByID(postID, userID)
+	if err != nil {
+		sendErrorResponse(w, "Пост не найден", http.StatusNotFound)
+		return
+	}
+
+	if !checkCanEditPost(userID, &post) {
+		sendErrorResponse(w, "Нет прав на изменение этого поста", http.StatusForbidden)
+		return
+	}
+
+	if post.Status != "scheduled" {
+		sendErrorResponse(w, "Пост не является отложенным", http.StatusConflict)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		rescheduleScheduledPost(w, r, userID, postID)
+	case http.MethodDelete:
+		cancelScheduledPost(w, r, userID, postID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// rescheduleScheduledPost переносит время публикации
+func rescheduleScheduledPost(w http.ResponseWriter, r *http.Request, userID, postID int) {
+	var req struct {
+		ScheduledAt string `json:"scheduled_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	scheduledAt := parseTime(req.ScheduledAt)
+	if scheduledAt == nil || !scheduledAt.After(time.Now()) {
+		sendErrorResponse(w, "Время публикации должно быть в будущем", http.StatusBadRequest)
+		return
+	}
+
+	// status = 'scheduled' в условии защищает от гонки с планировщиком
+	result, err := database.DB.Exec(ConvertPlaceholders(`
+		UPDATE posts SET scheduled_at = ?, updated_at = NOW()
+		WHERE id = ? AND status = 'scheduled' AND is_deleted = FALSE
+	`), *scheduledAt, postID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка переноса публикации: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		sendErrorResponse(w, "Пост уже опубликован", http.StatusConflict)
+		return
+	}
+
+	CreateUserLog(database.DB, userID, "post_reschedule",
+		"Перенесена публикация поста #"+strconv.Itoa(postID)+" на "+scheduledAt.Format(time.RFC3339),
+		r.RemoteAddr, r.Header.Get("User-Agent"))
+
+	post, err := getPostByID(postID, userID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка получения поста", http.StatusInternalServerError)
+		return
+	}
+	post.CanEdit = true
+
+	sendSuccessResponse(w, post)
+}
+
+// cancelScheduledPost отменяет публикацию - пост возвращается в черновики
+func cancelScheduledPost(w http.ResponseWriter, r *http.Request, userID, postID int) {
+	result, err := database.DB.Exec(ConvertPlaceholders(`
+		UPDATE posts SET status = 'draft', scheduled_at = NULL, updated_at = NOW()
+		WHERE id = ? AND status = 'scheduled' AND is_deleted = FALSE
+	`), postID)
+	if err != nil {
+		sendErrorResponse(w, "Ошибка отмены публикации: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		sendErrorResponse(w, "Пост уже опубликован", http.StatusConflict)
+		return
+	}
+
+	CreateUserLog(database.DB, userID, "post_schedule_cancel",
+		"Отменена публикация поста #"+strconv.Itoa(postID),
+		r.RemoteAddr, r.Header.Get("User-Agent"))
+
+	sendSuccessResponse(w, map[string]string{"message": "Публикация отменена, пост перемещён в черновики"})
+}
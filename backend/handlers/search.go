@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Выражения tsvector для каждого типа. Должны совпадать с индексами
+// из scripts/add_search_indexes.sql, иначе PostgreSQL не использует GIN.
+const (
+	postSearchDocument = `setweight(to_tsvector('russian', coalesce(p.tags::text, '')), 'A') || ` +
+		`setweight(to_tsvector('russian', coalesce(p.content, '')), 'B')`
+	petSearchDocument = `setweight(to_tsvector('russian', coalesce(pt.name, '')), 'A') || ` +
+		`setweight(to_tsvector('russian', coalesce(pt.breed, '') || ' ' || coalesce(pt.species, '')), 'B')`
+	announcementSearchDocument = `setweight(to_tsvector('russian', coalesce(a.title, '')), 'A') || ` +
+		`setweight(to_tsvector('russian', coalesce(a.description, '')), 'B')`
+	organizationSearchDocument = `setweight(to_tsvector('russian', coalesce(o.name, '') || ' ' || coalesce(o.short_name, '')), 'A')`
+)
+
+// searchDocumentsCTE - общая часть запросов результатов и фасетов.
+// Единственный параметр - поисковая строка пользователя.
+var searchDocumentsCTE = fmt.Sprintf(`
+	WITH q AS (SELECT websearch_to_tsquery('russian', ?) AS query),
+	docs AS (
+		SELECT 'post' AS type, p.id, '' AS title, coalesce(p.content, '') AS body, '' AS image,
+		       p.created_at, %[1]s AS doc
+		FROM posts p, q
+		WHERE p.is_deleted = FALSE AND p.status = 'published' AND %[1]s @@ q.query
+
+		UNION ALL
+
+		SELECT 'pet', pt.id, pt.name, trim(coalesce(pt.species, '') || ' ' || coalesce(pt.breed, '')),
+		       coalesce(pt.photo, ''), pt.created_at, %[2]s
+		FROM pets pt, q
+		WHERE %[2]s @@ q.query
+
+		UNION ALL
+
+		SELECT 'announcement', a.id, a.title, coalesce(a.description, ''), '',
+		       a.created_at, %[3]s
+		FROM pet_announcements a, q
+		WHERE a.is_published = 1 AND a.status = 'active' AND %[3]s @@ q.query
+
+		UNION ALL
+
+		SELECT 'organization', o.id, o.name, coalesce(o.bio, ''), coalesce(o.logo, ''),
+		       o.created_at, %[4]s
+		FROM organizations o, q
+		WHERE o.status = 'active' AND %[4]s @@ q.query
+	)
+`, postSearchDocument, petSearchDocument, announcementSearchDocument, organizationSearchDocument)
+
+// searchTypes - допустимые значения параметра type
+var searchTypes = map[string]bool{
+	models.SearchTypePost:         true,
+	models.SearchTypePet:          true,
+	models.SearchTypeAnnouncement: true,
+	models.SearchTypeOrganization: true,
+}
+
+// SearchHandler - единый полнотекстовый поиск по постам, питомцам, объявлениям и организациям
+// GET /api/search?q=...&type=all|post|pet|announcement|organization&limit=20&offset=0
+func SearchHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if utf8.RuneCountInString(query) < 2 {
+			sendErrorResponse(w, "Поисковый запрос должен содержать минимум 2 символа", http.StatusBadRequest)
+			return
+		}
+
+		searchType := r.URL.Query().Get("type")
+		if searchType == "all" {
+			searchType = ""
+		}
+		if searchType != "" && !searchTypes[searchType] {
+			sendErrorResponse(w, "Неизвестный тип: "+searchType, http.StatusBadRequest)
+			return
+		}
+
+		limit := 20
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+				limit = parsedLimit
+			}
+		}
+
+		offset := 0
+		if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+			if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+				offset = parsedOffset
+			}
+		}
+
+		// Фасеты считаем по всем типам, чтобы клиент мог показать счётчики на вкладках
+		facets := map[string]int{}
+		for t := range searchTypes {
+			facets[t] = 0
+		}
+
+		facetRows, err := db.Query(ConvertPlaceholders(searchDocumentsCTE+`
+			SELECT type, COUNT(*) FROM docs GROUP BY type
+		`), query)
+		if err != nil {
+			log.Printf("❌ Search facets error: %v", err)
+			sendErrorResponse(w, "Ошибка поиска: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for facetRows.Next() {
+			var t string
+			var count int
+			if err := facetRows.Scan(&t, &count); err != nil {
+				continue
+			}
+			facets[t] = count
+		}
+		facetRows.Close()
+
+		total := facets[searchType]
+		if searchType == "" {
+			total = 0
+			for _, count := range facets {
+				total += count
+			}
+		}
+
+		rows, err := db.Query(ConvertPlaceholders(searchDocumentsCTE+`
+			SELECT d.type, d.id, d.title,
+			       ts_headline('russian', d.body, q.query, 'MaxWords=35, MinWords=15, MaxFragments=2'),
+			       d.image, ts_rank(d.doc, q.query) AS rank, d.created_at
+			FROM docs d, q
+			WHERE (? = '' OR d.type = ?)
+			ORDER BY rank DESC, d.created_at DESC, d.id DESC
+			LIMIT ? OFFSET ?
+		`), query, searchType, searchType, limit, offset)
+		if err != nil {
+			log.Printf("❌ Search error: %v", err)
+			sendErrorResponse(w, "Ошибка поиска: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		results := []models.SearchResult{}
+		for rows.Next() {
+			var res models.SearchResult
+			if err := rows.Scan(&res.Type, &res.ID, &res.Title, &res.Snippet, &res.Image, &res.Rank, &res.CreatedAt); err != nil {
+				log.Printf("❌ Error scanning search result: %v", err)
+				continue
+			}
+			results = append(results, res)
+		}
+
+		respType := searchType
+		if respType == "" {
+			respType = "all"
+		}
+
+		log.Printf("🔍 Search %q (type=%q): %d of %d", query, searchType, len(results), total)
+
+		sendSuccessResponse(w, models.SearchResponse{
+			Query:   query,
+			Type:    respType,
+			Results: results,
+			Facets:  facets,
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+			HasMore: offset+len(results) < total,
+		})
+	}
+}
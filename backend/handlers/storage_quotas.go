@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storageQuotaForRole возвращает квоту роли. Значение по умолчанию из
+// models.DefaultStorageQuotas можно переопределить переменной окружения
+// STORAGE_QUOTA_<РОЛЬ>_MB (например STORAGE_QUOTA_SHELTER_ADMIN_MB=51200, -1 - без ограничения).
+func storageQuotaForRole(role string) int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("STORAGE_QUOTA_"+strings.ToUpper(role)+"_MB"), 10, 64); err == nil {
+		if mb < 0 {
+			return models.UnlimitedStorageQuota
+		}
+		return mb << 20
+	}
+	return models.DefaultStorageQuotas[role]
+}
+
+// organizationStorageQuota - квота редакторов организаций (STORAGE_QUOTA_ORGANIZATION_MB)
+func organizationStorageQuota() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("STORAGE_QUOTA_ORGANIZATION_MB"), 10, 64); err == nil {
+		if mb < 0 {
+			return models.UnlimitedStorageQuota
+		}
+		return mb << 20
+	}
+	return models.OrganizationStorageQuota
+}
+
+// largerQuota сравнивает квоты с учётом -1 (без ограничения)
+func largerQuota(a, b int64) int64 {
+	if a == models.UnlimitedStorageQuota || b == models.UnlimitedStorageQuota {
+		return models.UnlimitedStorageQuota
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// storageReservationTTL - резерв, не снятый обработчиком (сбой процесса), перестаёт учитываться
+const storageReservationTTL = time.Hour
+
+// getStorageUsage - занятое место: медиатека, аватар и обложка, вложения отправленных
+// сообщений и зарезервированное незавершёнными загрузками (по частям, напрямую в S3
+// и между проверкой квоты и записью файла)
+func getStorageUsage(db *sql.DB, userID int) (used, reserved int64, err error) {
+	err = db.QueryRow(ConvertPlaceholders(`
+		SELECT
+			COALESCE((SELECT SUM(file_size) FROM user_media WHERE user_id = ?), 0)
+			+ COALESCE((SELECT avatar_size + cover_photo_size FROM users WHERE id = ?), 0)
+			+ COALESCE((SELECT SUM(a.file_size) FROM message_attachments a
+			            JOIN messages m ON m.id = a.message_id WHERE m.sender_id = ?), 0),
+			COALESCE((SELECT SUM(file_size) FROM upload_sessions
+			          WHERE user_id = ? AND status IN ('uploading', 'assembling')), 0)
+			+ COALESCE((SELECT SUM(file_size) FROM direct_uploads WHERE user_id = ? AND status = 'pending'), 0)
+			+ COALESCE((SELECT SUM(size) FROM storage_reservations WHERE user_id = ? AND created_at > ?), 0)
+	`), userID, userID, userID, userID, userID, userID, time.Now().Add(-storageReservationTTL)).Scan(&used, &reserved)
+	return used, reserved, err
+}
+
+// getStorageQuota считает квоту пользователя: переопределение администратора,
+// иначе наибольшая из квот ролей и квоты редактора организации
+func getStorageQuota(db *sql.DB, userID int) (models.StorageQuota, error) {
+	quota := models.StorageQuota{UserID: userID}
+
+	var override models.StorageQuotaOverride
+	var setBy sql.NullInt64
+	var note sql.NullString
+	err := db.QueryRow(ConvertPlaceholders(`
+		SELECT quota_bytes, set_by, note, updated_at FROM user_storage_quotas WHERE user_id = ?
+	`), userID).Scan(&override.QuotaBytes, &setBy, &note, &override.UpdatedAt)
+	switch {
+	case err == nil:
+		override.SetBy = nullIntPtr(setBy)
+		override.Note = note.String
+		quota.Override = &override
+		quota.QuotaBytes = override.QuotaBytes
+		quota.Source = "override"
+	case err == sql.ErrNoRows:
+		roles, err := getUserActiveRoles(db, userID)
+		if err != nil {
+			return quota, err
+		}
+		quota.QuotaBytes = storageQuotaForRole(models.RoleUser)
+		quota.Source = "role:" + models.RoleUser
+		for _, role := range roles {
+			if q := storageQuotaForRole(role); largerQuota(q, quota.QuotaBytes) != quota.QuotaBytes {
+				quota.QuotaBytes = q
+				quota.Source = "role:" + role
+			}
+		}
+
+		var orgEditor bool
+		db.QueryRow(ConvertPlaceholders(`
+			SELECT EXISTS(
+				SELECT 1 FROM organization_members m
+				JOIN organizations o ON o.id = m.organization_id
+				WHERE m.user_id = ? AND m.can_edit = TRUE AND o.status = 'active'
+			)
+		`), userID).Scan(&orgEditor)
+		if q := organizationStorageQuota(); orgEditor && largerQuota(q, quota.QuotaBytes) != quota.QuotaBytes {
+			quota.QuotaBytes = q
+			quota.Source = "organization"
+		}
+	default:
+		return quota, err
+	}
+
+	quota.UsedBytes, quota.ReservedBytes, err = getStorageUsage(db, userID)
+	if err != nil {
+		return quota, err
+	}
+
+	quota.AvailableBytes = models.UnlimitedStorageQuota
+	if quota.QuotaBytes != models.UnlimitedStorageQuota {
+		quota.AvailableBytes = quota.QuotaBytes - quota.UsedBytes - quota.ReservedBytes
+		if quota.AvailableBytes < 0 {
+			quota.AvailableBytes = 0
+		}
+	}
+	return quota, nil
+}
+
+// reserveStorageQuota проверяет, что у пользователя есть место под size байт, и
+// резервирует его в той же транзакции: проверки одного пользователя выполняются
+// по очереди (advisory lock), поэтому параллельные загрузки не превышают квоту.
+// release снимает резерв - вызывается, когда файл учтён своей записью (или не
+// сохранён). Если места нет - отправляет 413 с текущей квотой и возвращает false.
+func reserveStorageQuota(w http.ResponseWriter, db *sql.DB, userID int, size int64) (release func(), ok bool) {
+	failed := func(err error) (func(), bool) {
+		log.Printf("❌ Storage quota check failed for user %d: %v", userID, err)
+		sendErrorResponse(w, "Failed to check storage quota", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return failed(err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка снимается при завершении транзакции; резервы предыдущих загрузок
+	// к этому моменту закоммичены и видны getStorageQuota
+	if _, err := tx.Exec(ConvertPlaceholders(`SELECT pg_advisory_xact_lock(hashtext('storage_quota'), ?)`), userID); err != nil {
+		return failed(err)
+	}
+
+	// Резервы, оставшиеся после сбоя, уже не учитываются - убираем их
+	tx.Exec(ConvertPlaceholders(`DELETE FROM storage_reservations WHERE user_id = ? AND created_at <= ?`),
+		userID, time.Now().Add(-storageReservationTTL))
+
+	quota, err := getStorageQuota(db, userID)
+	if err != nil {
+		return failed(err)
+	}
+
+	if quota.QuotaBytes == models.UnlimitedStorageQuota || size <= quota.AvailableBytes {
+		var reservationID int64
+		err := tx.QueryRow(ConvertPlaceholders(`
+			INSERT INTO storage_reservations (user_id, size) VALUES (?, ?) RETURNING id
+		`), userID, size).Scan(&reservationID)
+		if err != nil {
+			return failed(err)
+		}
+		if err := tx.Commit(); err != nil {
+			return failed(err)
+		}
+		return func() {
+			db.Exec(ConvertPlaceholders(`DELETE FROM storage_reservations WHERE id = ?`), reservationID)
+		}, true
+	}
+
+	log.Printf("🚫 Storage quota exceeded: user %d, size %d, available %d of %d", userID, size, quota.AvailableBytes, quota.QuotaBytes)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(models.Response{
+		Success: false,
+		Error: fmt.Sprintf("Недостаточно места в хранилище: файл %.1f MB, свободно %.1f MB из %.1f MB",
+			float64(size)/1024/1024, float64(quota.AvailableBytes)/1024/1024, float64(quota.QuotaBytes)/1024/1024),
+		Data: quota,
+	})
+	return nil, false
+}
+
+// GetStorageQuotaHandler - квота и занятое место текущего пользователя (GET /api/storage/quota)
+func GetStorageQuotaHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok {
+			sendErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		quota, err := getStorageQuota(db, userID)
+		if err != nil {
+			log.Printf("❌ Error loading storage quota: %v", err)
+			sendErrorResponse(w, "Failed to load storage quota", http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, quota)
+	}
+}
+
+// AdminStorageQuotaHandler - квота пользователя для администратора (маршрут закрыт правом manage_storage_quotas):
+// GET /api/admin/storage-quotas/{userID} - текущая квота
+// PUT /api/admin/storage-quotas/{userID} - переопределить ({"quota_bytes": ..., "note": ...})
+// DELETE /api/admin/storage-quotas/{userID} - вернуть квоту по ролям
+func AdminStorageQuotaHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.Context().Value("userID").(int)
+
+		targetID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/storage-quotas/"), "/"))
+		if err != nil {
+			sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+			return
+		}
+
+		exists, err := userExists(db, targetID)
+		if err != nil || !exists {
+			sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+			return
+		}
+
+		var details, actionType string
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req models.SetStorageQuotaRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+				return
+			}
+			if req.QuotaBytes == nil || (*req.QuotaBytes < 0 && *req.QuotaBytes != models.UnlimitedStorageQuota) {
+				sendErrorResponse(w, "Укажите quota_bytes (-1 - без ограничения)", http.StatusBadRequest)
+				return
+			}
+
+			_, err := db.Exec(ConvertPlaceholders(`
+				INSERT INTO user_storage_quotas (user_id, quota_bytes, set_by, note)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (user_id) DO UPDATE
+				SET quota_bytes = EXCLUDED.quota_bytes, set_by = EXCLUDED.set_by, note = EXCLUDED.note, updated_at = NOW()
+			`), targetID, *req.QuotaBytes, adminID, nullIfEmpty(strings.TrimSpace(req.Note)))
+			if err != nil {
+				log.Printf("❌ Error setting storage quota: %v", err)
+				sendErrorResponse(w, "Ошибка сохранения квоты", http.StatusInternalServerError)
+				return
+			}
+			actionType = models.ActionSetStorageQuota
+			details = fmt.Sprintf("Quota: %d bytes, Note: %s", *req.QuotaBytes, req.Note)
+		case http.MethodDelete:
+			if _, err := db.Exec(ConvertPlaceholders(`DELETE FROM user_storage_quotas WHERE user_id = ?`), targetID); err != nil {
+				log.Printf("❌ Error resetting storage quota: %v", err)
+				sendErrorResponse(w, "Ошибка сброса квоты", http.StatusInternalServerError)
+				return
+			}
+			actionType = models.ActionResetStorageQuota
+			details = "Quota reset to role default"
+		default:
+			sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if actionType != "" {
+			var adminEmail, userName string
+			db.QueryRow(ConvertPlaceholders("SELECT email FROM users WHERE id = ?"), adminID).Scan(&adminEmail)
+			db.QueryRow(ConvertPlaceholders("SELECT name FROM users WHERE id = ?"), targetID).Scan(&userName)
+			CreateAdminLog(adminID, adminEmail, actionType, models.TargetUser, targetID, userName, details,
+				r.RemoteAddr, r.Header.Get("User-Agent"))
+			log.Printf("💾 Storage quota of user %d changed by admin %d: %s", targetID, adminID, details)
+		}
+
+		quota, err := getStorageQuota(db, targetID)
+		if err != nil {
+			log.Printf("❌ Error loading storage quota: %v", err)
+			sendErrorResponse(w, "Ошибка загрузки квоты", http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, quota)
+	}
+}
@@ -50,27 +50,13 @@ func UpdateUserActivityHandler(db *sql.DB) http.HandlerFunc {
 // GetOnlineUsersCountHandler возвращает количество пользователей онлайн
 func GetOnlineUsersCountHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Пользователь считается онлайн, если был активен в последние 5 минут
-		fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
-
-		var onlineCount int
-		err := db.QueryRow(ConvertPlaceholders(`
-			SELECT COUNT(DISTINCT user_id) 
-			FROM user_activity 
-			WHERE last_seen >= ?
-		`), fiveMinutesAgo).Scan(&onlineCount)
-
-		if err != nil {
-			log.Printf("❌ Error getting online users count: %v", err)
-			http.Error(w, "Failed to get online users", http.StatusInternalServerError)
-			return
-		}
-
+		// Пользователь онлайн, если у него есть открытое WebSocket подключение
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
 			"data": map[string]interface{}{
-				"online_count": onlineCount,
+				"online_count":      GetConnectedUsersCount(),
+				"connections_count": GetConnectionsCount(),
 			},
 		})
 	}
@@ -81,11 +67,8 @@ func GetUserActivityStatsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		stats := make(map[string]interface{})
 
-		// Онлайн сейчас (последние 5 минут)
-		fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
-		var onlineNow int
-		db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM user_activity WHERE last_seen >= ?"), fiveMinutesAgo).Scan(&onlineNow)
-		stats["online_now"] = onlineNow
+		// Онлайн сейчас (открытые WebSocket подключения)
+		stats["online_now"] = GetConnectedUsersCount()
 
 		// Активны за последний час
 		oneHourAgo := time.Now().Add(-1 * time.Hour)
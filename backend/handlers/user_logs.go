@@ -133,6 +133,11 @@ func GetUserStorageStatsHandler(db *sql.DB) http.HandlerFunc {
 		stats["media_size_bytes"] = mediaSize
 		stats["media_size_mb"] = float64(mediaSize) / 1024 / 1024
 
+		// Квота хранилища
+		if quota, err := getStorageQuota(db, userID); err == nil {
+			stats["storage_quota"] = quota
+		}
+
 		// Количество питомцев
 		var petsCount int
 		db.QueryRow(ConvertPlaceholders("SELECT COUNT(*) FROM pets WHERE user_id = ?"), userID).Scan(&petsCount)
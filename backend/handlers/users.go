@@ -11,7 +11,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // convertPlaceholdersUsers converts ? to $1, $2, $3 for PostgreSQL
@@ -236,14 +235,14 @@ func handleGetUser(w http.ResponseWriter, _ *http.Request, id int) {
 		user.LastSeen = parseTime(lastSeenTime.String)
 	}
 
-	// Проверяем онлайн статус (активен в последние 5 минут)
-	if user.LastSeen != nil {
-		fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
-		user.IsOnline = user.LastSeen.After(fiveMinutesAgo)
-	}
+	// Онлайн - есть хотя бы одно открытое WebSocket подключение
+	user.IsOnline = IsUserOnline(user.ID)
 
-	// Возвращаем данные пользователя
-	sendSuccess(w, user)
+	// Возвращаем данные пользователя вместе со счётчиками подписок
+	sendSuccess(w, struct {
+		models.User
+		models.FollowCounts
+	}{user, getFollowCounts(database.DB, id)})
 	log.Printf("✅ User profile loaded from Main Backend: id=%d, name=%s, last_name=%s, is_online=%v", id, user.Name, user.LastName, user.IsOnline)
 }
 
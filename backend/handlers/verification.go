@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"backend/middleware"
 	"database/sql"
 	"encoding/json"
 	"log"
@@ -10,17 +11,11 @@ import (
 	"time"
 )
 
-// VerifyUserHandler верифицирует пользователя (только для модераторов и суперадминов)
+// VerifyUserHandler верифицирует пользователя (маршрут закрыт правом verify_users)
 func VerifyUserHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUserID := r.Context().Value("userID").(int)
 
-		// Проверяем права (только moderator или superadmin)
-		if !hasModeratorRights(db, currentUserID) {
-			http.Error(w, "Access denied: insufficient permissions", http.StatusForbidden)
-			return
-		}
-
 		var req struct {
 			UserID int `json:"user_id"`
 		}
@@ -100,17 +95,11 @@ func VerifyUserHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// UnverifyUserHandler снимает верификацию с пользователя
+// UnverifyUserHandler снимает верификацию с пользователя (маршрут закрыт правом verify_users)
 func UnverifyUserHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUserID := r.Context().Value("userID").(int)
 
-		// Проверяем права (только moderator или superadmin)
-		if !hasModeratorRights(db, currentUserID) {
-			http.Error(w, "Access denied: insufficient permissions", http.StatusForbidden)
-			return
-		}
-
 		var req struct {
 			UserID int `json:"user_id"`
 		}
@@ -168,12 +157,16 @@ func UnverifyUserHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// GetVerifiedUsersHandler возвращает список верифицированных пользователей
+// GetVerifiedUsersHandler возвращает список пользователей со статусом верификации (публичный;
+// email и телефон отдаются только пользователям с правом verify_users)
 func GetVerifiedUsersHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Получаем параметры фильтрации
 		verifiedOnly := r.URL.Query().Get("verified") == "true"
 
+		// Список публичный, контакты видят только модераторы верификации
+		showContacts := middleware.HasPermission(r, "verify_users")
+
 		query := `
 			SELECT 
 				id, email, name, last_name, avatar, cover_photo, bio, 
@@ -226,17 +219,19 @@ func GetVerifiedUsersHandler(db *sql.DB) http.HandlerFunc {
 
 			userMap := map[string]interface{}{
 				"id":          user.ID,
-				"email":       user.Email,
 				"name":        user.Name,
 				"last_name":   user.LastName.String,
 				"avatar":      user.Avatar.String,
 				"cover_photo": user.CoverPhoto.String,
 				"bio":         user.Bio.String,
 				"location":    user.Location.String,
-				"phone":       user.Phone.String,
 				"created_at":  user.CreatedAt,
 				"verified":    user.Verified,
 			}
+			if showContacts {
+				userMap["email"] = user.Email
+				userMap["phone"] = user.Phone.String
+			}
 
 			if user.VerifiedAt.Valid {
 				userMap["verified_at"] = user.VerifiedAt.String
@@ -305,19 +300,3 @@ func GetUserVerificationStatusHandler(db *sql.DB) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 	}
 }
-
-// Вспомогательные функции
-
-func hasModeratorRights(db *sql.DB, userID int) bool {
-	// Проверяем, есть ли у пользователя роль moderator или superadmin
-	var count int
-	err := db.QueryRow(ConvertPlaceholders(`
-		SELECT COUNT(*) FROM user_roles 
-		WHERE user_id = ? 
-		AND (role = 'moderator' OR role = 'superadmin')
-		AND is_active = 1
-		AND (expires_at IS NULL OR expires_at > ?)
-	`), userID, time.Now()).Scan(&count)
-
-	return err == nil && count > 0
-}
@@ -2,43 +2,97 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
-		// Разрешаем подключения с localhost для разработки
 		origin := r.Header.Get("Origin")
-		return origin == "http://localhost:3000" || origin == "http://localhost:3001"
+		// Клиенты без Origin (мобильные приложения, Gateway) авторизуются заголовками
+		if origin == "" {
+			return true
+		}
+		return wsAllowedOrigins()[origin]
 	},
 }
 
+// wsAllowedOrigins - сайты, с которых разрешено подключение к WebSocket.
+// Дополнительные origins задаются через WS_ALLOWED_ORIGINS (через запятую).
+func wsAllowedOrigins() map[string]bool {
+	origins := map[string]bool{
+		"http://localhost:3000":                                  true, // Main frontend (dev)
+		"http://localhost:3001":                                  true,
+		"https://my-projects-zooplatforma.crv1ic.easypanel.host": true, // Main frontend (prod)
+	}
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins[origin] = true
+		}
+	}
+	return origins
+}
+
+// Лимиты соединения
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = 54 * time.Second
+	wsMaxMessageSize = 4096 // события клиента маленькие, сообщения отправляются через REST
+)
+
 // WebSocketMessage - структура сообщения через WebSocket
 type WebSocketMessage struct {
 	Type string      `json:"type"` // "unread_count", "new_message", etc.
 	Data interface{} `json:"data"`
 }
 
-// Client - WebSocket клиент
+// clientEvent - событие от клиента: {"type": "mark_read", "data": {"chat_id": 1}}
+type clientEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Client - одно WebSocket подключение (вкладка браузера, телефон).
+// У пользователя может быть несколько подключений, у каждого свой буфер и heartbeat.
 type Client struct {
-	UserID int
-	Conn   *websocket.Conn
-	Send   chan WebSocketMessage
+	ID          string
+	UserID      int
+	Conn        *websocket.Conn
+	Send        chan WebSocketMessage
+	RemoteAddr  string
+	UserAgent   string
+	ConnectedAt time.Time
+
+	// chatID -> время последнего typing_start (используется только в readPump)
+	typingSentAt map[int]time.Time
 }
 
+// Лимит одновременных подключений одного пользователя: при превышении закрывается самое старое
+const maxConnectionsPerUser = 10
+
 // Hub - управляет WebSocket подключениями
 type Hub struct {
-	clients    map[int]*Client // userID -> Client
+	clients    map[int]map[*Client]bool // userID -> подключения пользователя
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan WebSocketMessage
 	mu         sync.RWMutex
 	db         *sql.DB
+
+	// Рассылка между экземплярами backend (nil - один экземпляр)
+	pubsub HubPubSub
+	outbox chan HubEvent
+	remote map[string]*remoteInstance // instanceID -> онлайн пользователи экземпляра
 }
 
 var hub *Hub
@@ -46,13 +100,36 @@ var hub *Hub
 // InitWebSocketHub - инициализирует WebSocket hub
 func InitWebSocketHub(db *sql.DB) {
 	hub = &Hub{
-		clients:    make(map[int]*Client),
+		clients:    make(map[int]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan WebSocketMessage),
 		db:         db,
+		remote:     make(map[string]*remoteInstance),
 	}
+
+	// Транспорт настраивается до запуска hub: run публикует события присутствия
+	if pubsub, err := newHubPubSubFromEnv(db); err != nil {
+		log.Printf("⚠️ WebSocket pub/sub disabled, events stay on this instance: %v", err)
+	} else if pubsub != nil {
+		if err := pubsub.Subscribe(hub.handleRemoteEvent); err != nil {
+			log.Printf("⚠️ WebSocket pub/sub subscribe failed: %v", err)
+			pubsub.Close()
+		} else {
+			hub.pubsub = pubsub
+			hub.outbox = make(chan HubEvent, hubOutboxSize)
+		}
+	}
+
 	go hub.run()
+
+	if hub.pubsub != nil {
+		go hub.runPubSub()
+
+		// Узнаём, кто онлайн на остальных экземплярах
+		hub.publish(HubEvent{Kind: hubEventSync})
+		log.Printf("✅ WebSocket pub/sub enabled (instance %s)", instanceID)
+	}
 }
 
 // run - основной цикл hub
@@ -61,62 +138,123 @@ func (h *Hub) run() {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
-			h.clients[client.UserID] = client
+			conns, ok := h.clients[client.UserID]
+			if !ok {
+				conns = make(map[*Client]bool)
+				h.clients[client.UserID] = conns
+			}
+			conns[client] = true
+			if len(conns) > maxConnectionsPerUser {
+				h.removeClient(oldestClient(conns))
+			}
+			firstConnection := len(conns) == 1
+			total := len(conns)
 			h.mu.Unlock()
-			log.Printf("🔌 WebSocket: User %d connected (total: %d)", client.UserID, len(h.clients))
+			log.Printf("🔌 WebSocket: User %d connected (connection %s, devices: %d)", client.UserID, client.ID, total)
+
+			if firstConnection {
+				go h.touchLastSeen(client)
+				h.publish(HubEvent{Kind: hubEventPresence, UserID: client.UserID, Online: true})
+			}
 
 			// Отправляем текущее количество непрочитанных сообщений
 			go h.sendUnreadCount(client.UserID)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
-				close(client.Send)
-			}
+			removed := h.removeClient(client)
+			_, online := h.clients[client.UserID]
 			h.mu.Unlock()
-			log.Printf("🔌 WebSocket: User %d disconnected (total: %d)", client.UserID, len(h.clients))
+			if !removed {
+				// Подключение уже закрыто hub (переполнение буфера или лимит устройств)
+				continue
+			}
+			log.Printf("🔌 WebSocket: User %d disconnected (connection %s)", client.UserID, client.ID)
+			h.clientRemoved(client, online)
 
 		case message := <-h.broadcast:
-			// Broadcast to all clients
-			h.mu.RLock()
-			for _, client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client.UserID)
+			type evicted struct {
+				client *Client
+				online bool
+			}
+			var slow []evicted
+			h.mu.Lock()
+			for _, conns := range h.clients {
+				for client := range conns {
+					select {
+					case client.Send <- message:
+					default:
+						slow = append(slow, evicted{client: client})
+					}
 				}
 			}
-			h.mu.RUnlock()
+			for i, e := range slow {
+				h.removeClient(e.client)
+				_, slow[i].online = h.clients[e.client.UserID]
+			}
+			h.mu.Unlock()
+
+			for _, e := range slow {
+				log.Printf("⚠️ WebSocket: send buffer full for user %d, closing connection %s", e.client.UserID, e.client.ID)
+				h.clientRemoved(e.client, e.online)
+			}
 		}
 	}
 }
 
+// removeClient удаляет подключение и закрывает его канал. Вызывается под h.mu.Lock.
+// Возвращает false, если подключение уже было удалено.
+func (h *Hub) removeClient(client *Client) bool {
+	conns, ok := h.clients[client.UserID]
+	if !ok || !conns[client] {
+		return false
+	}
+	delete(conns, client)
+	close(client.Send)
+	if len(conns) == 0 {
+		delete(h.clients, client.UserID)
+	}
+	return true
+}
+
+// clientRemoved завершает отключение клиента после removeClient: если это было
+// последнее подключение пользователя, фиксирует время выхода и публикует offline.
+// Вызывается без h.mu.
+func (h *Hub) clientRemoved(client *Client, online bool) {
+	if online {
+		return
+	}
+	go h.touchLastSeen(client)
+	h.publish(HubEvent{Kind: hubEventPresence, UserID: client.UserID, Online: false})
+}
+
+func oldestClient(conns map[*Client]bool) *Client {
+	var oldest *Client
+	for client := range conns {
+		if oldest == nil || client.ConnectedAt.Before(oldest.ConnectedAt) {
+			oldest = client
+		}
+	}
+	return oldest
+}
+
+// touchLastSeen обновляет user_activity.last_seen при входе и выходе пользователя.
+// Онлайн статус берётся из hub, last_seen показывается, когда пользователь офлайн.
+func (h *Hub) touchLastSeen(client *Client) {
+	if err := UpdateUserActivity(h.db, client.UserID, client.RemoteAddr, client.UserAgent); err != nil {
+		log.Printf("❌ Error updating last_seen for user %d: %v", client.UserID, err)
+	}
+}
+
 // sendUnreadCount - отправляет количество непрочитанных сообщений пользователю
 func (h *Hub) sendUnreadCount(userID int) {
-	var count int
-	err := h.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM messages
-		WHERE receiver_id = $1 AND is_read = FALSE
-	`, userID).Scan(&count)
-
+	count, err := countUnreadMessages(h.db, userID)
 	if err != nil {
 		log.Printf("❌ Error getting unread count for user %d: %v", userID, err)
 		return
 	}
 
-	h.mu.RLock()
-	client, ok := h.clients[userID]
-	h.mu.RUnlock()
-
-	if ok {
-		client.Send <- WebSocketMessage{
-			Type: "unread_count",
-			Data: map[string]int{"count": count},
-		}
-	}
+	SendToUser(userID, "unread_count", map[string]int{"count": count})
 }
 
 // NotifyUnreadCount - уведомляет пользователя об изменении количества непрочитанных
@@ -133,16 +271,79 @@ func NotifyNewMessage(userID int, message interface{}) {
 		return
 	}
 
+	SendToUser(userID, "new_message", message)
+}
+
+// NotifyMessagesRead - сообщает отправителю, что его сообщения в чате прочитаны
+// до lastReadMessageID включительно
+func NotifyMessagesRead(senderID, chatID, readerID, lastReadMessageID int, readAt time.Time) {
+	SendToUser(senderID, "messages_read", map[string]interface{}{
+		"chat_id":              chatID,
+		"reader_id":            readerID,
+		"last_read_message_id": lastReadMessageID,
+		"read_at":              readAt,
+	})
+}
+
+// SendToUser - отправляет произвольное событие на все устройства пользователя,
+// в том числе подключенные к другим экземплярам backend
+func SendToUser(userID int, messageType string, data interface{}) {
+	if hub == nil {
+		return
+	}
+
+	hub.deliverToUser(userID, WebSocketMessage{Type: messageType, Data: data})
+	hub.publishToUser(userID, messageType, data)
+}
+
+// deliverToUser отправляет событие подключениям пользователя на этом экземпляре
+func (h *Hub) deliverToUser(userID int, message WebSocketMessage) {
+	// Отправка под RLock: hub закрывает каналы только под Lock
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients[userID] {
+		client.trySend(message)
+	}
+}
+
+// send отправляет событие только в это подключение (ответы на события клиента)
+func (c *Client) send(messageType string, data interface{}) {
 	hub.mu.RLock()
-	client, ok := hub.clients[userID]
-	hub.mu.RUnlock()
+	defer hub.mu.RUnlock()
+
+	if hub.clients[c.UserID][c] {
+		c.trySend(WebSocketMessage{Type: messageType, Data: data})
+	}
+}
 
-	if ok {
-		client.Send <- WebSocketMessage{
-			Type: "new_message",
-			Data: message,
+// trySend кладёт событие в буфер подключения без блокировки. Вызывается под hub.mu.
+// Медленное устройство теряет событие, но не задерживает остальные.
+func (c *Client) trySend(message WebSocketMessage) {
+	select {
+	case c.Send <- message:
+	default:
+		log.Printf("⚠️ WebSocket: send buffer full for user %d (connection %s), dropping %s", c.UserID, c.ID, message.Type)
+	}
+}
+
+// IsUserOnline - есть ли у пользователя хотя бы одно живое подключение (на любом экземпляре)
+func IsUserOnline(userID int) bool {
+	if hub == nil {
+		return false
+	}
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if len(hub.clients[userID]) > 0 {
+		return true
+	}
+	for _, instance := range hub.remote {
+		if instance.users[userID] {
+			return true
 		}
 	}
+	return false
 }
 
 // HandleWebSocket - обработчик WebSocket подключений
@@ -154,6 +355,10 @@ func HandleWebSocket(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		if hub == nil {
+			http.Error(w, "WebSocket is not available", http.StatusServiceUnavailable)
+			return
+		}
 
 		// Upgrade HTTP connection to WebSocket
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -164,9 +369,13 @@ func HandleWebSocket(db *sql.DB) http.HandlerFunc {
 
 		// Создаем клиента
 		client := &Client{
-			UserID: userID,
-			Conn:   conn,
-			Send:   make(chan WebSocketMessage, 256),
+			ID:          uuid.New().String(),
+			UserID:      userID,
+			Conn:        conn,
+			Send:        make(chan WebSocketMessage, 256),
+			RemoteAddr:  r.RemoteAddr,
+			UserAgent:   r.Header.Get("User-Agent"),
+			ConnectedAt: time.Now(),
 		}
 
 		// Регистрируем клиента
@@ -180,7 +389,7 @@ func HandleWebSocket(db *sql.DB) http.HandlerFunc {
 
 // writePump - отправляет сообщения клиенту
 func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(wsPingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -189,7 +398,7 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
 			if !ok {
 				// Hub закрыл канал
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -204,7 +413,7 @@ func (c *Client) writePump() {
 
 		case <-ticker.C:
 			// Ping для поддержания соединения
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -219,15 +428,16 @@ func (c *Client) readPump() {
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.Conn.SetReadLimit(wsMaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
 		return nil
 	})
 
 	for {
-		var msg map[string]interface{}
-		err := c.Conn.ReadJSON(&msg)
+		var event clientEvent
+		err := c.Conn.ReadJSON(&event)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("❌ WebSocket read error: %v", err)
@@ -235,9 +445,118 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// Обрабатываем сообщения от клиента (если нужно)
-		log.Printf("📨 WebSocket message from user %d: %v", c.UserID, msg)
+		c.handleEvent(event)
+	}
+}
+
+// handleEvent обрабатывает событие от клиента. Ответы и ошибки отправляются
+// только в это подключение.
+func (c *Client) handleEvent(event clientEvent) {
+	switch event.Type {
+	case "ping":
+		// Проверка соединения на уровне приложения (браузер не видит ping/pong фреймы)
+		c.send("pong", nil)
+
+	case "get_unread_count":
+		NotifyUnreadCount(c.UserID)
+
+	case "mark_read":
+		// Пользователь увидел сообщения чата до message_id (без message_id - все)
+		data, ok := c.chatEventData(event)
+		if !ok {
+			return
+		}
+		go markMessagesAsRead(hub.db, data.ChatID, c.UserID, data.MessageID)
+
+	case "delivered":
+		// Устройство получило new_message - подтверждаем доставку
+		data, ok := c.chatEventData(event)
+		if !ok {
+			return
+		}
+		go markMessagesDelivered(hub.db, c.UserID, data.ChatID, data.MessageID)
+
+	case "typing_start", "typing_stop":
+		c.handleTyping(event)
+
+	default:
+		c.sendError(event.Type, "Unknown event type")
+	}
+}
+
+// chatEvent - данные событий чата: {"chat_id": 1, "message_id": 10}
+type chatEvent struct {
+	ChatID    int `json:"chat_id"`
+	MessageID int `json:"message_id"`
+}
+
+// chatEventData разбирает событие чата и проверяет, что пользователь - участник чата
+func (c *Client) chatEventData(event clientEvent) (chatEvent, bool) {
+	var data chatEvent
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.ChatID == 0 {
+		c.sendError(event.Type, "chat_id is required")
+		return data, false
 	}
+	if !isUserInChat(hub.db, data.ChatID, c.UserID) {
+		c.sendError(event.Type, "Access denied")
+		return data, false
+	}
+	return data, true
+}
+
+// handleTyping пересылает typing_start/typing_stop остальным участникам чата.
+// Повторные typing_start чаще typingThrottle не пересылаются.
+func (c *Client) handleTyping(event clientEvent) {
+	var data chatEvent
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.ChatID == 0 {
+		c.sendError(event.Type, "chat_id is required")
+		return
+	}
+
+	if c.typingSentAt == nil {
+		c.typingSentAt = make(map[int]time.Time)
+	}
+	if event.Type == "typing_start" {
+		if time.Since(c.typingSentAt[data.ChatID]) < typingThrottle {
+			return
+		}
+		c.typingSentAt[data.ChatID] = time.Now()
+	} else {
+		if _, typing := c.typingSentAt[data.ChatID]; !typing {
+			return
+		}
+		delete(c.typingSentAt, data.ChatID)
+	}
+
+	participants, err := chatParticipantIDs(hub.db, data.ChatID)
+	if err != nil || !slices.Contains(participants, c.UserID) {
+		c.sendError(event.Type, "Access denied")
+		return
+	}
+	// Набор текста - тоже активность: скрыт, если пользователь не показывает онлайн статус
+	if !showsOnlineStatus(hub.db, c.UserID) {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"chat_id": data.ChatID,
+		"user_id": c.UserID,
+	}
+	if event.Type == "typing_start" {
+		payload["expires_in"] = typingExpiresIn
+	}
+	for _, userID := range participants {
+		if userID != c.UserID {
+			SendToUser(userID, event.Type, payload)
+		}
+	}
+}
+
+func (c *Client) sendError(eventType, message string) {
+	c.send("error", map[string]string{
+		"event": eventType,
+		"error": message,
+	})
 }
 
 // BroadcastToAll - отправляет сообщение всем подключенным клиентам
@@ -250,9 +569,18 @@ func BroadcastToAll(messageType string, data interface{}) {
 		Type: messageType,
 		Data: data,
 	}
+
+	if hub.pubsub != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("❌ WebSocket pub/sub: failed to encode %s: %v", messageType, err)
+			return
+		}
+		hub.publish(HubEvent{Kind: hubEventBroadcast, Type: messageType, Data: raw})
+	}
 }
 
-// GetConnectedUsersCount - возвращает количество подключенных пользователей
+// GetConnectedUsersCount - возвращает количество подключенных пользователей (на всех экземплярах)
 func GetConnectedUsersCount() int {
 	if hub == nil {
 		return 0
@@ -260,5 +588,33 @@ func GetConnectedUsersCount() int {
 
 	hub.mu.RLock()
 	defer hub.mu.RUnlock()
-	return len(hub.clients)
+	if len(hub.remote) == 0 {
+		return len(hub.clients)
+	}
+
+	users := make(map[int]bool, len(hub.clients))
+	for userID := range hub.clients {
+		users[userID] = true
+	}
+	for _, instance := range hub.remote {
+		for userID := range instance.users {
+			users[userID] = true
+		}
+	}
+	return len(users)
+}
+
+// GetConnectionsCount - возвращает количество открытых подключений на этом экземпляре
+func GetConnectionsCount() int {
+	if hub == nil {
+		return 0
+	}
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	count := 0
+	for _, conns := range hub.clients {
+		count += len(conns)
+	}
+	return count
 }
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Виды событий hub, которые рассылаются между экземплярами backend
+const (
+	hubEventUser      = "user"              // событие для устройств одного пользователя
+	hubEventBroadcast = "broadcast"         // событие для всех подключенных
+	hubEventPresence  = "presence"          // пользователь подключился/отключился на экземпляре
+	hubEventSnapshot  = "presence_snapshot" // все онлайн пользователи экземпляра (heartbeat)
+	hubEventSync      = "presence_sync"     // запрос снимков у остальных экземпляров
+	hubEventRef       = "ref"               // событие сохранено в ws_events (не влезло в NOTIFY)
+)
+
+// HubEvent - событие hub в транспорте между экземплярами
+type HubEvent struct {
+	Origin  string          `json:"origin"` // ID экземпляра-отправителя
+	Kind    string          `json:"kind"`
+	UserID  int             `json:"user_id,omitempty"`
+	Type    string          `json:"type,omitempty"` // тип WebSocket сообщения
+	Data    json.RawMessage `json:"data,omitempty"`
+	Online  bool            `json:"online,omitempty"`
+	UserIDs []int           `json:"user_ids,omitempty"`
+	Ref     int64           `json:"ref,omitempty"`
+}
+
+// HubPubSub - транспорт событий hub между экземплярами backend.
+// Без транспорта hub работает в пределах одного процесса.
+type HubPubSub interface {
+	// Publish отправляет событие всем экземплярам (включая текущий)
+	Publish(event HubEvent) error
+	// Subscribe запускает приём событий; handler вызывается последовательно
+	Subscribe(handler func(HubEvent)) error
+	Close() error
+}
+
+// Интервалы обмена присутствием между экземплярами
+const (
+	hubSnapshotInterval = 30 * time.Second
+	hubInstanceTimeout  = 90 * time.Second // экземпляр без heartbeat считается остановленным
+	hubOutboxSize       = 1024
+)
+
+// instanceID - идентификатор этого экземпляра backend в событиях pub/sub
+var instanceID = uuid.New().String()
+
+// newHubPubSubFromEnv выбирает транспорт по WS_PUBSUB: postgres (по умолчанию при
+// заданном DATABASE_URL) или local - без рассылки между экземплярами.
+func newHubPubSubFromEnv(db *sql.DB) (HubPubSub, error) {
+	mode := os.Getenv("WS_PUBSUB")
+	dsn := os.Getenv("DATABASE_URL")
+	if mode == "" {
+		mode = "local"
+		if dsn != "" {
+			mode = "postgres"
+		}
+	}
+
+	switch mode {
+	case "local":
+		return nil, nil
+	case "postgres":
+		if dsn == "" {
+			return nil, fmt.Errorf("DATABASE_URL is required for WS_PUBSUB=postgres")
+		}
+		return NewPostgresPubSub(db, dsn, "ws_events")
+	}
+	return nil, fmt.Errorf("unknown WS_PUBSUB %q (expected local or postgres)", mode)
+}
+
+// Максимальный размер payload NOTIFY - 8000 байт, оставляем запас
+const pgNotifyMaxPayload = 7900
+
+// PostgresPubSub - рассылка событий через PostgreSQL LISTEN/NOTIFY
+type PostgresPubSub struct {
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+}
+
+func NewPostgresPubSub(db *sql.DB, dsn, channel string) (*PostgresPubSub, error) {
+	listener := pq.NewListener(dsn, 2*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			log.Printf("⚠️ WebSocket pub/sub: disconnected from PostgreSQL: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Println("✅ WebSocket pub/sub: reconnected to PostgreSQL")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("⚠️ WebSocket pub/sub: connection attempt failed: %v", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen %s: %v", channel, err)
+	}
+
+	return &PostgresPubSub{db: db, listener: listener, channel: channel}, nil
+}
+
+func (p *PostgresPubSub) Publish(event HubEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > pgNotifyMaxPayload {
+		var id int64
+		err := p.db.QueryRow(ConvertPlaceholders(`
+			INSERT INTO ws_events (payload) VALUES (?) RETURNING id
+		`), string(payload)).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to store large event: %v", err)
+		}
+		payload, _ = json.Marshal(HubEvent{Origin: event.Origin, Kind: hubEventRef, Ref: id})
+	}
+
+	_, err = p.db.Exec(ConvertPlaceholders("SELECT pg_notify(?, ?)"), p.channel, string(payload))
+	return err
+}
+
+func (p *PostgresPubSub) Subscribe(handler func(HubEvent)) error {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case n, ok := <-p.listener.Notify:
+				if !ok {
+					return
+				}
+				if n == nil {
+					// Переподключение: часть событий могла потеряться
+					handler(HubEvent{Kind: hubEventSync})
+					continue
+				}
+
+				var event HubEvent
+				if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+					log.Printf("❌ WebSocket pub/sub: invalid event: %v", err)
+					continue
+				}
+				if event.Kind == hubEventRef {
+					if event.Origin == instanceID {
+						continue
+					}
+					if err := p.loadEvent(event.Ref, &event); err != nil {
+						log.Printf("❌ WebSocket pub/sub: failed to load event %d: %v", event.Ref, err)
+						continue
+					}
+				}
+				handler(event)
+
+			case <-ticker.C:
+				// Проверка соединения (pq рекомендует периодический Ping) и очистка ws_events
+				go p.listener.Ping()
+				if _, err := p.db.Exec(`DELETE FROM ws_events WHERE created_at < NOW() - INTERVAL '5 minutes'`); err != nil {
+					log.Printf("⚠️ WebSocket pub/sub: failed to clean up ws_events: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *PostgresPubSub) loadEvent(id int64, event *HubEvent) error {
+	var payload string
+	err := p.db.QueryRow(ConvertPlaceholders("SELECT payload FROM ws_events WHERE id = ?"), id).Scan(&payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(payload), event)
+}
+
+func (p *PostgresPubSub) Close() error {
+	return p.listener.Close()
+}
+
+// remoteInstance - онлайн пользователи другого экземпляра backend
+type remoteInstance struct {
+	users  map[int]bool
+	seenAt time.Time
+}
+
+// publish ставит событие в очередь отправки. Очередь сохраняет порядок событий
+// (new_message приходит раньше unread_count) и не задерживает HTTP обработчики.
+func (h *Hub) publish(event HubEvent) {
+	if h.pubsub == nil {
+		return
+	}
+	event.Origin = instanceID
+
+	select {
+	case h.outbox <- event:
+	default:
+		log.Printf("⚠️ WebSocket pub/sub: outbox full, dropping %s %s", event.Kind, event.Type)
+	}
+}
+
+// publishToUser рассылает событие пользователя остальным экземплярам
+func (h *Hub) publishToUser(userID int, messageType string, data interface{}) {
+	if h.pubsub == nil {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("❌ WebSocket pub/sub: failed to encode %s: %v", messageType, err)
+		return
+	}
+	h.publish(HubEvent{Kind: hubEventUser, UserID: userID, Type: messageType, Data: raw})
+}
+
+// runPubSub отправляет события из очереди и рассылает снимок присутствия (heartbeat)
+func (h *Hub) runPubSub() {
+	ticker := time.NewTicker(hubSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-h.outbox:
+			if err := h.pubsub.Publish(event); err != nil {
+				log.Printf("❌ WebSocket pub/sub: publish %s failed: %v", event.Kind, err)
+			}
+
+		case <-ticker.C:
+			h.publishSnapshot()
+			h.expireRemoteInstances()
+		}
+	}
+}
+
+func (h *Hub) publishSnapshot() {
+	h.mu.RLock()
+	userIDs := make([]int, 0, len(h.clients))
+	for userID := range h.clients {
+		userIDs = append(userIDs, userID)
+	}
+	h.mu.RUnlock()
+
+	h.publish(HubEvent{Kind: hubEventSnapshot, UserIDs: userIDs})
+}
+
+func (h *Hub) expireRemoteInstances() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, instance := range h.remote {
+		if time.Since(instance.seenAt) > hubInstanceTimeout {
+			delete(h.remote, id)
+			log.Printf("🔌 WebSocket pub/sub: instance %s timed out (%d users offline)", id, len(instance.users))
+		}
+	}
+}
+
+// handleRemoteEvent применяет событие, полученное от другого экземпляра
+func (h *Hub) handleRemoteEvent(event HubEvent) {
+	if event.Origin == instanceID {
+		// Свои события уже доставлены локально
+		return
+	}
+
+	switch event.Kind {
+	case hubEventUser:
+		h.deliverToUser(event.UserID, WebSocketMessage{Type: event.Type, Data: event.Data})
+
+	case hubEventBroadcast:
+		h.broadcast <- WebSocketMessage{Type: event.Type, Data: event.Data}
+
+	case hubEventPresence:
+		h.mu.Lock()
+		instance := h.remoteInstance(event.Origin)
+		if event.Online {
+			instance.users[event.UserID] = true
+		} else {
+			delete(instance.users, event.UserID)
+		}
+		h.mu.Unlock()
+
+	case hubEventSnapshot:
+		h.mu.Lock()
+		instance := h.remoteInstance(event.Origin)
+		instance.users = make(map[int]bool, len(event.UserIDs))
+		for _, userID := range event.UserIDs {
+			instance.users[userID] = true
+		}
+		h.mu.Unlock()
+
+	case hubEventSync:
+		h.publishSnapshot()
+		if event.Origin == "" {
+			// Локальный сигнал о переподключении - запрашиваем снимки у остальных
+			h.publish(HubEvent{Kind: hubEventSync})
+		}
+	}
+}
+
+// remoteInstance возвращает запись экземпляра и отмечает его активным. Вызывается под h.mu.Lock.
+func (h *Hub) remoteInstance(id string) *remoteInstance {
+	instance, ok := h.remote[id]
+	if !ok {
+		instance = &remoteInstance{users: make(map[int]bool)}
+		h.remote[id] = instance
+	}
+	instance.seenAt = time.Now()
+	return instance
+}
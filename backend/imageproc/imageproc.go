@@ -0,0 +1,160 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+// Size - вариант изображения: длинная сторона не больше MaxSide
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// Sizes - варианты, которые генерируются для каждой загруженной фотографии
+var Sizes = []Size{
+	{Name: "thumb", MaxSide: 320},
+	{Name: "medium", MaxSide: 800},
+	{Name: "large", MaxSide: 1600},
+}
+
+const (
+	originalJPEGQuality = 92
+	variantJPEGQuality  = 82
+	variantWebPQuality  = 80
+)
+
+// MaxPixels - предельный размер изображения (ширина × высота). Декодированное
+// изображение занимает ~4 байта на пиксель, поэтому маленький файл с огромными
+// размерами (decompression bomb) отклоняется до декодирования.
+const MaxPixels = 50_000_000
+
+// ErrTooLarge - размеры изображения больше MaxPixels
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// ErrUnsupported - формат не перекодируется (например, анимированный GIF), оригинал сохраняется как есть
+var ErrUnsupported = errors.New("image format is not processed")
+
+// Output - закодированный файл
+type Output struct {
+	Size   string // thumb, medium, large
+	Format string // webp, jpeg
+	Ext    string // .webp, .jpg
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Result - очищенный оригинал и его варианты
+type Result struct {
+	Width        int
+	Height       int
+	Original     []byte // перекодированный оригинал без EXIF/GPS
+	OriginalExt  string // .jpg или .png
+	OriginalMime string
+	Variants     []Output
+}
+
+// Process декодирует изображение, поворачивает его по EXIF Orientation и
+// перекодирует оригинал и все варианты. Перекодирование отбрасывает все
+// метаданные (EXIF, GPS, XMP), поэтому в хранилище они не попадают.
+func Process(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	if format == "gif" {
+		return nil, ErrUnsupported
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	bounds := img.Bounds()
+	result := &Result{Width: bounds.Dx(), Height: bounds.Dy()}
+
+	// JPEG остаётся JPEG, PNG и WebP - PNG (могут быть с прозрачностью)
+	var original bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&original, img, &jpeg.Options{Quality: originalJPEGQuality})
+		result.OriginalExt, result.OriginalMime = ".jpg", "image/jpeg"
+	} else {
+		err = png.Encode(&original, img)
+		result.OriginalExt, result.OriginalMime = ".png", "image/png"
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode original: %w", err)
+	}
+	result.Original = original.Bytes()
+
+	flat := flatten(img)
+	for _, size := range Sizes {
+		resized := img
+		if bounds.Dx() > size.MaxSide || bounds.Dy() > size.MaxSide {
+			resized = imaging.Fit(img, size.MaxSide, size.MaxSide, imaging.Lanczos)
+		}
+		w, h := resized.Bounds().Dx(), resized.Bounds().Dy()
+
+		var webpBuf bytes.Buffer
+		if err := webp.Encode(&webpBuf, resized, &webp.Options{Quality: variantWebPQuality}); err != nil {
+			return nil, fmt.Errorf("encode %s webp: %w", size.Name, err)
+		}
+		result.Variants = append(result.Variants, Output{Size: size.Name, Format: "webp", Ext: ".webp", Width: w, Height: h, Data: webpBuf.Bytes()})
+
+		// JPEG без альфа-канала: прозрачные области заливаются белым
+		jpegSource := flat
+		if resized != img {
+			jpegSource = flatten(resized)
+		}
+		var jpegBuf bytes.Buffer
+		if err := jpeg.Encode(&jpegBuf, jpegSource, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("encode %s jpeg: %w", size.Name, err)
+		}
+		result.Variants = append(result.Variants, Output{Size: size.Name, Format: "jpeg", Ext: ".jpg", Width: w, Height: h, Data: jpegBuf.Bytes()})
+	}
+
+	return result, nil
+}
+
+// TotalSize - байт в хранилище: оригинал и все варианты
+func (r *Result) TotalSize() int64 {
+	size := int64(len(r.Original))
+	for _, v := range r.Variants {
+		size += int64(len(v.Data))
+	}
+	return size
+}
+
+// VariantFileName - имя файла варианта рядом с оригиналом: {base}_{size}{ext}
+func VariantFileName(base, size, ext string) string {
+	return base + "_" + size + ext
+}
+
+func flatten(img image.Image) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"backend/handlers"
+	appmw "backend/middleware"
+	"backend/storage"
 	"database"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/zooplatforma/pkg/clients"
@@ -50,8 +54,9 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 			log.Printf("⚠️ Blocked request from unauthorized origin: %s", origin)
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Cookie")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -63,6 +68,17 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// authMiddleware - проверка сессии (pkg/middleware) и блокировки аккаунта.
+// Все авторизованные маршруты подключаются через него, а не напрямую.
+func authMiddleware(next http.Handler) http.Handler {
+	return middleware.AuthMiddleware(appmw.RequireNotBanned(next))
+}
+
+// optionalAuthMiddleware - то же для маршрутов, доступных гостям
+func optionalAuthMiddleware(next http.Handler) http.Handler {
+	return middleware.OptionalAuthMiddleware(appmw.RequireNotBanned(next))
+}
+
 // enableCORSHandler - версия для http.Handler (используется с middleware)
 func enableCORSHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,8 +109,9 @@ func enableCORSHandler(next http.Handler) http.Handler {
 			log.Printf("⚠️ Blocked request from unauthorized origin: %s", origin)
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Cookie")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -136,6 +153,36 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// Ключ подписи ссылок на приватные медиафайлы
+	if err := handlers.ValidateMediaURLSecret(); err != nil {
+		log.Fatal("Media URL secret is not configured:", err)
+	}
+
+	// S3-хранилище (USE_S3=true); без него файлы хранятся локально
+	if err := storage.InitS3(); err != nil {
+		log.Printf("⚠️  S3 initialization failed: %v", err)
+		log.Println("📁 Falling back to local file storage")
+	}
+
+	// WebSocket hub для событий в реальном времени (сообщения, уведомления, обработка медиа)
+	handlers.InitWebSocketHub(database.DB)
+
+	// Фоновая публикация отложенных постов
+	handlers.StartScheduledPostsPublisher(database.DB, 30*time.Second)
+
+	// Фоновый пересчёт ранжирования ленты "Для вас"
+	handlers.StartFeedRanker(database.DB, 2*time.Minute)
+
+	// Воркеры очереди обработки медиа (транскодирование видео)
+	mediaWorkers := 2
+	if n, err := strconv.Atoi(os.Getenv("MEDIA_WORKERS")); err == nil && n > 0 {
+		mediaWorkers = n
+	}
+	handlers.StartMediaWorkers(database.DB, mediaWorkers)
+
+	// Очистка брошенных загрузок по частям
+	handlers.StartUploadJanitor(database.DB, time.Hour)
+
 	// Public API routes (register BEFORE root route)
 	http.HandleFunc("/api/health", enableCORS(handleHealth))
 	http.HandleFunc("/api/auth/register", enableCORS(handlers.RegisterHandler))
@@ -148,20 +195,22 @@ func main() {
 	http.HandleFunc("/api/users/", enableCORS(handlers.UserHandler)) // Публичный просмотр профилей пользователей
 
 	// Protected routes
-	http.Handle("/api/users", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.UsersHandler))))
-	http.Handle("/api/profile", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateProfileHandler))))
-	http.Handle("/api/profile/avatar", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.UploadAvatarHandler))))
-	http.Handle("/api/profile/avatar/delete", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAvatarHandler))))
-	http.Handle("/api/profile/cover", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.UploadCoverPhotoHandler))))
-	http.Handle("/api/profile/cover/delete", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteCoverPhotoHandler))))
-	http.Handle("/api/posts/drafts", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.DraftsHandler))))
+	http.Handle("/api/users", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.UsersHandler))))
+	http.Handle("/api/profile", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.UpdateProfileHandler))))
+	http.Handle("/api/profile/avatar", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.UploadAvatarHandler))))
+	http.Handle("/api/profile/avatar/delete", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.DeleteAvatarHandler))))
+	http.Handle("/api/profile/cover", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.UploadCoverPhotoHandler))))
+	http.Handle("/api/profile/cover/delete", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.DeleteCoverPhotoHandler))))
+	http.Handle("/api/posts/drafts", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.DraftsHandler))))
+	http.Handle("/api/posts/scheduled", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.ScheduledPostsHandler))))
+	http.Handle("/api/posts/scheduled/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.ScheduledPostHandler))))
 
 	// /api/posts - GET с опциональной авторизацией, POST требует авторизации
 	http.Handle("/api/posts", enableCORSHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			middleware.AuthMiddleware(http.HandlerFunc(handlers.PostsHandler)).ServeHTTP(w, r)
+			authMiddleware(http.HandlerFunc(handlers.PostsHandler)).ServeHTTP(w, r)
 		} else {
-			middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.PostsHandler)).ServeHTTP(w, r)
+			optionalAuthMiddleware(http.HandlerFunc(handlers.PostsHandler)).ServeHTTP(w, r)
 		}
 	})))
 
@@ -186,62 +235,85 @@ func main() {
 		// /like endpoint
 		if strings.HasSuffix(path, "/like") {
 			if r.Method == http.MethodGet {
-				middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.LikesHandler)).ServeHTTP(w, r)
+				optionalAuthMiddleware(http.HandlerFunc(handlers.LikesHandler)).ServeHTTP(w, r)
 			} else {
-				middleware.AuthMiddleware(http.HandlerFunc(handlers.LikesHandler)).ServeHTTP(w, r)
+				authMiddleware(http.HandlerFunc(handlers.LikesHandler)).ServeHTTP(w, r)
 			}
 			return
 		}
 
 		// Обычные посты /api/posts/{id}
 		if r.Method == http.MethodGet {
-			middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.PostHandler)).ServeHTTP(w, r)
+			optionalAuthMiddleware(http.HandlerFunc(handlers.PostHandler)).ServeHTTP(w, r)
 		} else {
-			middleware.AuthMiddleware(http.HandlerFunc(handlers.PostHandler)).ServeHTTP(w, r)
+			authMiddleware(http.HandlerFunc(handlers.PostHandler)).ServeHTTP(w, r)
 		}
 	})))
 
 	// Comments
-	http.Handle("/api/comments/post/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.CommentsHandler))))
-	http.Handle("/api/comments/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteCommentHandler))))
+	http.Handle("/api/comments/post/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.CommentsHandler))))
+	http.Handle("/api/comments/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.DeleteCommentHandler))))
 
 	// Polls
-	http.Handle("/api/polls/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.VoteHandler))))
+	http.Handle("/api/polls/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.VoteHandler))))
+
+	// Search - публичный полнотекстовый поиск
+	http.HandleFunc("/api/search", enableCORS(handlers.SearchHandler(database.DB)))
 
 	// Pets
-	http.Handle("/api/pets", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.PetsHandler))))
+	http.Handle("/api/pets", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.PetsHandler))))
 	http.HandleFunc("/api/pets/user/", enableCORS(handlers.UserPetsHandler))       // Публичный endpoint для просмотра питомцев
 	http.HandleFunc("/api/pets/curated/", enableCORS(handlers.CuratedPetsHandler)) // Публичный endpoint для просмотра курируемых питомцев
-	// /api/pets/:id - GET публичный, DELETE требует авторизации
+	// /api/pets/:id - GET публичный (медкарта учитывает текущего пользователя),
+	// PUT/PATCH/DELETE, POST медзаписей и передачи, история изменений и владения требуют авторизации
 	http.Handle("/api/pets/", enableCORSHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodDelete {
-			middleware.AuthMiddleware(http.HandlerFunc(handlers.PetHandler)).ServeHTTP(w, r)
+		if r.Method != http.MethodGet || strings.HasSuffix(r.URL.Path, "/history") || strings.HasSuffix(r.URL.Path, "/provenance") {
+			authMiddleware(http.HandlerFunc(handlers.PetHandler)).ServeHTTP(w, r)
 		} else {
-			handlers.PetHandler(w, r)
+			optionalAuthMiddleware(http.HandlerFunc(handlers.PetHandler)).ServeHTTP(w, r)
 		}
 	})))
+	http.Handle("/api/medical-records/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.MedicalRecordHandler))))
+	http.Handle("/api/pet-transfers", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.PetTransfersHandler))))
+	http.Handle("/api/pet-transfers/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.PetTransferHandler))))
 
 	// Pet Announcements
-	http.Handle("/api/announcements", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AnnouncementsHandler))))
-	http.Handle("/api/announcements/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AnnouncementHandler))))
-	http.Handle("/api/announcements/posts/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AnnouncementPostsHandler))))
-	http.Handle("/api/announcements/donations/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AnnouncementDonationsHandler))))
+	http.Handle("/api/announcements", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AnnouncementsHandler))))
+	http.Handle("/api/announcements/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AnnouncementHandler))))
+	http.Handle("/api/announcements/posts/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AnnouncementPostsHandler))))
+	http.Handle("/api/announcements/donations/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AnnouncementDonationsHandler))))
+
+	// Adoption - заявки на усыновление по объявлениям "ищет дом"
+	http.Handle("/api/adoption/forms/", enableCORSHandler(authMiddleware(handlers.AdoptionFormHandler(database.DB))))
+	http.Handle("/api/adoption/applications", enableCORSHandler(authMiddleware(handlers.AdoptionApplicationsHandler(database.DB))))
+	http.Handle("/api/adoption/applications/", enableCORSHandler(authMiddleware(handlers.AdoptionApplicationHandler(database.DB))))
+
+	// Alert zones - оповещения о потерянных/найденных животных рядом
+	http.Handle("/api/alerts/zones", enableCORSHandler(authMiddleware(handlers.AlertZonesHandler(database.DB))))
+	http.Handle("/api/alerts/zones/", enableCORSHandler(authMiddleware(handlers.AlertZoneHandler(database.DB))))
+
+	// Подписки на пользователей и организации
+	http.Handle("/api/follows/follow", enableCORSHandler(authMiddleware(handlers.FollowHandler(database.DB))))
+	http.Handle("/api/follows/unfollow", enableCORSHandler(authMiddleware(handlers.UnfollowHandler(database.DB))))
+	http.Handle("/api/follows/status", enableCORSHandler(authMiddleware(handlers.FollowStatusHandler(database.DB))))
+	http.Handle("/api/follows/followers", enableCORSHandler(authMiddleware(handlers.FollowersHandler(database.DB))))
+	http.Handle("/api/follows/following", enableCORSHandler(authMiddleware(handlers.FollowingHandler(database.DB))))
 
 	// Friends
-	http.Handle("/api/friends", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetFriendsHandler))))
-	http.Handle("/api/friends/requests", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetFriendRequestsHandler))))
-	http.Handle("/api/friends/send", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.SendFriendRequestHandler))))
-	http.Handle("/api/friends/accept", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AcceptFriendRequestHandler))))
-	http.Handle("/api/friends/reject", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.RejectFriendRequestHandler))))
-	http.Handle("/api/friends/remove", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.RemoveFriendHandler))))
-	http.Handle("/api/friends/status", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetFriendshipStatusHandler))))
+	http.Handle("/api/friends", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetFriendsHandler))))
+	http.Handle("/api/friends/requests", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetFriendRequestsHandler))))
+	http.Handle("/api/friends/send", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.SendFriendRequestHandler))))
+	http.Handle("/api/friends/accept", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AcceptFriendRequestHandler))))
+	http.Handle("/api/friends/reject", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.RejectFriendRequestHandler))))
+	http.Handle("/api/friends/remove", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.RemoveFriendHandler))))
+	http.Handle("/api/friends/status", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetFriendshipStatusHandler))))
 
 	// Notifications
 	notificationsHandler := &handlers.NotificationsHandler{DB: database.DB}
-	http.Handle("/api/notifications", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(notificationsHandler.GetNotifications))))
-	http.Handle("/api/notifications/unread", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(notificationsHandler.GetUnreadCount))))
-	http.Handle("/api/notifications/read-all", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(notificationsHandler.MarkAllAsRead))))
-	http.Handle("/api/notifications/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	http.Handle("/api/notifications", enableCORSHandler(authMiddleware(http.HandlerFunc(notificationsHandler.GetNotifications))))
+	http.Handle("/api/notifications/unread", enableCORSHandler(authMiddleware(http.HandlerFunc(notificationsHandler.GetUnreadCount))))
+	http.Handle("/api/notifications/read-all", enableCORSHandler(authMiddleware(http.HandlerFunc(notificationsHandler.MarkAllAsRead))))
+	http.Handle("/api/notifications/", enableCORSHandler(authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "PUT" {
 			notificationsHandler.MarkAsRead(w, r)
 		} else {
@@ -250,68 +322,112 @@ func main() {
 	}))))
 
 	// Organizations
-	http.HandleFunc("/api/organizations/all", enableCORS(handlers.GetAllOrganizationsHandler))                                               // Публичный endpoint
-	http.Handle("/api/organizations/my", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetMyOrganizationsHandler)))) // Мои организации для публикации
-	http.Handle("/api/organizations", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateOrganizationHandler))))
-	http.Handle("/api/organizations/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.OrganizationHandler)))) // GET и PUT для конкретной организации
-	http.Handle("/api/organizations/user/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetUserOrganizationsHandler))))
-	http.Handle("/api/organizations/members/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetOrganizationMembersHandler))))
-	http.Handle("/api/organizations/members/add", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AddMemberHandler))))
-	http.Handle("/api/organizations/members/update", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateMemberHandler))))
-	http.Handle("/api/organizations/members/remove", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.RemoveMemberHandler))))
+	http.HandleFunc("/api/organizations/all", enableCORS(handlers.GetAllOrganizationsHandler))                                    // Публичный endpoint
+	http.HandleFunc("/api/organizations/nearby", enableCORS(handlers.GetNearbyOrganizationsHandler))                              // Публичный поиск по радиусу
+	http.Handle("/api/organizations/my", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetMyOrganizationsHandler)))) // Мои организации для публикации
+	http.Handle("/api/organizations", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.CreateOrganizationHandler))))
+	http.Handle("/api/organizations/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.OrganizationHandler)))) // GET и PUT для конкретной организации
+	http.Handle("/api/organizations/user/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetUserOrganizationsHandler))))
+	http.Handle("/api/organizations/members/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.GetOrganizationMembersHandler))))
+	http.Handle("/api/organizations/members/add", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.AddMemberHandler))))
+	http.Handle("/api/organizations/members/update", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.UpdateMemberHandler))))
+	http.Handle("/api/organizations/members/remove", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.RemoveMemberHandler))))
+	http.Handle("/api/organizations/chat", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.OrganizationChatHandler)))) // Чат команды организации
 
 	// Messenger (личные чаты 1-1)
-	http.Handle("/api/chats", enableCORSHandler(middleware.AuthMiddleware(handlers.GetChatsHandler(database.DB))))
-	http.Handle("/api/chats/", enableCORSHandler(middleware.AuthMiddleware(handlers.GetChatMessagesHandler(database.DB))))
-	http.Handle("/api/messages/send", enableCORSHandler(middleware.AuthMiddleware(handlers.SendMessageHandler(database.DB))))
-	http.Handle("/api/messages/send-media", enableCORSHandler(middleware.AuthMiddleware(handlers.SendMediaMessageHandler(database.DB))))
-	http.Handle("/api/messages/unread", enableCORSHandler(middleware.AuthMiddleware(handlers.GetUnreadCountHandler(database.DB))))
+	http.Handle("/api/chats", enableCORSHandler(authMiddleware(handlers.GetChatsHandler(database.DB))))
+	http.Handle("/api/chats/groups", enableCORSHandler(authMiddleware(handlers.CreateGroupChatHandler(database.DB))))
+	// /api/chats/{id} - сообщения чата (GET), название группы (PATCH),
+	// /read - курсоры и отметка прочтения, /members - участники группы, /leave - выход из группы
+	http.Handle("/api/chats/", enableCORSHandler(authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/read"):
+			handlers.ChatReadHandler(database.DB).ServeHTTP(w, r)
+		case strings.HasSuffix(path, "/leave"):
+			handlers.LeaveChatHandler(database.DB).ServeHTTP(w, r)
+		case strings.Contains(path, "/members"):
+			handlers.ChatMembersHandler(database.DB).ServeHTTP(w, r)
+		case r.Method == http.MethodPatch:
+			handlers.UpdateGroupChatHandler(database.DB).ServeHTTP(w, r)
+		default:
+			handlers.GetChatMessagesHandler(database.DB).ServeHTTP(w, r)
+		}
+	}))))
+	http.Handle("/api/messages/send", enableCORSHandler(authMiddleware(handlers.SendMessageHandler(database.DB))))
+	http.Handle("/api/messages/send-media", enableCORSHandler(authMiddleware(handlers.SendMediaMessageHandler(database.DB))))
+	http.Handle("/api/messages/unread", enableCORSHandler(authMiddleware(handlers.GetUnreadCountHandler(database.DB))))
+
+	// WebSocket (origin проверяется при upgrade); /api/ws - тот же обработчик через Gateway
+	http.Handle("/ws", authMiddleware(handlers.HandleWebSocket(database.DB)))
+	http.Handle("/api/ws", authMiddleware(handlers.HandleWebSocket(database.DB)))
 
 	// Favorites (избранные питомцы)
-	http.Handle("/api/favorites", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.FavoritesHandler))))
-	http.Handle("/api/favorites/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.FavoriteDetailHandler))))
+	http.Handle("/api/favorites", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.FavoritesHandler))))
+	http.Handle("/api/favorites/", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.FavoriteDetailHandler))))
 
 	// Roles (система ролей)
-	http.Handle("/api/roles/available", enableCORSHandler(middleware.AuthMiddleware(handlers.GetAllRolesHandler(database.DB))))
-	http.Handle("/api/roles/user/", enableCORSHandler(middleware.AuthMiddleware(handlers.GetUserRolesHandler(database.DB))))
-	http.Handle("/api/roles/grant", enableCORSHandler(middleware.AuthMiddleware(handlers.GrantRoleHandler(database.DB))))
-	http.Handle("/api/roles/revoke", enableCORSHandler(middleware.AuthMiddleware(handlers.RevokeRoleHandler(database.DB))))
+	// Права проверяются через appmw.RequirePermission (models.RolePermissions)
+	http.Handle("/api/roles/available", enableCORSHandler(authMiddleware(appmw.RequirePermission("manage_roles", handlers.GetAllRolesHandler(database.DB)))))
+	http.Handle("/api/roles/user/", enableCORSHandler(authMiddleware(appmw.RequirePermission("manage_roles", handlers.GetUserRolesHandler(database.DB)))))
+	http.Handle("/api/roles/grant", enableCORSHandler(authMiddleware(appmw.RequirePermission("manage_roles", handlers.GrantRoleHandler(database.DB)))))
+	http.Handle("/api/roles/revoke", enableCORSHandler(authMiddleware(appmw.RequirePermission("manage_roles", handlers.RevokeRoleHandler(database.DB)))))
 
 	// Verification (верификация пользователей)
-	http.Handle("/api/verification/verify", enableCORSHandler(middleware.AuthMiddleware(handlers.VerifyUserHandler(database.DB))))
-	http.Handle("/api/verification/unverify", enableCORSHandler(middleware.AuthMiddleware(handlers.UnverifyUserHandler(database.DB))))
-	http.HandleFunc("/api/verification/status/", enableCORS(handlers.GetUserVerificationStatusHandler(database.DB)))
-	http.HandleFunc("/api/users/verified", enableCORS(handlers.GetVerifiedUsersHandler(database.DB)))
+	http.Handle("/api/verification/verify", enableCORSHandler(authMiddleware(appmw.RequirePermission("verify_users", handlers.VerifyUserHandler(database.DB)))))
+	http.Handle("/api/verification/unverify", enableCORSHandler(authMiddleware(appmw.RequirePermission("verify_users", handlers.UnverifyUserHandler(database.DB)))))
+	http.HandleFunc("/api/verification/status/", enableCORS(handlers.GetUserVerificationStatusHandler(database.DB)))             // Публичный статус (для галочки в профиле)
+	http.Handle("/api/users/verified", enableCORSHandler(optionalAuthMiddleware(handlers.GetVerifiedUsersHandler(database.DB)))) // Публичный список; email и телефон - только с правом verify_users
 
 	// Admin Logs (логи действий администраторов)
-	http.Handle("/api/admin/logs", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.AdminLogsHandler))))
-	http.Handle("/api/admin/logs/stats", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.GetAdminLogStats))))
+	http.Handle("/api/admin/logs", enableCORSHandler(authMiddleware(appmw.RequirePermission("view_admin_logs", handlers.AdminLogsHandler))))
+	http.Handle("/api/admin/logs/stats", enableCORSHandler(authMiddleware(appmw.RequirePermission("view_admin_logs", handlers.GetAdminLogStats))))
 
 	// User Activity (отслеживание активности пользователей)
-	http.Handle("/api/activity/update", enableCORSHandler(middleware.AuthMiddleware(handlers.UpdateUserActivityHandler(database.DB))))
+	http.Handle("/api/activity/update", enableCORSHandler(authMiddleware(handlers.UpdateUserActivityHandler(database.DB))))
 	http.HandleFunc("/api/activity/online", enableCORS(handlers.GetOnlineUsersCountHandler(database.DB)))
 	http.HandleFunc("/api/activity/stats", enableCORS(handlers.GetUserActivityStatsHandler(database.DB)))
 
 	// User Logs (логи действий пользователей)
-	http.Handle("/api/users/logs/", enableCORSHandler(middleware.AuthMiddleware(handlers.GetUserLogsHandler(database.DB))))
-	http.Handle("/api/users/storage/", enableCORSHandler(middleware.AuthMiddleware(handlers.GetUserStorageStatsHandler(database.DB))))
+	http.Handle("/api/users/logs/", enableCORSHandler(authMiddleware(handlers.GetUserLogsHandler(database.DB))))
+	http.Handle("/api/users/storage/", enableCORSHandler(authMiddleware(handlers.GetUserStorageStatsHandler(database.DB))))
+
+	// Storage quotas (квоты хранилища)
+	http.Handle("/api/storage/quota", enableCORSHandler(authMiddleware(handlers.GetStorageQuotaHandler(database.DB))))
+	http.Handle("/api/admin/storage-quotas/", enableCORSHandler(authMiddleware(appmw.RequirePermission("manage_storage_quotas", handlers.AdminStorageQuotaHandler(database.DB)))))
 
 	// Reports (система жалоб)
-	http.Handle("/api/reports", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateReportHandler))))
+	http.Handle("/api/reports", enableCORSHandler(authMiddleware(http.HandlerFunc(handlers.CreateReportHandler))))
+
+	// Moderation (очередь жалоб для модераторов)
+	http.Handle("/api/moderation/reports", enableCORSHandler(authMiddleware(appmw.RequirePermission("view_reports", handlers.ModerationReportsHandler(database.DB)))))
+	http.Handle("/api/moderation/reports/", enableCORSHandler(authMiddleware(appmw.RequirePermission("view_reports", handlers.ModerationReportHandler(database.DB)))))
+	http.Handle("/api/moderation/users/ban", enableCORSHandler(authMiddleware(appmw.RequirePermission("ban_users", handlers.BanUserHandler(database.DB)))))
+	http.Handle("/api/moderation/users/suspend", enableCORSHandler(authMiddleware(appmw.RequirePermission("ban_users", handlers.SuspendUserHandler(database.DB)))))
+	http.Handle("/api/moderation/users/unban", enableCORSHandler(authMiddleware(appmw.RequirePermission("ban_users", handlers.UnbanUserHandler(database.DB)))))
+	http.Handle("/api/moderation/users/bans/", enableCORSHandler(authMiddleware(appmw.RequirePermission("ban_users", handlers.GetUserBansHandler(database.DB)))))
 
 	// Media - более специфичные роуты должны быть первыми
 	mediaHandler := handlers.NewMediaHandler(database.DB)
-	http.Handle("/api/media/upload", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.UploadMedia))))
-	http.Handle("/api/media/stats", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.GetMediaStats))))
-	http.Handle("/api/media/user/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.GetUserMedia))))
+	http.Handle("/api/media/upload", enableCORSHandler(authMiddleware(http.HandlerFunc(mediaHandler.UploadMedia))))
+	http.Handle("/api/media/stats", enableCORSHandler(authMiddleware(http.HandlerFunc(mediaHandler.GetMediaStats))))
+	http.Handle("/api/media/user/", enableCORSHandler(authMiddleware(http.HandlerFunc(mediaHandler.GetUserMedia))))
 	http.HandleFunc("/api/media/file/", enableCORS(mediaHandler.GetMediaFile)) // Public для отображения
-	http.Handle("/api/media/delete/", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.DeleteMedia))))
+	http.Handle("/api/media/status/", enableCORSHandler(authMiddleware(http.HandlerFunc(mediaHandler.GetMediaStatus))))
+	http.Handle("/api/media/delete/", enableCORSHandler(authMiddleware(http.HandlerFunc(mediaHandler.DeleteMedia))))
 
 	// Chunked Upload
 	chunkedHandler := handlers.NewChunkedUploadHandler(database.DB)
-	http.Handle("/api/media/chunked/initiate", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(chunkedHandler.InitiateUpload))))
-	http.Handle("/api/media/chunked/upload", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(chunkedHandler.UploadChunk))))
-	http.Handle("/api/media/chunked/complete", enableCORSHandler(middleware.AuthMiddleware(http.HandlerFunc(chunkedHandler.CompleteUpload))))
+	http.Handle("/api/media/chunked/initiate", enableCORSHandler(authMiddleware(http.HandlerFunc(chunkedHandler.InitiateUpload))))
+	http.Handle("/api/media/chunked/upload", enableCORSHandler(authMiddleware(http.HandlerFunc(chunkedHandler.UploadChunk))))
+	http.Handle("/api/media/chunked/status", enableCORSHandler(authMiddleware(http.HandlerFunc(chunkedHandler.GetUploadStatus))))
+	http.Handle("/api/media/chunked/complete", enableCORSHandler(authMiddleware(http.HandlerFunc(chunkedHandler.CompleteUpload))))
+
+	// Direct upload to S3 via presigned URLs
+	directHandler := handlers.NewDirectUploadHandler(database.DB)
+	http.Handle("/api/media/direct/initiate", enableCORSHandler(authMiddleware(http.HandlerFunc(directHandler.InitiateUpload))))
+	http.Handle("/api/media/direct/complete", enableCORSHandler(authMiddleware(http.HandlerFunc(directHandler.CompleteUpload))))
+	http.Handle("/api/media/direct/abort", enableCORSHandler(authMiddleware(http.HandlerFunc(directHandler.AbortUpload))))
 
 	// Static files - serve uploads directory from project root
 	fs := http.FileServer(http.Dir("../.."))
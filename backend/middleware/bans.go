@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"backend/models"
+	"database"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ActiveBan возвращает действующую блокировку пользователя или nil.
+// Если блокировок несколько - берётся самая длинная (бессрочная в приоритете).
+func ActiveBan(userID int) (*models.UserBan, error) {
+	var ban models.UserBan
+	var expiresAt sql.NullTime
+	var bannedBy sql.NullInt64
+
+	err := database.DB.QueryRow(convertPlaceholders(`
+		SELECT id, user_id, ban_type, reason, banned_by, starts_at, expires_at, created_at
+		FROM user_bans
+		WHERE user_id = ? AND lifted_at IS NULL
+		AND starts_at <= ?
+		AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY expires_at DESC NULLS FIRST
+		LIMIT 1
+	`), userID, time.Now(), time.Now()).Scan(
+		&ban.ID, &ban.UserID, &ban.BanType, &ban.Reason, &bannedBy,
+		&ban.StartsAt, &expiresAt, &ban.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+	if bannedBy.Valid {
+		id := int(bannedBy.Int64)
+		ban.BannedBy = &id
+	}
+
+	return &ban, nil
+}
+
+// checkBan проверяет блокировку пользователя и при необходимости сам пишет ответ.
+// Ошибка БД - 503: без проверки блокировки запрос не пропускаем.
+// Возвращает true, если запрос можно передавать дальше.
+func checkBan(w http.ResponseWriter, userID int) bool {
+	ban, err := ActiveBan(userID)
+	if err != nil {
+		log.Printf("❌ Failed to check ban for user %d: %v", userID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"success":false,"error":"Failed to check account status"}`))
+		return false
+	}
+	if ban != nil {
+		log.Printf("🚫 User %d is banned (%s) until %v", userID, ban.BanType, ban.ExpiresAt)
+		WriteBanned(w, ban)
+		return false
+	}
+	return true
+}
+
+// RequireNotBanned не пропускает заблокированные и приостановленные аккаунты.
+// Ставится внутрь AuthMiddleware/OptionalAuthMiddleware из pkg/middleware -
+// userID берётся из контекста. Гостевые запросы (без userID) проходят.
+// Это единственная проверка блокировки для запросов (вход проверяет LoginHandler).
+func RequireNotBanned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := r.Context().Value("userID").(int); ok && userID != 0 {
+			if !checkBan(w, userID) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteBanned отправляет ответ 403 для заблокированного аккаунта
+func WriteBanned(w http.ResponseWriter, ban *models.UserBan) {
+	message := "Аккаунт заблокирован"
+	if ban.BanType == models.BanTypeSuspension {
+		message = "Аккаунт временно приостановлен"
+	}
+	if ban.ExpiresAt != nil {
+		message += " до " + ban.ExpiresAt.Format("02.01.2006 15:04")
+	}
+	if ban.Reason != "" {
+		message += ". Причина: " + ban.Reason
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   message,
+		"code":    "account_" + ban.BanType,
+		"ban": map[string]interface{}{
+			"ban_type":   ban.BanType,
+			"reason":     ban.Reason,
+			"expires_at": ban.ExpiresAt,
+		},
+	})
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"backend/models"
+	"context"
+	"database"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RequirePermission пропускает запрос только если одна из активных ролей
+// пользователя даёт право permission (см. models.RolePermissions).
+// Должен стоять после AuthMiddleware - userID берётся из контекста.
+// Найденные роли кладутся в контекст как "userRoles".
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"success":false,"error":"Unauthorized"}`))
+			return
+		}
+
+		roles, err := UserRoles(userID)
+		if err != nil {
+			log.Printf("❌ Failed to load roles for user %d: %v", userID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"success":false,"error":"Failed to check permissions"}`))
+			return
+		}
+
+		if !rolesHavePermission(roles, permission) {
+			log.Printf("🔒 Permission denied: user %d, roles %v, required %s", userID, roles, permission)
+			WriteForbidden(w, permission)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "userRoles", roles)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// HasPermission проверяет право у пользователя текущего запроса.
+// Использует роли из контекста (если запрос прошёл через RequirePermission),
+// иначе загружает их из user_roles.
+func HasPermission(r *http.Request, permission string) bool {
+	roles, ok := r.Context().Value("userRoles").([]string)
+	if !ok {
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok || userID == 0 {
+			return false
+		}
+		return UserHasPermission(userID, permission)
+	}
+
+	return rolesHavePermission(roles, permission)
+}
+
+// UserHasPermission проверяет право у произвольного пользователя
+func UserHasPermission(userID int, permission string) bool {
+	roles, err := UserRoles(userID)
+	if err != nil {
+		log.Printf("❌ Failed to load roles for user %d: %v", userID, err)
+		return false
+	}
+
+	return rolesHavePermission(roles, permission)
+}
+
+// UserRoles возвращает активные роли пользователя с учётом expires_at.
+// Базовая роль "user" есть у каждого пользователя, даже без записи в user_roles.
+func UserRoles(userID int) ([]string, error) {
+	rows, err := database.DB.Query(convertPlaceholders(`
+		SELECT role FROM user_roles
+		WHERE user_id = ? AND is_active = 1
+		AND (expires_at IS NULL OR expires_at > ?)
+	`), userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{models.RoleUser}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			continue
+		}
+		if role != models.RoleUser {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, rows.Err()
+}
+
+func rolesHavePermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		if models.HasPermission(role, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteForbidden отправляет единый ответ 403 для недостающего права
+func WriteForbidden(w http.ResponseWriter, permission string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":             false,
+		"error":               "Forbidden: insufficient permissions",
+		"required_permission": permission,
+	})
+}
@@ -27,6 +27,22 @@ const (
 	ActionDeleteUser   = "delete_user"
 	ActionUpdateOrg    = "update_organization"
 	ActionDeleteOrg    = "delete_organization"
+
+	// Модерация жалоб
+	ActionAssignReport  = "assign_report"
+	ActionHidePost      = "hide_post"
+	ActionDeleteComment = "delete_comment"
+	ActionWarnUser      = "warn_user"
+	ActionDismissReport = "dismiss_report"
+
+	// Блокировки пользователей
+	ActionBanUser     = "ban_user"
+	ActionSuspendUser = "suspend_user"
+	ActionUnbanUser   = "unban_user"
+
+	// Квоты хранилища
+	ActionSetStorageQuota   = "set_storage_quota"
+	ActionResetStorageQuota = "reset_storage_quota"
 )
 
 // Типы целей
@@ -35,6 +51,8 @@ const (
 	TargetPost         = "post"
 	TargetRole         = "role"
 	TargetOrganization = "organization"
+	TargetComment      = "comment"
+	TargetReport       = "report"
 )
 
 // AdminLogResponse для API ответа
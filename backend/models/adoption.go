@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Статусы заявки на усыновление
+const (
+	AdoptionStatusSubmitted = "submitted"
+	AdoptionStatusInterview = "interview"
+	AdoptionStatusApproved  = "approved"
+	AdoptionStatusRejected  = "rejected"
+	AdoptionStatusCompleted = "completed"
+)
+
+// AdoptionQuestion - вопрос анкеты приюта
+type AdoptionQuestion struct {
+	ID       string   `json:"id"`   // стабильный ключ ответа, например "has_other_pets"
+	Text     string   `json:"text"` // текст вопроса
+	Type     string   `json:"type"` // text, boolean, choice
+	Options  []string `json:"options,omitempty"`
+	Required bool     `json:"required"`
+}
+
+// AdoptionForm - анкета, которую организация задаёт для своих объявлений "ищет дом"
+type AdoptionForm struct {
+	OrganizationID int                `json:"organization_id"`
+	Questions      []AdoptionQuestion `json:"questions"`
+	UpdatedAt      *time.Time         `json:"updated_at,omitempty"`
+}
+
+// AdoptionApplication - заявка на усыновление питомца из объявления
+type AdoptionApplication struct {
+	ID             int                `json:"id"`
+	AnnouncementID int                `json:"announcement_id"`
+	PetID          int                `json:"pet_id"`
+	OrganizationID *int               `json:"organization_id,omitempty"` // организация питомца на момент подачи
+	ApplicantID    int                `json:"applicant_id"`
+	Questions      []AdoptionQuestion `json:"questions"` // снимок анкеты на момент подачи
+	Answers        map[string]string  `json:"answers"`
+	Message        string             `json:"message,omitempty"`
+	Status         string             `json:"status"`
+	StatusNote     string             `json:"status_note,omitempty"`
+	AssignedTo     *int               `json:"assigned_to,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	CompletedAt    *time.Time         `json:"completed_at,omitempty"`
+
+	// Связанные данные
+	ApplicantName     string `json:"applicant_name,omitempty"`
+	ApplicantAvatar   string `json:"applicant_avatar,omitempty"`
+	AnnouncementTitle string `json:"announcement_title,omitempty"`
+	PetName           string `json:"pet_name,omitempty"`
+}
+
+// CreateAdoptionApplicationRequest - подача заявки
+type CreateAdoptionApplicationRequest struct {
+	AnnouncementID int               `json:"announcement_id"`
+	Answers        map[string]string `json:"answers"`
+	Message        string            `json:"message"`
+}
+
+// UpdateAdoptionStatusRequest - смена статуса заявки
+type UpdateAdoptionStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AlertZone - зона, в которой пользователь хочет получать оповещения
+// о потерянных и найденных животных: город или радиус вокруг точки
+type AlertZone struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name,omitempty"`
+	City      *string   `json:"city,omitempty"`
+	Lat       *float64  `json:"lat,omitempty"`
+	Lon       *float64  `json:"lon,omitempty"`
+	RadiusKm  *float64  `json:"radius_km,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateAlertZoneRequest - город ИЛИ точка с радиусом
+type CreateAlertZoneRequest struct {
+	Name     string   `json:"name"`
+	City     *string  `json:"city,omitempty"`
+	Lat      *float64 `json:"lat,omitempty"`
+	Lon      *float64 `json:"lon,omitempty"`
+	RadiusKm *float64 `json:"radius_km,omitempty"`
+}
@@ -17,9 +17,12 @@ type PetAnnouncement struct {
 	ContactPersonPhone *string `json:"contact_person_phone,omitempty"`
 
 	// Локация
-	LocationCity        *string `json:"location_city,omitempty"`
-	LocationAddress     *string `json:"location_address,omitempty"`
-	LocationCoordinates *string `json:"location_coordinates,omitempty"`
+	LocationCity        *string  `json:"location_city,omitempty"`
+	LocationAddress     *string  `json:"location_address,omitempty"`
+	LocationCoordinates *string  `json:"location_coordinates,omitempty"`
+	LocationLat         *float64 `json:"location_lat,omitempty"`
+	LocationLon         *float64 `json:"location_lon,omitempty"`
+	DistanceKm          *float64 `json:"distance_km,omitempty"` // только при поиске по радиусу
 
 	// Дата события
 	EventDate *time.Time `json:"event_date,omitempty"`
@@ -118,9 +121,11 @@ type CreateAnnouncementRequest struct {
 	ContactPersonPhone *string `json:"contact_person_phone,omitempty"`
 
 	// Локация
-	LocationCity        *string `json:"location_city,omitempty"`
-	LocationAddress     *string `json:"location_address,omitempty"`
-	LocationCoordinates *string `json:"location_coordinates,omitempty"`
+	LocationCity        *string  `json:"location_city,omitempty"`
+	LocationAddress     *string  `json:"location_address,omitempty"`
+	LocationCoordinates *string  `json:"location_coordinates,omitempty"`
+	LocationLat         *float64 `json:"location_lat,omitempty"` // если не переданы - разбираются из location_coordinates
+	LocationLon         *float64 `json:"location_lon,omitempty"`
 
 	// Дата события
 	EventDate *string `json:"event_date,omitempty"`
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// UserBan представляет блокировку (бан) или временную приостановку аккаунта
+type UserBan struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	BanType   string     `json:"ban_type"` // ban, suspension
+	Reason    string     `json:"reason"`
+	BannedBy  *int       `json:"banned_by"` // nil - администратор удалён
+	StartsAt  time.Time  `json:"starts_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil - бессрочно
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy  *int       `json:"lifted_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Типы блокировок
+const (
+	BanTypeBan        = "ban"
+	BanTypeSuspension = "suspension"
+)
+
+// BanUserRequest - запрос на бан или приостановку аккаунта
+type BanUserRequest struct {
+	UserID        int    `json:"user_id"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // 0 - бессрочный бан (для suspension обязателен)
+}
+
+// UnbanUserRequest - запрос на снятие блокировки
+type UnbanUserRequest struct {
+	UserID int    `json:"user_id"`
+	Reason string `json:"reason"`
+}
@@ -4,32 +4,89 @@ import (
 	"time"
 )
 
-// Chat представляет диалог между двумя пользователями
+// Типы чатов
+const (
+	ChatTypeDirect = "direct" // диалог двух пользователей (User1ID/User2ID)
+	ChatTypeGroup  = "group"  // групповой чат, участники в chat_members
+)
+
+// Роли участников группового чата
+const (
+	ChatRoleOwner  = "owner"
+	ChatRoleAdmin  = "admin"
+	ChatRoleMember = "member"
+)
+
+// Chat представляет диалог между двумя пользователями или групповой чат
 type Chat struct {
 	ID            int        `json:"id"`
+	Type          string     `json:"type"`
 	User1ID       int        `json:"user1_id"`
 	User2ID       int        `json:"user2_id"`
 	LastMessageID *int       `json:"last_message_id"`
 	LastMessageAt *time.Time `json:"last_message_at"`
 	CreatedAt     time.Time  `json:"created_at"`
 
+	// Групповой чат
+	Title          string `json:"title,omitempty"`
+	Avatar         string `json:"avatar,omitempty"`
+	OrganizationID *int   `json:"organization_id,omitempty"` // чат команды организации, участники синхронизируются
+	MyRole         string `json:"my_role,omitempty"`
+	MembersCount   int    `json:"members_count,omitempty"`
+
 	// Дополнительные поля для UI
 	OtherUser   *User    `json:"other_user,omitempty"`
 	LastMessage *Message `json:"last_message,omitempty"`
 	UnreadCount int      `json:"unread_count"`
+	// Курсоры прочтения участников (чужие - только если участник показывает онлайн статус)
+	ReadCursors []ChatReadCursor `json:"read_cursors,omitempty"`
+}
+
+// ChatMember - участник группового чата
+type ChatMember struct {
+	ChatID   int       `json:"chat_id"`
+	UserID   int       `json:"user_id"`
+	Role     string    `json:"role"`
+	AddedBy  *int      `json:"added_by,omitempty"`
+	JoinedAt time.Time `json:"joined_at"`
+	User     *User     `json:"user,omitempty"`
 }
 
+// CreateGroupChatRequest - создание группового чата
+type CreateGroupChatRequest struct {
+	Title     string `json:"title"`
+	MemberIDs []int  `json:"member_ids"`
+}
+
+// ChatReadCursor - участник просмотрел сообщения чата до LastReadMessageID включительно.
+// LastDeliveredMessageID - до какого сообщения группы они доставлены на его устройства.
+type ChatReadCursor struct {
+	UserID                 int       `json:"user_id"`
+	LastReadMessageID      int       `json:"last_read_message_id"`
+	ReadAt                 time.Time `json:"read_at"`
+	LastDeliveredMessageID int       `json:"last_delivered_message_id"`
+}
+
+// Статусы сообщения для отправителя
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
 // Message представляет сообщение в чате
 type Message struct {
-	ID         int        `json:"id"`
-	ChatID     int        `json:"chat_id"`
-	SenderID   int        `json:"sender_id"`
-	ReceiverID int        `json:"receiver_id"`
-	Content    string     `json:"content"`
-	IsRead     bool       `json:"is_read"`
-	ReadAt     *time.Time `json:"read_at"`
-	CreatedAt  *time.Time `json:"created_at"`       // Используем указатель для поддержки NULL
-	PetID      *int       `json:"pet_id,omitempty"` // ID животного если это сообщение с животным
+	ID          int        `json:"id"`
+	ChatID      int        `json:"chat_id"`
+	SenderID    int        `json:"sender_id"`
+	ReceiverID  int        `json:"receiver_id"` // 0 - сообщение в групповом чате
+	Content     string     `json:"content"`
+	IsRead      bool       `json:"is_read"`
+	ReadAt      *time.Time `json:"read_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	Status      string     `json:"status"`           // sent, delivered, read
+	CreatedAt   *time.Time `json:"created_at"`       // Используем указатель для поддержки NULL
+	PetID       *int       `json:"pet_id,omitempty"` // ID животного если это сообщение с животным
 
 	// Дополнительные поля для UI
 	Sender      *User               `json:"sender,omitempty"`
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Статусы прямой загрузки в S3
+const (
+	DirectUploadPending   = "pending"
+	DirectUploadCompleted = "completed"
+	DirectUploadAborted   = "aborted"
+	DirectUploadExpired   = "expired"
+)
+
+// InitiateDirectUploadRequest - запрос presigned URL для загрузки напрямую в бакет
+type InitiateDirectUploadRequest struct {
+	FileName  string `json:"file_name"`
+	FileSize  int64  `json:"file_size"`
+	MimeType  string `json:"mime_type"`
+	MediaType string `json:"media_type"` // video, document
+	Private   bool   `json:"private"`    // отдавать только по подписанной ссылке (медицинские документы и т.п.)
+}
+
+// DirectUpload - инструкция для клиента: один PUT по URL или multipart по частям
+type DirectUpload struct {
+	UploadID  string             `json:"upload_id"`
+	Method    string             `json:"method"`            // PUT
+	URL       string             `json:"url,omitempty"`     // одиночная загрузка
+	Headers   map[string]string  `json:"headers,omitempty"` // заголовки, которые нужно отправить с PUT
+	Multipart bool               `json:"multipart"`
+	PartSize  int64              `json:"part_size,omitempty"`
+	Parts     []DirectUploadPart `json:"parts,omitempty"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+// DirectUploadPart - presigned URL одной части multipart-загрузки
+type DirectUploadPart struct {
+	PartNumber int64  `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// CompleteDirectUploadRequest - подтверждение загрузки; для multipart нужны ETag всех частей
+type CompleteDirectUploadRequest struct {
+	UploadID string                     `json:"upload_id"`
+	Parts    []CompleteDirectUploadPart `json:"parts"`
+}
+
+// CompleteDirectUploadPart - часть, загруженная клиентом (ETag из ответа S3)
+type CompleteDirectUploadPart struct {
+	PartNumber int64  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Типы объектов подписки
+const (
+	FollowTargetUser         = "user"
+	FollowTargetOrganization = "organization"
+)
+
+// Follow - односторонняя подписка на пользователя или организацию
+type Follow struct {
+	ID         int       `json:"id"`
+	FollowerID int       `json:"follower_id"`
+	TargetType string    `json:"target_type"` // user, organization
+	TargetID   int       `json:"target_id"`
+	Notify     bool      `json:"notify"` // уведомлять о новых постах (только организации)
+	CreatedAt  time.Time `json:"created_at"`
+
+	// Данные для списков (заполняются отдельно)
+	Name   string `json:"name,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// FollowRequest - подписка/отписка
+type FollowRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Notify     *bool  `json:"notify,omitempty"` // по умолчанию уведомления выключены
+}
+
+// FollowCounts - счётчики подписок в профиле
+type FollowCounts struct {
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+}
@@ -14,9 +14,53 @@ type UserMedia struct {
 	MediaType    string    `json:"media_type"` // photo, video, document, avatar
 	Width        *int      `json:"width,omitempty"`
 	Height       *int      `json:"height,omitempty"`
-	Duration     *int      `json:"duration,omitempty"`
+	Duration     *int      `json:"duration,omitempty"` // секунды (видео)
 	UploadedAt   time.Time `json:"uploaded_at"`
-	URL          string    `json:"url"` // Полный URL для доступа к файлу
+	URL          string    `json:"url"`                  // Полный URL для доступа к файлу
+	Status       string    `json:"status"`               // processing, ready, failed
+	PosterURL    string    `json:"poster_url,omitempty"` // кадр-обложка видео
+	Storage      string    `json:"storage"`              // local, s3
+	IsPrivate    bool      `json:"is_private"`           // URL подписан и действует ограниченное время
+
+	Variants map[string]ImageVariant `json:"variants,omitempty"` // thumb, medium, large (только фото)
+}
+
+// ImageVariant - уменьшенная копия фото в WebP и JPEG
+type ImageVariant struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	WebP   string `json:"webp,omitempty"`
+	JPEG   string `json:"jpeg,omitempty"`
+}
+
+// Состояния медиафайла
+const (
+	MediaStatusProcessing = "processing"
+	MediaStatusReady      = "ready"
+	MediaStatusFailed     = "failed"
+)
+
+// Статусы задачи обработки медиа
+const (
+	MediaJobQueued  = "queued"
+	MediaJobRunning = "running"
+	MediaJobDone    = "done"
+	MediaJobFailed  = "failed"
+)
+
+// MediaJob - задача фоновой обработки медиа (транскодирование видео)
+type MediaJob struct {
+	ID          int        `json:"id"`
+	MediaID     int        `json:"media_id"`
+	JobType     string     `json:"job_type"`
+	Status      string     `json:"status"`
+	Progress    int        `json:"progress"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 // UploadMediaRequest - запрос на загрузку медиа
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Типы медицинских записей
+const (
+	MedicalRecordVaccination   = "vaccination"
+	MedicalRecordDeworming     = "deworming"
+	MedicalRecordSterilization = "sterilization"
+	MedicalRecordDiagnosis     = "diagnosis"
+	MedicalRecordTreatment     = "treatment"
+)
+
+// Видимость медицинской записи (управляет владелец питомца)
+const (
+	MedicalVisibilityPrivate = "private" // владелец, редакторы питомца и выдавшая клиника
+	MedicalVisibilityPublic  = "public"  // все, кто видит карточку питомца
+)
+
+// MedicalRecord - ветеринарная запись питомца.
+// Запись, выданная клиникой (ClinicID != nil), редактируется только сотрудниками
+// этой клиники; владелец может лишь менять её видимость.
+type MedicalRecord struct {
+	ID          int          `json:"id"`
+	PetID       int          `json:"pet_id"`
+	RecordType  string       `json:"record_type"` // vaccination, deworming, sterilization, diagnosis, treatment
+	Title       string       `json:"title"`       // название вакцины, препарата, диагноз
+	Description string       `json:"description,omitempty"`
+	PerformedAt string       `json:"performed_at"`          // дата процедуры (ГГГГ-ММ-ДД)
+	NextDueAt   *string      `json:"next_due_at,omitempty"` // дата следующей процедуры (ревакцинация и т.п.)
+	VetName     string       `json:"vet_name,omitempty"`
+	ClinicID    *int         `json:"clinic_id,omitempty"`
+	ClinicName  string       `json:"clinic_name,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+	Visibility  string       `json:"visibility"`
+	CreatedBy   int          `json:"created_by"`
+	UpdatedBy   *int         `json:"updated_by,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+
+	// Вычисляется для текущего пользователя
+	CanEdit bool `json:"can_edit"`
+}
+
+// MedicalRecordRequest - создание/изменение медицинской записи.
+// ClinicID указывается сотрудником клиники, чтобы выдать запись от её имени.
+type MedicalRecordRequest struct {
+	RecordType  string       `json:"record_type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	PerformedAt string       `json:"performed_at"`
+	NextDueAt   *string      `json:"next_due_at"`
+	VetName     string       `json:"vet_name"`
+	ClinicID    *int         `json:"clinic_id"`
+	Attachments []Attachment `json:"attachments"`
+	Visibility  string       `json:"visibility"`
+}
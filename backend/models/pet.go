@@ -25,13 +25,51 @@ type Pet struct {
 }
 
 type CreatePetRequest struct {
-	Name    string `json:"name"`
-	Species string `json:"species"`
-	Photo   string `json:"photo"`
+	Name         string `json:"name"`
+	Species      string `json:"species"`
+	Breed        string `json:"breed"`
+	Gender       string `json:"gender"`
+	BirthDate    string `json:"birth_date"`
+	Color        string `json:"color"`
+	Size         string `json:"size"`
+	Photo        string `json:"photo"`
+	Status       string `json:"status"`
+	City         string `json:"city"`
+	Region       string `json:"region"`
+	Urgent       bool   `json:"urgent"`
+	Story        string `json:"story"`
+	ContactName  string `json:"contact_name"`
+	ContactPhone string `json:"contact_phone"`
 }
 
+// UpdatePetRequest - PUT заменяет карточку целиком (отсутствующие поля очищаются),
+// PATCH меняет только переданные поля
 type UpdatePetRequest struct {
-	Name    string `json:"name,omitempty"`
-	Species string `json:"species,omitempty"`
-	Photo   string `json:"photo,omitempty"`
+	Name         *string `json:"name,omitempty"`
+	Species      *string `json:"species,omitempty"`
+	Breed        *string `json:"breed,omitempty"`
+	Gender       *string `json:"gender,omitempty"`
+	BirthDate    *string `json:"birth_date,omitempty"`
+	Color        *string `json:"color,omitempty"`
+	Size         *string `json:"size,omitempty"`
+	Photo        *string `json:"photo,omitempty"`
+	Status       *string `json:"status,omitempty"`
+	City         *string `json:"city,omitempty"`
+	Region       *string `json:"region,omitempty"`
+	Urgent       *bool   `json:"urgent,omitempty"`
+	Story        *string `json:"story,omitempty"`
+	ContactName  *string `json:"contact_name,omitempty"`
+	ContactPhone *string `json:"contact_phone,omitempty"`
+}
+
+// PetChange - запись истории изменений карточки питомца (одно поле)
+type PetChange struct {
+	ID            int    `json:"id"`
+	PetID         int    `json:"pet_id"`
+	ChangedBy     int    `json:"changed_by"`
+	ChangedByName string `json:"changed_by_name,omitempty"`
+	Field         string `json:"field"`
+	OldValue      string `json:"old_value"`
+	NewValue      string `json:"new_value"`
+	CreatedAt     string `json:"created_at"`
 }
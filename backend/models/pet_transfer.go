@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Статусы передачи питомца
+const (
+	PetTransferPending   = "pending"
+	PetTransferAccepted  = "accepted"
+	PetTransferDeclined  = "declined"
+	PetTransferCancelled = "cancelled"
+)
+
+// PetTransfer - предложение передать питомца другому пользователю или организации.
+// Владелец меняется только после подтверждения получателем.
+type PetTransfer struct {
+	ID                 int        `json:"id"`
+	PetID              int        `json:"pet_id"`
+	FromUserID         int        `json:"from_user_id"`
+	FromOrganizationID *int       `json:"from_organization_id,omitempty"`
+	ToUserID           *int       `json:"to_user_id,omitempty"`
+	ToOrganizationID   *int       `json:"to_organization_id,omitempty"`
+	OfferedBy          int        `json:"offered_by"`
+	Message            string     `json:"message,omitempty"`
+	Status             string     `json:"status"`
+	RespondedBy        *int       `json:"responded_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	RespondedAt        *time.Time `json:"responded_at,omitempty"`
+
+	// Связанные данные
+	PetName  string `json:"pet_name,omitempty"`
+	PetPhoto string `json:"pet_photo,omitempty"`
+}
+
+// CreatePetTransferRequest - получатель: пользователь или организация
+type CreatePetTransferRequest struct {
+	ToUserID         *int   `json:"to_user_id"`
+	ToOrganizationID *int   `json:"to_organization_id"`
+	Message          string `json:"message"`
+}
+
+// PetOwnershipRecord - запись истории владения (provenance): кто владел и курировал питомца до передачи
+type PetOwnershipRecord struct {
+	ID                 int       `json:"id"`
+	PetID              int       `json:"pet_id"`
+	FromUserID         int       `json:"from_user_id"`
+	FromUserName       string    `json:"from_user_name,omitempty"`
+	FromOrganizationID *int      `json:"from_organization_id,omitempty"`
+	FromOrganization   string    `json:"from_organization_name,omitempty"`
+	FromCuratorID      *int      `json:"from_curator_id,omitempty"`
+	ToUserID           int       `json:"to_user_id"`
+	ToUserName         string    `json:"to_user_name,omitempty"`
+	ToOrganizationID   *int      `json:"to_organization_id,omitempty"`
+	ToOrganization     string    `json:"to_organization_name,omitempty"`
+	Reason             string    `json:"reason"` // transfer, adoption
+	TransferID         *int      `json:"transfer_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
@@ -6,6 +6,8 @@ type Attachment struct {
 	Type     string `json:"type"` // "image" или "video"
 	FileName string `json:"file_name,omitempty"`
 	Size     int64  `json:"size,omitempty"`
+
+	Variants map[string]ImageVariant `json:"variants,omitempty"` // адаптивные размеры для изображений
 }
 
 // Post - универсальный пост в стиле Threads
@@ -17,7 +19,7 @@ type Post struct {
 	AttachedPets  []int         `json:"attached_pets"`          // Массив PetID
 	Attachments   []Attachment  `json:"attachments"`            // Массив медиа-файлов
 	Tags          []string      `json:"tags"`                   // Метки: "ищет дом", "потерян", "найден"
-	Status        string        `json:"status"`                 // "published", "scheduled", "draft"
+	Status        string        `json:"status"`                 // "published", "scheduled", "draft", "hidden" (скрыт модератором)
 	ScheduledAt   *string       `json:"scheduled_at,omitempty"` // Время публикации (ISO 8601)
 	CreatedAt     string        `json:"created_at"`
 	UpdatedAt     string        `json:"updated_at"`
@@ -27,7 +29,8 @@ type Post struct {
 	Pets          []Pet         `json:"pets,omitempty"`         // Прикреплённые питомцы (полные данные)
 	Poll          *Poll         `json:"poll,omitempty"`         // Опрос (если есть)
 	CommentsCount int           `json:"comments_count,omitempty"`
-	CanEdit       bool          `json:"can_edit"` // Может ли текущий пользователь редактировать пост
+	IsFriend      bool          `json:"is_friend,omitempty"` // Автор - друг текущего пользователя
+	CanEdit       bool          `json:"can_edit"`            // Может ли текущий пользователь редактировать пост
 }
 
 // CreatePostRequest - запрос на создание поста
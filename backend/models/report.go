@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Report представляет жалобу пользователя на контент
+type Report struct {
+	ID          int        `json:"id"`
+	ReporterID  int        `json:"reporter_id"`
+	TargetType  string     `json:"target_type"` // post, comment, user, organization, pet
+	TargetID    int        `json:"target_id"`
+	Reason      string     `json:"reason"` // spam, harassment, violence, etc.
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"` // pending, in_review, resolved, dismissed
+	AssignedTo  *int       `json:"assigned_to,omitempty"`
+	AssignedAt  *time.Time `json:"assigned_at,omitempty"`
+	ResolvedBy  *int       `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Resolution  *string    `json:"resolution,omitempty"` // hide_post, delete_comment, warn_user, dismiss
+	ModNote     *string    `json:"moderator_note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Дополнительные поля для UI
+	Reporter *User `json:"reporter,omitempty"`
+}
+
+// Статусы жалоб
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusInReview  = "in_review"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Действия модератора по жалобе
+const (
+	ReportActionHidePost      = "hide_post"
+	ReportActionDeleteComment = "delete_comment"
+	ReportActionWarnUser      = "warn_user"
+	ReportActionDismiss       = "dismiss"
+)
+
+// ResolveReportRequest - запрос на рассмотрение жалобы
+type ResolveReportRequest struct {
+	Action string `json:"action"` // hide_post, delete_comment, warn_user, dismiss
+	Note   string `json:"note"`
+}
+
+// AssignReportRequest - запрос на назначение жалобы модератору
+type AssignReportRequest struct {
+	ModeratorID *int `json:"moderator_id,omitempty"` // Если не указан - назначается на себя
+}
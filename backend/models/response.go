@@ -1,7 +1,8 @@
 package models
 
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"` // курсор следующей страницы (пусто - страниц больше нет)
 }
@@ -100,6 +100,7 @@ var RolePermissions = map[string][]string{
 		"view_reports",
 		"ban_users",
 		"verify_users",
+		"manage_roles",
 	},
 	RoleSuperAdmin: {
 		"*", // Все права
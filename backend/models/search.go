@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Типы результатов поиска
+const (
+	SearchTypePost         = "post"
+	SearchTypePet          = "pet"
+	SearchTypeAnnouncement = "announcement"
+	SearchTypeOrganization = "organization"
+)
+
+// SearchResult - один найденный объект
+type SearchResult struct {
+	Type      string    `json:"type"` // post, pet, announcement, organization
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"` // фрагмент с подсветкой <b>...</b>
+	Image     string    `json:"image,omitempty"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchResponse - результаты поиска с фасетами по типам
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Type    string         `json:"type"`
+	Results []SearchResult `json:"results"`
+	Facets  map[string]int `json:"facets"` // количество совпадений по каждому типу
+	Total   int            `json:"total"`  // всего совпадений с учётом фильтра type
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	HasMore bool           `json:"has_more"`
+}
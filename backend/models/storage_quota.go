@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// UnlimitedStorageQuota - квота без ограничения
+const UnlimitedStorageQuota int64 = -1
+
+// DefaultStorageQuotas - квоты хранилища по ролям (байты). Пользователю
+// достаётся наибольшая из квот его активных ролей.
+var DefaultStorageQuotas = map[string]int64{
+	RoleUser:         1 << 30,  // 1 GB
+	RoleVolunteer:    2 << 30,  // 2 GB
+	RoleShelterAdmin: 20 << 30, // 20 GB
+	RoleClinicAdmin:  10 << 30, // 10 GB
+	RoleModerator:    2 << 30,  // 2 GB
+	RoleSuperAdmin:   UnlimitedStorageQuota,
+}
+
+// OrganizationStorageQuota - квота редакторов активных организаций (фото питомцев приютов и т.п.)
+const OrganizationStorageQuota int64 = 20 << 30 // 20 GB
+
+// StorageQuota - квота и занятое место пользователя
+type StorageQuota struct {
+	UserID         int    `json:"user_id"`
+	QuotaBytes     int64  `json:"quota_bytes"` // -1 - без ограничения
+	UsedBytes      int64  `json:"used_bytes"`
+	ReservedBytes  int64  `json:"reserved_bytes"` // незавершённые загрузки по частям
+	AvailableBytes int64  `json:"available_bytes"`
+	Source         string `json:"source"` // role:<роль>, organization, override
+
+	Override *StorageQuotaOverride `json:"override,omitempty"`
+}
+
+// StorageQuotaOverride - квота, назначенная администратором вручную
+type StorageQuotaOverride struct {
+	QuotaBytes int64     `json:"quota_bytes"`
+	SetBy      *int      `json:"set_by,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SetStorageQuotaRequest - запрос на переопределение квоты (quota_bytes: -1 - без ограничения)
+type SetStorageQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"`
+	Note       string `json:"note"`
+}
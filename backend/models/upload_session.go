@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Статусы сессии загрузки по частям
+const (
+	UploadSessionUploading  = "uploading"
+	UploadSessionAssembling = "assembling"
+	UploadSessionCompleted  = "completed"
+	UploadSessionExpired    = "expired"
+)
+
+// UploadSession - возобновляемая загрузка по частям. Полученные части
+// отмечаются в битовой карте, чтобы клиент мог докачать только недостающие.
+type UploadSession struct {
+	ID             string    `json:"upload_id"`
+	UserID         int       `json:"user_id"`
+	FileName       string    `json:"file_name"`
+	FileSize       int64     `json:"file_size"`
+	MimeType       string    `json:"mime_type"`
+	MediaType      string    `json:"media_type"`
+	ChunkSize      int       `json:"chunk_size"`
+	TotalChunks    int       `json:"total_chunks"`
+	ReceivedChunks int       `json:"received_chunks"`
+	MissingChunks  []int     `json:"missing_chunks"`
+	SHA256         string    `json:"sha256,omitempty"`
+	Status         string    `json:"status"`
+	MediaID        *int      `json:"media_id,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
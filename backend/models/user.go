@@ -23,7 +23,7 @@ type User struct {
 	VerifiedBy        *int       `json:"verified_by,omitempty"`
 	CreatedAt         string     `json:"created_at"`
 	LastSeen          *time.Time `json:"last_seen,omitempty"`
-	IsOnline          bool       `json:"is_online"` // Онлайн статус (активен в последние 5 минут)
+	IsOnline          bool       `json:"is_online"` // Онлайн статус (есть открытое WebSocket подключение)
 }
 
 type UserResponse struct {
@@ -45,7 +45,7 @@ type UserResponse struct {
 	VerifiedAt        *string    `json:"verified_at,omitempty"`
 	CreatedAt         string     `json:"created_at"`
 	LastSeen          *time.Time `json:"last_seen,omitempty"` // Время последней активности
-	IsOnline          bool       `json:"is_online"`           // Онлайн статус (активен в последние 5 минут)
+	IsOnline          bool       `json:"is_online"`           // Онлайн статус (есть открытое WebSocket подключение)
 }
 
 type CreateUserRequest struct {
@@ -0,0 +1,42 @@
+-- Заявки на усыновление по объявлениям "ищет дом" и анкеты приютов
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Анкета организации (JSON массив вопросов)
+CREATE TABLE IF NOT EXISTS adoption_forms (
+    organization_id INTEGER PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    questions TEXT NOT NULL DEFAULT '[]',
+    updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS adoption_applications (
+    id SERIAL PRIMARY KEY,
+    announcement_id INTEGER NOT NULL REFERENCES pet_announcements(id) ON DELETE CASCADE,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL,
+    applicant_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    questions TEXT NOT NULL DEFAULT '[]',  -- снимок анкеты на момент подачи
+    answers TEXT NOT NULL DEFAULT '{}',
+    message TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted'
+        CHECK (status IN ('submitted', 'interview', 'approved', 'rejected', 'completed')),
+    status_note TEXT NOT NULL DEFAULT '',
+    assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+-- Одна открытая заявка от пользователя на объявление
+CREATE UNIQUE INDEX IF NOT EXISTS idx_adoption_applications_open
+    ON adoption_applications(announcement_id, applicant_id)
+    WHERE status NOT IN ('rejected', 'completed');
+
+CREATE INDEX IF NOT EXISTS idx_adoption_applications_announcement ON adoption_applications(announcement_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_adoption_applications_applicant ON adoption_applications(applicant_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_adoption_applications_assigned ON adoption_applications(assigned_to, created_at DESC, id DESC)
+    WHERE assigned_to IS NOT NULL;
+
+COMMIT;
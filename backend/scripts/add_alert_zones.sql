@@ -0,0 +1,33 @@
+-- Зоны оповещений о потерянных/найденных животных
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS alert_zones (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    city TEXT,                    -- зона-город
+    lat DOUBLE PRECISION,         -- или зона-радиус вокруг точки
+    lon DOUBLE PRECISION,
+    radius_km DOUBLE PRECISION,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (city IS NOT NULL OR (lat IS NOT NULL AND lon IS NOT NULL AND radius_km IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_zones_user ON alert_zones(user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_zones_city ON alert_zones(lower(city)) WHERE city IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_alert_zones_point ON alert_zones(lat, lon) WHERE lat IS NOT NULL;
+
+-- Журнал доставленных оповещений: дневной лимит и защита от дублей
+CREATE TABLE IF NOT EXISTS lost_pet_alert_deliveries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    announcement_id INTEGER NOT NULL REFERENCES pet_announcements(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, announcement_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lost_pet_alert_deliveries_user ON lost_pet_alert_deliveries(user_id, created_at);
+
+COMMIT;
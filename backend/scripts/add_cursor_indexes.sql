@@ -0,0 +1,33 @@
+-- ============================================
+-- Индексы для курсорной пагинации по (created_at, id)
+-- База данных: PostgreSQL
+-- Дата: 2026-10-17
+-- ============================================
+
+BEGIN;
+
+-- Общая лента и посты авторов
+CREATE INDEX IF NOT EXISTS idx_posts_feed_cursor ON posts(created_at DESC, id DESC)
+WHERE is_deleted = false AND status = 'published';
+
+CREATE INDEX IF NOT EXISTS idx_posts_author_cursor ON posts(author_id, author_type, created_at DESC, id DESC)
+WHERE is_deleted = false;
+
+-- Корневые комментарии поста
+CREATE INDEX IF NOT EXISTS idx_comments_roots_cursor ON comments(post_id, created_at ASC, id ASC)
+WHERE parent_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id)
+WHERE parent_id IS NOT NULL;
+
+-- Сообщения чата
+CREATE INDEX IF NOT EXISTS idx_messages_chat_cursor ON messages(chat_id, created_at DESC, id DESC);
+
+-- Уведомления
+CREATE INDEX IF NOT EXISTS idx_notifications_user_cursor ON notifications(user_id, created_at DESC, id DESC);
+
+-- Объявления
+CREATE INDEX IF NOT EXISTS idx_pet_announcements_cursor ON pet_announcements(created_at DESC, id DESC)
+WHERE is_published = 1 AND status = 'active';
+
+COMMIT;
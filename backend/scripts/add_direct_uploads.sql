@@ -0,0 +1,33 @@
+-- Прямая загрузка в S3 по presigned URL и приватные медиафайлы
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Где лежит файл: local - UploadDir, s3 - бакет (file_path = ключ объекта)
+ALTER TABLE user_media ADD COLUMN IF NOT EXISTS storage VARCHAR(10) NOT NULL DEFAULT 'local';
+-- Приватный файл отдаётся только по подписанной короткоживущей ссылке
+ALTER TABLE user_media ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS direct_uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    object_key TEXT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    media_type VARCHAR(20) NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    s3_upload_id TEXT,                          -- UploadId multipart-загрузки (NULL - одиночный PUT)
+    part_size BIGINT,
+    total_parts INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, completed, aborted, expired
+    media_id INTEGER REFERENCES user_media(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_user ON direct_uploads(user_id);
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_expires ON direct_uploads(status, expires_at);
+
+COMMIT;
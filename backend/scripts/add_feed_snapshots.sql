@@ -0,0 +1,16 @@
+-- Снимки ленты "Для вас": общие для всех реплик, чтобы курсор, выданный
+-- одной репликой, продолжал тот же снимок на другой
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS feed_snapshots (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    snapshot_id BIGINT NOT NULL,
+    post_ids INTEGER[] NOT NULL,
+    built_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_snapshots_built_at ON feed_snapshots(built_at);
+
+COMMIT;
@@ -0,0 +1,23 @@
+-- Односторонние подписки на пользователей и организации (без дружбы)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS follows (
+    id SERIAL PRIMARY KEY,
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('user', 'organization')),
+    target_id INTEGER NOT NULL,
+    notify BOOLEAN NOT NULL DEFAULT FALSE, -- уведомлять о новых постах (только организации)
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (follower_id, target_type, target_id)
+);
+-- Для уже созданной таблицы: уведомления включаются только явно
+ALTER TABLE follows ALTER COLUMN notify SET DEFAULT FALSE;
+
+-- Подписчики объекта (списки с курсорной пагинацией, счётчики, рассылка уведомлений)
+CREATE INDEX IF NOT EXISTS idx_follows_target ON follows(target_type, target_id, created_at DESC, id DESC);
+-- Подписки пользователя (лента "following")
+CREATE INDEX IF NOT EXISTS idx_follows_follower ON follows(follower_id, target_type, created_at DESC, id DESC);
+
+COMMIT;
@@ -0,0 +1,33 @@
+-- Числовые координаты для поиска по радиусу
+-- (объявления - /api/announcements?lat=&lon=&radius_km=, приюты - /api/organizations/nearby)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+ALTER TABLE pet_announcements ADD COLUMN IF NOT EXISTS location_lat DOUBLE PRECISION;
+ALTER TABLE pet_announcements ADD COLUMN IF NOT EXISTS location_lon DOUBLE PRECISION;
+
+-- Переносим координаты из строки location_coordinates ("55.7558, 37.6173")
+WITH parsed AS (
+    SELECT id,
+           (regexp_match(location_coordinates, '(-?\d+(?:\.\d+)?)[\s,;]+(-?\d+(?:\.\d+)?)'))[1]::double precision AS lat,
+           (regexp_match(location_coordinates, '(-?\d+(?:\.\d+)?)[\s,;]+(-?\d+(?:\.\d+)?)'))[2]::double precision AS lon
+    FROM pet_announcements
+    WHERE location_lat IS NULL
+      AND location_coordinates ~ '-?\d+(\.\d+)?[\s,;]+-?\d+(\.\d+)?'
+)
+UPDATE pet_announcements a
+SET location_lat = parsed.lat, location_lon = parsed.lon
+FROM parsed
+WHERE a.id = parsed.id
+  AND parsed.lat BETWEEN -90 AND 90
+  AND parsed.lon BETWEEN -180 AND 180;
+
+-- Предфильтр по прямоугольнику перед точным расчётом расстояния
+CREATE INDEX IF NOT EXISTS idx_pet_announcements_location ON pet_announcements(location_lat, location_lon)
+WHERE location_lat IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_organizations_geo ON organizations(geo_lat, geo_lon)
+WHERE geo_lat IS NOT NULL AND status = 'active';
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- Групповые чаты: название, участники с ролями, чаты команд организаций
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- direct - диалог двух пользователей (user1_id/user2_id), group - участники в chat_members
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'direct';
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS title VARCHAR(255);
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS avatar TEXT;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+-- Чат команды организации: участники синхронизируются с organization_members.
+-- При удалении организации чат остаётся обычной группой.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+
+ALTER TABLE chats ALTER COLUMN user1_id DROP NOT NULL;
+ALTER TABLE chats ALTER COLUMN user2_id DROP NOT NULL;
+-- Сообщения в группе не имеют получателя, прочтение - через chat_read_cursors
+ALTER TABLE messages ALTER COLUMN receiver_id DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_organization ON chats(organization_id) WHERE organization_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS chat_members (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member', -- owner, admin, member
+    added_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_members_user ON chat_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id, id);
+
+COMMIT;
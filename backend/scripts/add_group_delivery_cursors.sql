@@ -0,0 +1,15 @@
+-- Курсоры доставки в групповых чатах: сообщения группы не имеют получателя,
+-- поэтому доставка, как и прочтение, хранится на участника
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- "Доставлено до сообщения X" (прочитанные считаются доставленными)
+ALTER TABLE chat_read_cursors ADD COLUMN IF NOT EXISTS last_delivered_message_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chat_read_cursors ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+UPDATE chat_read_cursors
+SET last_delivered_message_id = last_read_message_id, delivered_at = COALESCE(delivered_at, read_at)
+WHERE last_delivered_message_id < last_read_message_id;
+
+COMMIT;
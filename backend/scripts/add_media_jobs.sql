@@ -0,0 +1,30 @@
+-- Очередь фоновой обработки медиа (транскодирование видео, постер-кадры)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Состояние файла: processing - ещё обрабатывается, ready - готов, failed - обработка не удалась
+ALTER TABLE user_media ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ready';
+ALTER TABLE user_media ADD COLUMN IF NOT EXISTS poster_path TEXT;
+
+CREATE TABLE IF NOT EXISTS media_jobs (
+    id SERIAL PRIMARY KEY,
+    media_id INTEGER NOT NULL REFERENCES user_media(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_type VARCHAR(30) NOT NULL DEFAULT 'video_transcode',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued, running, done, failed
+    progress INTEGER NOT NULL DEFAULT 0,          -- 0..100
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    last_error TEXT,
+    run_after TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_jobs_queue ON media_jobs(status, run_after);
+CREATE INDEX IF NOT EXISTS idx_media_jobs_media ON media_jobs(media_id);
+
+COMMIT;
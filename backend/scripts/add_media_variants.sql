@@ -0,0 +1,9 @@
+-- Варианты изображений (thumb/medium/large в WebP и JPEG) для медиафайлов
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- JSON с размерами вариантов: {"thumb":{"width":320,"height":240},...}
+ALTER TABLE user_media ADD COLUMN IF NOT EXISTS variants TEXT;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Ветеринарные записи питомцев (прививки, обработки, стерилизация, диагнозы, лечение)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pet_medical_records (
+    id SERIAL PRIMARY KEY,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    record_type VARCHAR(20) NOT NULL
+        CHECK (record_type IN ('vaccination', 'deworming', 'sterilization', 'diagnosis', 'treatment')),
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    performed_at DATE NOT NULL,
+    next_due_at DATE,
+    vet_name TEXT NOT NULL DEFAULT '',
+    clinic_id INTEGER REFERENCES organizations(id),  -- выдавшая ветклиника (запись нельзя "отвязать")
+    attachments TEXT NOT NULL DEFAULT '[]',                             -- JSON массив вложений
+    visibility VARCHAR(10) NOT NULL DEFAULT 'private' CHECK (visibility IN ('private', 'public')),
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    updated_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pet_medical_records_pet ON pet_medical_records(pet_id, performed_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_pet_medical_records_clinic ON pet_medical_records(clinic_id) WHERE clinic_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- Статусы доставки/прочтения сообщений и курсоры прочтения участников чата
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Когда сообщение получило устройство собеседника (NULL - ещё не доставлено)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+-- Прочитанные сообщения считаются доставленными
+UPDATE messages SET delivered_at = COALESCE(read_at, created_at)
+WHERE is_read = TRUE AND delivered_at IS NULL;
+
+-- "Просмотрено до сообщения X" для каждого участника чата
+CREATE TABLE IF NOT EXISTS chat_read_cursors (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id INTEGER NOT NULL,
+    read_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+INSERT INTO chat_read_cursors (chat_id, user_id, last_read_message_id, read_at)
+SELECT chat_id, receiver_id, MAX(id), COALESCE(MAX(read_at), NOW())
+FROM messages
+WHERE is_read = TRUE
+GROUP BY chat_id, receiver_id
+ON CONFLICT (chat_id, user_id) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_messages_undelivered ON messages(receiver_id, chat_id) WHERE delivered_at IS NULL;
+
+COMMIT;
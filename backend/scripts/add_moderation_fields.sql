@@ -0,0 +1,23 @@
+-- Поля для очереди модерации жалоб
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Назначение модератору
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP;
+
+-- Результат рассмотрения
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS resolution TEXT; -- hide_post, delete_comment, warn_user, dismiss
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS moderator_note TEXT;
+
+-- Очередь: открытые жалобы по дате создания
+CREATE INDEX IF NOT EXISTS idx_reports_queue ON reports(status, created_at)
+WHERE status IN ('pending', 'in_review');
+
+-- Поиск всех жалоб на один объект
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(target_type, target_id);
+
+COMMIT;
@@ -0,0 +1,33 @@
+-- Полное редактирование карточки питомца и история изменений
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Поля карточки, которые раньше не заполнялись через API
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS breed TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS gender TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS birth_date DATE;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS color TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS size TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS status TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS city TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS region TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS urgent INTEGER DEFAULT 0;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS story TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS contact_name TEXT;
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS contact_phone TEXT;
+
+-- История изменений: одна строка на изменённое поле
+CREATE TABLE IF NOT EXISTS pet_changes (
+    id SERIAL PRIMARY KEY,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    field VARCHAR(50) NOT NULL,
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pet_changes_pet ON pet_changes(pet_id, created_at DESC, id DESC);
+
+COMMIT;
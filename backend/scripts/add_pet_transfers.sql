@@ -0,0 +1,60 @@
+-- Передача питомцев между пользователями и организациями, история владения
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Связь организации с питомцем (повторяет pets.organization_id)
+CREATE TABLE IF NOT EXISTS organization_pets (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    notes TEXT,
+    UNIQUE (organization_id, pet_id)
+);
+
+INSERT INTO organization_pets (organization_id, pet_id)
+SELECT organization_id, id FROM pets WHERE organization_id IS NOT NULL
+ON CONFLICT (organization_id, pet_id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS pet_transfers (
+    id SERIAL PRIMARY KEY,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    from_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL,
+    to_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    to_organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+    offered_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')),
+    responded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMP,
+    CHECK ((to_user_id IS NULL) <> (to_organization_id IS NULL))
+);
+
+-- Одно открытое предложение на питомца
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pet_transfers_pending ON pet_transfers(pet_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_pet_transfers_to_user ON pet_transfers(to_user_id, status) WHERE to_user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_pet_transfers_to_org ON pet_transfers(to_organization_id, status) WHERE to_organization_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_pet_transfers_from ON pet_transfers(from_user_id, from_organization_id, status);
+
+-- Provenance: прежние владельцы и кураторы (не удаляются вместе с пользователями)
+CREATE TABLE IF NOT EXISTS pet_ownership_history (
+    id SERIAL PRIMARY KEY,
+    pet_id INTEGER NOT NULL REFERENCES pets(id) ON DELETE CASCADE,
+    from_user_id INTEGER NOT NULL,
+    from_organization_id INTEGER,
+    from_curator_id INTEGER,
+    to_user_id INTEGER NOT NULL,
+    to_organization_id INTEGER,
+    reason VARCHAR(20) NOT NULL,  -- transfer, adoption
+    transfer_id INTEGER REFERENCES pet_transfers(id) ON DELETE SET NULL,
+    changed_by INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pet_ownership_history_pet ON pet_ownership_history(pet_id, created_at DESC, id DESC);
+
+COMMIT;
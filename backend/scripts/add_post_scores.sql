@@ -0,0 +1,27 @@
+-- Предрасчитанные сигналы ранжирования ленты "Для вас"
+-- Заполняется фоновым StartFeedRanker (handlers/feed_ranking.go)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS post_scores (
+    post_id INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL,
+    author_type TEXT NOT NULL,
+    city TEXT NOT NULL DEFAULT '',          -- город автора (users.location / organizations.address_city)
+    created_at TIMESTAMP NOT NULL,
+    likes_total INTEGER NOT NULL DEFAULT 0,
+    comments_total INTEGER NOT NULL DEFAULT 0,
+    likes_recent INTEGER NOT NULL DEFAULT 0,    -- за последние 24 часа
+    comments_recent INTEGER NOT NULL DEFAULT 0, -- за последние 24 часа
+    content_boost INTEGER NOT NULL DEFAULT 0,   -- приоритетные метки + срочные питомцы
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_scores_created ON post_scores(created_at DESC);
+
+-- Для агрегатов за окно ранжирования
+CREATE INDEX IF NOT EXISTS idx_likes_created ON likes(created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_created ON comments(created_at);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Индекс для фоновой публикации отложенных постов
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Планировщик выбирает посты со status = 'scheduled' и наступившим scheduled_at
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_due ON posts(scheduled_at)
+WHERE status = 'scheduled' AND is_deleted = false;
+
+COMMIT;
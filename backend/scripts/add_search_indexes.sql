@@ -0,0 +1,22 @@
+-- GIN индексы для полнотекстового поиска (/api/search)
+-- Выражения должны совпадать с handlers/search.go
+-- Дата: 2026-10-17
+
+CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (
+    (setweight(to_tsvector('russian', coalesce(tags::text, '')), 'A') ||
+     setweight(to_tsvector('russian', coalesce(content, '')), 'B'))
+) WHERE is_deleted = FALSE AND status = 'published';
+
+CREATE INDEX IF NOT EXISTS idx_pets_search ON pets USING GIN (
+    (setweight(to_tsvector('russian', coalesce(name, '')), 'A') ||
+     setweight(to_tsvector('russian', coalesce(breed, '') || ' ' || coalesce(species, '')), 'B'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_pet_announcements_search ON pet_announcements USING GIN (
+    (setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+     setweight(to_tsvector('russian', coalesce(description, '')), 'B'))
+) WHERE is_published = 1 AND status = 'active';
+
+CREATE INDEX IF NOT EXISTS idx_organizations_search ON organizations USING GIN (
+    (setweight(to_tsvector('russian', coalesce(name, '') || ' ' || coalesce(short_name, '')), 'A'))
+) WHERE status = 'active';
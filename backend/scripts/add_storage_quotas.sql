@@ -0,0 +1,15 @@
+-- Квоты хранилища: ручное переопределение квоты пользователя администратором
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_storage_quotas (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    quota_bytes BIGINT NOT NULL, -- -1 - без ограничения
+    set_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Квоты хранилища: размеры аватара и обложки, резервирование места под загрузку
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- Аватар и обложка учитываются в занятом месте (оригинал + варианты).
+-- Для уже загруженных файлов размер неизвестен и считается 0 до следующей загрузки.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cover_photo_size BIGINT NOT NULL DEFAULT 0;
+
+-- Место, занятое загрузкой между проверкой квоты и созданием записи о файле.
+-- Записи удаляются обработчиком; оставшиеся после сбоя не учитываются через час.
+CREATE TABLE IF NOT EXISTS storage_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_reservations_user ON storage_reservations(user_id, created_at);
+
+COMMIT;
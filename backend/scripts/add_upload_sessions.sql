@@ -0,0 +1,27 @@
+-- Сессии возобновляемой загрузки по частям (chunked upload)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id VARCHAR(36) PRIMARY KEY,                   -- upload_id (UUID)
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    media_type VARCHAR(20) NOT NULL,
+    chunk_size INTEGER NOT NULL,
+    total_chunks INTEGER NOT NULL,
+    received_chunks BYTEA NOT NULL,               -- битовая карта: бит i = часть i получена
+    sha256 VARCHAR(64),                           -- ожидаемая контрольная сумма (hex)
+    status VARCHAR(20) NOT NULL DEFAULT 'uploading', -- uploading, assembling, completed, expired
+    media_id INTEGER REFERENCES user_media(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user ON upload_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires ON upload_sessions(status, expires_at);
+
+COMMIT;
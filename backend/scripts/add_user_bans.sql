@@ -0,0 +1,23 @@
+-- Блокировки и временные приостановки аккаунтов
+-- Дата: 2026-10-17
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_bans (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ban_type TEXT NOT NULL DEFAULT 'ban', -- ban, suspension
+    reason TEXT NOT NULL,
+    banned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    starts_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,                 -- NULL - бессрочно
+    lifted_at TIMESTAMP,                  -- досрочное снятие
+    lifted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Проверка в AuthMiddleware на каждый запрос: только действующие блокировки
+CREATE INDEX IF NOT EXISTS idx_user_bans_active ON user_bans(user_id, expires_at)
+WHERE lifted_at IS NULL;
+
+COMMIT;
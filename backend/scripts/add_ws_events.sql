@@ -0,0 +1,16 @@
+-- Рассылка WebSocket событий между экземплярами backend (PostgreSQL LISTEN/NOTIFY)
+-- Дата: 2026-10-17
+
+BEGIN;
+
+-- NOTIFY ограничен 8000 байт: события большего размера сохраняются здесь,
+-- а в канал ws_events отправляется только id записи. Записи живут несколько минут.
+CREATE TABLE IF NOT EXISTS ws_events (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ws_events_created ON ws_events(created_at);
+
+COMMIT;
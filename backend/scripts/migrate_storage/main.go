@@ -0,0 +1,680 @@
+// migrate_storage переносит файлы между локальным хранилищем и S3 и переписывает
+// ссылки на них в БД: user_media, аватары, обложки, вложения постов и сообщений.
+// Каждый файл проверяется по SHA-256 после копирования; ссылка в БД меняется
+// только когда все файлы записи скопированы и проверены.
+//
+//	cd backend/scripts/migrate_storage
+//	go run . -to s3 -dry-run
+//	go run . -to s3
+//	go run . -to local -only avatars,covers -delete-source
+package main
+
+import (
+	"backend/imageproc"
+	"backend/models"
+	"backend/storage"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// Виды ссылок на файлы, которые умеет переносить скрипт
+var allKinds = []string{"media", "avatars", "covers", "posts", "messages"}
+
+type migrator struct {
+	db           *sql.DB
+	target       storage.Storage
+	localRoot    string
+	dryRun       bool
+	deleteSource bool
+	limit        int
+}
+
+// stats - итог по одному виду ссылок
+type stats struct {
+	migrated, skipped, failed int
+	bytes                     int64
+}
+
+func main() {
+	to := flag.String("to", "", "целевое хранилище: local или s3")
+	only := flag.String("only", strings.Join(allKinds, ","), "что переносить: "+strings.Join(allKinds, ","))
+	localRoot := flag.String("uploads", "../../../uploads", "корень локального хранилища")
+	dryRun := flag.Bool("dry-run", false, "только показать, что будет перенесено")
+	deleteSource := flag.Bool("delete-source", false, "удалять исходные файлы после успешного переноса")
+	limit := flag.Int("limit", 0, "максимум переносимых записей каждого вида (0 - все)")
+	flag.Parse()
+
+	// Load .env from backend directory
+	if err := godotenv.Load("../../.env"); err != nil {
+		fmt.Println("⚠️  .env file not found, using environment variables")
+	}
+
+	if *to != storage.BackendLocal && *to != storage.BackendS3 {
+		fmt.Println("ERROR: -to must be local or s3")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	kinds := map[string]bool{}
+	for _, kind := range strings.Split(*only, ",") {
+		kind = strings.TrimSpace(kind)
+		if !contains(allKinds, kind) {
+			fmt.Printf("ERROR: unknown kind %q (expected %s)\n", kind, strings.Join(allKinds, ","))
+			os.Exit(2)
+		}
+		kinds[kind] = true
+	}
+
+	// S3 нужен и как цель, и для чтения уже перенесённых туда файлов
+	if err := storage.InitS3(); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	target, err := storage.Backend(*to, *localRoot)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		fmt.Println("ERROR: DATABASE_URL not set in .env")
+		os.Exit(1)
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		fmt.Printf("ERROR: Failed to ping database: %v\n", err)
+		os.Exit(1)
+	}
+
+	m := &migrator{
+		db:           db,
+		target:       target,
+		localRoot:    *localRoot,
+		dryRun:       *dryRun,
+		deleteSource: *deleteSource,
+		limit:        *limit,
+	}
+
+	fmt.Printf("🚚 Migrating files to %s (dry-run: %v, delete source: %v)\n\n", target.Name(), m.dryRun, m.deleteSource)
+
+	steps := []struct {
+		kind string
+		run  func() (stats, error)
+	}{
+		{"media", m.migrateUserMedia},
+		{"avatars", func() (stats, error) { return m.migrateUserColumn("avatar") }},
+		{"covers", func() (stats, error) { return m.migrateUserColumn("cover_photo") }},
+		{"posts", m.migratePostAttachments},
+		{"messages", m.migrateMessageAttachments},
+	}
+
+	failed := false
+	for _, step := range steps {
+		if !kinds[step.kind] {
+			continue
+		}
+		fmt.Printf("📦 %s\n", step.kind)
+		st, err := step.run()
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", step.kind, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("   migrated: %d, skipped: %d, failed: %d, %.1f MB\n\n",
+			st.migrated, st.skipped, st.failed, float64(st.bytes)/1024/1024)
+		if st.failed > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		fmt.Println("⚠️  Migration finished with errors - rerun to retry failed files")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Migration finished")
+}
+
+// source возвращает хранилище, в котором сейчас лежит файл
+func (m *migrator) source(backend string) (storage.Storage, error) {
+	return storage.Backend(backend, m.localRoot)
+}
+
+// fileCopy - файл записи, который нужно перенести
+type fileCopy struct {
+	key         string
+	contentType string
+	optional    bool // варианты изображений есть не у всех файлов
+}
+
+// copyFiles копирует файлы записи и проверяет SHA-256 каждой копии.
+// При ошибке уже скопированные файлы удаляются из целевого хранилища.
+// Возвращает перенесённые ключи (отсутствующие необязательные файлы пропускаются).
+func (m *migrator) copyFiles(src storage.Storage, files []fileCopy, public bool) ([]string, int64, error) {
+	var copied []string
+	var total int64
+	for _, f := range files {
+		if m.dryRun {
+			in, err := src.Open(f.key)
+			if err != nil {
+				if f.optional {
+					continue
+				}
+				return nil, 0, fmt.Errorf("%s: %v", f.key, err)
+			}
+			in.Close()
+			fmt.Printf("   → %s\n", f.key)
+			copied = append(copied, f.key)
+			continue
+		}
+
+		size, err := m.copyFile(src, f.key, f.contentType, public)
+		if os.IsNotExist(err) && f.optional {
+			continue
+		}
+		if err != nil {
+			for _, key := range copied {
+				m.target.Delete(key)
+			}
+			return nil, 0, fmt.Errorf("%s: %v", f.key, err)
+		}
+		copied = append(copied, f.key)
+		total += size
+	}
+	return copied, total, nil
+}
+
+// copyFile копирует один файл и сверяет SHA-256 прочитанных и записанных данных
+func (m *migrator) copyFile(src storage.Storage, key, contentType string, public bool) (int64, error) {
+	in, err := src.Open(key)
+	if err != nil {
+		if src.Name() == storage.BackendLocal {
+			return 0, err
+		}
+		// Отсутствующий объект S3 трактуется как отсутствующий файл
+		if strings.Contains(err.Error(), "NoSuchKey") {
+			return 0, os.ErrNotExist
+		}
+		return 0, err
+	}
+	defer in.Close()
+
+	hasher := sha256.New()
+	counter := &countingReader{r: io.TeeReader(in, hasher)}
+	if err := m.target.Put(key, counter, contentType, public); err != nil {
+		return 0, err
+	}
+	expected := hex.EncodeToString(hasher.Sum(nil))
+
+	out, err := m.target.Open(key)
+	if err != nil {
+		return 0, fmt.Errorf("verify: %v", err)
+	}
+	defer out.Close()
+	check := sha256.New()
+	if _, err := io.Copy(check, out); err != nil {
+		return 0, fmt.Errorf("verify: %v", err)
+	}
+	if actual := hex.EncodeToString(check.Sum(nil)); actual != expected {
+		m.target.Delete(key)
+		return 0, fmt.Errorf("checksum mismatch: source %s, copy %s", expected, actual)
+	}
+	return counter.n, nil
+}
+
+// removeSources удаляет исходные файлы после того, как ссылки переписаны
+func (m *migrator) removeSources(src storage.Storage, keys []string) {
+	if !m.deleteSource || m.dryRun {
+		return
+	}
+	for _, key := range keys {
+		if err := src.Delete(key); err != nil {
+			fmt.Printf("   ⚠️  failed to delete source %s: %v\n", key, err)
+		}
+	}
+}
+
+// migrateUserMedia переносит файлы медиатеки (оригинал, постер видео, варианты фото).
+// Файлы медиатеки отдаются через /api/media/file, поэтому в S3 они приватные.
+func (m *migrator) migrateUserMedia() (stats, error) {
+	var st stats
+	lastID := 0
+	for !m.done(st) {
+		rows, err := m.db.Query(`
+			SELECT id, file_path, mime_type, COALESCE(poster_path, ''), COALESCE(storage, 'local'), COALESCE(variants, '')
+			FROM user_media
+			WHERE COALESCE(storage, 'local') <> $1 AND COALESCE(status, 'ready') <> 'processing' AND id > $2
+			ORDER BY id LIMIT $3`, m.target.Name(), lastID, batchSize)
+		if err != nil {
+			return st, err
+		}
+
+		type mediaRow struct {
+			id                                              int
+			filePath, mimeType, posterPath, backend, varsJS string
+		}
+		var items []mediaRow
+		for rows.Next() {
+			var r mediaRow
+			if err := rows.Scan(&r.id, &r.filePath, &r.mimeType, &r.posterPath, &r.backend, &r.varsJS); err != nil {
+				rows.Close()
+				return st, err
+			}
+			items = append(items, r)
+		}
+		rows.Close()
+		if len(items) == 0 {
+			break
+		}
+		lastID = items[len(items)-1].id
+
+		for _, r := range items {
+			if m.done(st) {
+				break
+			}
+			src, err := m.source(r.backend)
+			if err != nil {
+				fmt.Printf("   ❌ media %d: %v\n", r.id, err)
+				st.failed++
+				continue
+			}
+
+			files := []fileCopy{{key: r.filePath, contentType: r.mimeType}}
+			if r.posterPath != "" {
+				files = append(files, fileCopy{key: r.posterPath, contentType: "image/jpeg", optional: true})
+			}
+			if r.varsJS != "" {
+				files = append(files, variantCopies(r.filePath)...)
+			}
+
+			copied, size, err := m.copyFiles(src, files, false)
+			if err != nil {
+				fmt.Printf("   ❌ media %d: %v\n", r.id, err)
+				st.failed++
+				continue
+			}
+			if !m.dryRun {
+				res, err := m.db.Exec(`UPDATE user_media SET storage = $1 WHERE id = $2 AND COALESCE(storage, 'local') = $3`,
+					m.target.Name(), r.id, r.backend)
+				if n, _ := rowsAffected(res, err); n == 0 {
+					// Запись удалена или изменена во время переноса - копия не нужна
+					for _, key := range copied {
+						m.target.Delete(key)
+					}
+					fmt.Printf("   ⚠️  media %d changed during migration, skipped\n", r.id)
+					st.skipped++
+					continue
+				}
+				m.removeSources(src, copied)
+			}
+			st.migrated++
+			st.bytes += size
+		}
+	}
+	return st, nil
+}
+
+// migrateUserColumn переносит аватары или обложки (публичные файлы с вариантами)
+func (m *migrator) migrateUserColumn(column string) (stats, error) {
+	var st stats
+	lastID := 0
+	for !m.done(st) {
+		rows, err := m.db.Query(`
+			SELECT id, `+column+` FROM users
+			WHERE `+column+` IS NOT NULL AND `+column+` <> '' AND id > $1
+			ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return st, err
+		}
+
+		type userRow struct {
+			id  int
+			ref string
+		}
+		var items []userRow
+		for rows.Next() {
+			var r userRow
+			if err := rows.Scan(&r.id, &r.ref); err != nil {
+				rows.Close()
+				return st, err
+			}
+			items = append(items, r)
+		}
+		rows.Close()
+		if len(items) == 0 {
+			break
+		}
+		lastID = items[len(items)-1].id
+
+		for _, r := range items {
+			if m.done(st) {
+				break
+			}
+			newRef, src, copied, size, ok := m.migrateRef(r.ref, true, true, &st)
+			if !ok {
+				continue
+			}
+			if !m.dryRun {
+				res, err := m.db.Exec(`UPDATE users SET `+column+` = $1 WHERE id = $2 AND `+column+` = $3`, newRef, r.id, r.ref)
+				if n, _ := rowsAffected(res, err); n == 0 {
+					for _, key := range copied {
+						m.target.Delete(key)
+					}
+					fmt.Printf("   ⚠️  user %d: %s changed during migration, skipped\n", r.id, column)
+					st.skipped++
+					continue
+				}
+				m.removeSources(src, copied)
+			}
+			st.migrated++
+			st.bytes += size
+		}
+	}
+	return st, nil
+}
+
+// migratePostAttachments переносит вложения постов со ссылками /uploads/... или на бакет.
+// Вложения из медиатеки (/api/media/file/{id}) переносятся вместе с user_media.
+func (m *migrator) migratePostAttachments() (stats, error) {
+	var st stats
+	lastID := 0
+	for !m.done(st) {
+		rows, err := m.db.Query(`
+			SELECT id, attachments FROM posts
+			WHERE attachments IS NOT NULL AND attachments NOT IN ('', 'null', '[]') AND id > $1
+			ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return st, err
+		}
+
+		type postRow struct {
+			id   int
+			json string
+		}
+		var items []postRow
+		for rows.Next() {
+			var r postRow
+			if err := rows.Scan(&r.id, &r.json); err != nil {
+				rows.Close()
+				return st, err
+			}
+			items = append(items, r)
+		}
+		rows.Close()
+		if len(items) == 0 {
+			break
+		}
+		lastID = items[len(items)-1].id
+
+		for _, r := range items {
+			if m.done(st) {
+				break
+			}
+			var attachments []models.Attachment
+			if err := json.Unmarshal([]byte(r.json), &attachments); err != nil {
+				fmt.Printf("   ⚠️  post %d: invalid attachments JSON, skipped\n", r.id)
+				st.skipped++
+				continue
+			}
+
+			// Переносим все файлы поста; ссылки переписываются одним UPDATE
+			type moved struct {
+				src  storage.Storage
+				keys []string
+			}
+			var done []moved
+			var size int64
+			changed, failed := false, false
+			copiedKeys := map[string]bool{}
+			migrate := func(ref string, optional bool) string {
+				if failed {
+					return ref
+				}
+				// Варианты уже скопированы вместе с оригиналом - достаточно переписать ссылку
+				if backend, key, ok := storage.ParseRef(ref); ok && backend != m.target.Name() && copiedKeys[key] {
+					changed = true
+					return storage.RefFor(m.target, key, true)
+				}
+				var fileStats stats
+				newRef, src, copied, n, ok := m.migrateRef(ref, true, optional, &fileStats)
+				if fileStats.failed > 0 {
+					failed = true
+				}
+				if !ok {
+					return ref
+				}
+				done = append(done, moved{src, copied})
+				for _, key := range copied {
+					copiedKeys[key] = true
+				}
+				size += n
+				changed = true
+				return newRef
+			}
+
+			for i := range attachments {
+				attachments[i].URL = migrate(attachments[i].URL, false)
+				for name, v := range attachments[i].Variants {
+					if v.WebP != "" {
+						v.WebP = migrate(v.WebP, true)
+					}
+					if v.JPEG != "" {
+						v.JPEG = migrate(v.JPEG, true)
+					}
+					attachments[i].Variants[name] = v
+				}
+			}
+
+			rollback := func() {
+				for _, d := range done {
+					for _, key := range d.keys {
+						m.target.Delete(key)
+					}
+				}
+			}
+			if failed {
+				rollback()
+				st.failed++
+				continue
+			}
+			if !changed {
+				st.skipped++
+				continue
+			}
+			if !m.dryRun {
+				data, _ := json.Marshal(attachments)
+				res, err := m.db.Exec(`UPDATE posts SET attachments = $1 WHERE id = $2 AND attachments = $3`, string(data), r.id, r.json)
+				if n, _ := rowsAffected(res, err); n == 0 {
+					rollback()
+					fmt.Printf("   ⚠️  post %d changed during migration, skipped\n", r.id)
+					st.skipped++
+					continue
+				}
+				for _, d := range done {
+					m.removeSources(d.src, d.keys)
+				}
+			}
+			st.migrated++
+			st.bytes += size
+		}
+	}
+	return st, nil
+}
+
+// migrateMessageAttachments переносит вложения сообщений. В S3 они приватные (s3://ключ).
+func (m *migrator) migrateMessageAttachments() (stats, error) {
+	var st stats
+	lastID := 0
+	for !m.done(st) {
+		rows, err := m.db.Query(`SELECT id, file_path FROM message_attachments WHERE id > $1 ORDER BY id LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return st, err
+		}
+
+		type attachmentRow struct {
+			id  int
+			ref string
+		}
+		var items []attachmentRow
+		for rows.Next() {
+			var r attachmentRow
+			if err := rows.Scan(&r.id, &r.ref); err != nil {
+				rows.Close()
+				return st, err
+			}
+			items = append(items, r)
+		}
+		rows.Close()
+		if len(items) == 0 {
+			break
+		}
+		lastID = items[len(items)-1].id
+
+		for _, r := range items {
+			if m.done(st) {
+				break
+			}
+			newRef, src, copied, size, ok := m.migrateRef(r.ref, false, false, &st)
+			if !ok {
+				continue
+			}
+			if !m.dryRun {
+				res, err := m.db.Exec(`UPDATE message_attachments SET file_path = $1 WHERE id = $2 AND file_path = $3`, newRef, r.id, r.ref)
+				if n, _ := rowsAffected(res, err); n == 0 {
+					for _, key := range copied {
+						m.target.Delete(key)
+					}
+					st.skipped++
+					continue
+				}
+				m.removeSources(src, copied)
+			}
+			st.migrated++
+			st.bytes += size
+		}
+	}
+	return st, nil
+}
+
+// migrateRef копирует файл по ссылке из БД (вместе с вариантами изображения, если они есть)
+// и возвращает новую ссылку. ok=false - ссылку трогать не нужно: она уже в целевом
+// хранилище, внешняя или файл не удалось перенести (это учитывается в st).
+func (m *migrator) migrateRef(ref string, public, optional bool, st *stats) (string, storage.Storage, []string, int64, bool) {
+	backend, key, ok := storage.ParseRef(ref)
+	if !ok || backend == m.target.Name() {
+		// Приватная ссылка s3:// в публичном поле или наоборот тоже считается перенесённой
+		st.skipped++
+		return ref, nil, nil, 0, false
+	}
+	src, err := m.source(backend)
+	if err != nil {
+		fmt.Printf("   ❌ %s: %v\n", ref, err)
+		st.failed++
+		return ref, nil, nil, 0, false
+	}
+
+	files := []fileCopy{{key: key, contentType: contentTypeFor(key), optional: optional}}
+	if public && isVariantSource(key) {
+		files = append(files, variantCopies(key)...)
+	}
+
+	copied, size, err := m.copyFiles(src, files, public)
+	if err != nil {
+		fmt.Printf("   ❌ %s: %v\n", ref, err)
+		st.failed++
+		return ref, nil, nil, 0, false
+	}
+	if len(copied) == 0 {
+		// Необязательный файл отсутствует в источнике
+		st.skipped++
+		return ref, nil, nil, 0, false
+	}
+	return storage.RefFor(m.target, key, public), src, copied, size, true
+}
+
+// batchSize - записей за один запрос. Записи выбираются по возрастанию id после
+// последней обработанной, поэтому пропущенные (уже перенесённые) не читаются снова.
+const batchSize = 500
+
+// done - достигнут лимит -limit. Пропущенные и неудачные записи в лимит не входят,
+// поэтому повторный запуск с тем же -limit переносит следующие записи.
+func (m *migrator) done(st stats) bool {
+	return m.limit > 0 && st.migrated >= m.limit
+}
+
+// variantCopies - варианты изображения рядом с оригиналом (копируются, если существуют)
+func variantCopies(originalKey string) []fileCopy {
+	base := strings.TrimSuffix(originalKey, filepath.Ext(originalKey))
+	var files []fileCopy
+	for _, s := range imageproc.Sizes {
+		files = append(files,
+			fileCopy{key: imageproc.VariantFileName(base, s.Name, ".webp"), contentType: "image/webp", optional: true},
+			fileCopy{key: imageproc.VariantFileName(base, s.Name, ".jpg"), contentType: "image/jpeg", optional: true},
+		)
+	}
+	return files
+}
+
+// isVariantSource - у оригиналов изображений могут быть варианты; сами варианты их не имеют
+func isVariantSource(key string) bool {
+	base := strings.TrimSuffix(filepath.Base(key), filepath.Ext(key))
+	for _, s := range imageproc.Sizes {
+		if strings.HasSuffix(base, "_"+s.Name) {
+			return false
+		}
+	}
+	return strings.HasPrefix(contentTypeFor(key), "image/")
+}
+
+func contentTypeFor(key string) string {
+	if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(key))); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func rowsAffected(res sql.Result, err error) (int64, error) {
+	if err != nil {
+		fmt.Printf("   ❌ update failed: %v\n", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader считает прочитанные байты
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
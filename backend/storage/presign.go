@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultPresignTTL - срок действия presigned URL, если S3_PRESIGN_TTL_MINUTES не задан
+const DefaultPresignTTL = 15 * time.Minute
+
+// PresignTTL возвращает срок действия presigned URL
+func PresignTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("S3_PRESIGN_TTL_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return DefaultPresignTTL
+}
+
+// Enabled - настроено ли S3-хранилище
+func Enabled() bool {
+	return UseS3 && GlobalS3Client != nil
+}
+
+// CompletedPart - часть multipart-загрузки, подтверждённая клиентом (ETag из ответа S3)
+type CompletedPart struct {
+	PartNumber int64  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// ObjectInfo - метаданные загруженного объекта
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// PresignPut возвращает URL для прямой загрузки объекта одним PUT-запросом.
+// Клиент обязан отправить заголовки из возвращаемого http.Header (Content-Type, Content-Length).
+func (c *S3Client) PresignPut(key, contentType string, size int64, ttl time.Duration) (string, http.Header, error) {
+	req, _ := c.presign.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	url, headers, err := req.PresignRequest(ttl)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to presign PUT: %v", err)
+	}
+	return url, headers, nil
+}
+
+// CreateMultipartUpload начинает multipart-загрузку и возвращает её UploadId
+func (c *S3Client) CreateMultipartUpload(key, contentType string) (string, error) {
+	out, err := c.svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %v", err)
+	}
+	return aws.StringValue(out.UploadId), nil
+}
+
+// PresignUploadPart возвращает URL для загрузки одной части (нумерация с 1)
+func (c *S3Client) PresignUploadPart(key, uploadID string, partNumber int64, ttl time.Duration) (string, error) {
+	req, _ := c.presign.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
+	})
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign part %d: %v", partNumber, err)
+	}
+	return url, nil
+}
+
+// CompleteMultipartUpload собирает объект из загруженных частей
+func (c *S3Client) CompleteMultipartUpload(key, uploadID string, parts []CompletedPart) error {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			PartNumber: aws.Int64(p.PartNumber),
+			ETag:       aws.String(p.ETag),
+		})
+	}
+
+	_, err := c.svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %v", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload отменяет multipart-загрузку и освобождает загруженные части
+func (c *S3Client) AbortMultipartUpload(key, uploadID string) error {
+	_, err := c.svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %v", err)
+	}
+	return nil
+}
+
+// HeadObject возвращает размер и тип объекта (проверка после прямой загрузки)
+func (c *S3Client) HeadObject(key string) (ObjectInfo, error) {
+	out, err := c.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("object %s not found: %v", key, err)
+	}
+	return ObjectInfo{
+		Size:        aws.Int64Value(out.ContentLength),
+		ContentType: aws.StringValue(out.ContentType),
+	}, nil
+}
+
+// PresignGet возвращает короткоживущий URL для чтения приватного объекта.
+// downloadName (опционально) задаёт имя файла при скачивании.
+func (c *S3Client) PresignGet(key string, ttl time.Duration, downloadName string) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}
+	if downloadName != "" {
+		input.ResponseContentDisposition = aws.String(fmt.Sprintf("inline; filename=%q", downloadName))
+	}
+
+	req, _ := c.presign.GetObjectRequest(input)
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign GET: %v", err)
+	}
+	return url, nil
+}
+
+// PutPrivateFile загружает локальный файл в S3 без публичного доступа
+func (c *S3Client) PutPrivateFile(localPath, key, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	return c.PutPrivateObject(file, key, contentType)
+}
+
+// PutPrivateObject загружает данные в S3 без публичного доступа
+func (c *S3Client) PutPrivateObject(body io.ReadSeeker, key, contentType string) error {
+	_, err := c.svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %v", err)
+	}
+	return nil
+}
+
+// DownloadFile скачивает объект в локальный файл (например, для транскодирования)
+func (c *S3Client) DownloadFile(key, localPath string) error {
+	out, err := c.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", key, err)
+	}
+	defer out.Body.Close()
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := dst.ReadFrom(out.Body); err != nil {
+		os.Remove(localPath)
+		return fmt.Errorf("failed to download %s: %v", key, err)
+	}
+	return nil
+}
+
+// DeleteObject удаляет объект по ключу
+func (c *S3Client) DeleteObject(key string) error {
+	_, err := c.svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from S3: %v", err)
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"mime/multipart"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -21,10 +20,14 @@ import (
 type S3Client struct {
 	session  *session.Session
 	uploader *s3manager.Uploader
+	svc      *s3.S3
+	presign  *s3.S3 // клиент для подписи URL (S3_PUBLIC_ENDPOINT, если задан)
 	bucket   string
 	region   string
 	endpoint string
 	cdnURL   string // URL для доступа к файлам (если используется CDN)
+	// publicEndpoint - адрес хранилища для клиентов (S3_PUBLIC_ENDPOINT или S3_ENDPOINT)
+	publicEndpoint string
 }
 
 var (
@@ -51,6 +54,10 @@ func InitS3() error {
 	accessKey := os.Getenv("S3_ACCESS_KEY")
 	secretKey := os.Getenv("S3_SECRET_KEY")
 	cdnURL := os.Getenv("S3_CDN_URL") // Опционально
+	// Адрес хранилища, видимый клиентам (MinIO в docker: S3_ENDPOINT=http://minio:9000,
+	// S3_PUBLIC_ENDPOINT=http://localhost:9000). Подпись включает хост, поэтому
+	// presigned URL подписываются для этого адреса. Опционально.
+	publicEndpoint := os.Getenv("S3_PUBLIC_ENDPOINT")
 
 	// Проверяем обязательные параметры
 	if endpoint == "" || region == "" || bucket == "" || accessKey == "" || secretKey == "" {
@@ -72,13 +79,24 @@ func InitS3() error {
 	// Создаем uploader
 	uploader := s3manager.NewUploader(sess)
 
+	svc := s3.New(sess)
+	presign := svc
+	if publicEndpoint != "" && publicEndpoint != endpoint {
+		presign = s3.New(sess, &aws.Config{Endpoint: aws.String(publicEndpoint)})
+	} else {
+		publicEndpoint = endpoint
+	}
+
 	GlobalS3Client = &S3Client{
-		session:  sess,
-		uploader: uploader,
-		bucket:   bucket,
-		region:   region,
-		endpoint: endpoint,
-		cdnURL:   cdnURL,
+		session:        sess,
+		uploader:       uploader,
+		svc:            svc,
+		presign:        presign,
+		bucket:         bucket,
+		region:         region,
+		endpoint:       endpoint,
+		publicEndpoint: publicEndpoint,
+		cdnURL:         cdnURL,
 	}
 
 	UseS3 = true
@@ -156,8 +174,7 @@ func (c *S3Client) DeleteFile(fileURL string) error {
 		return fmt.Errorf("invalid file URL")
 	}
 
-	svc := s3.New(c.session)
-	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+	_, err := c.svc.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(c.bucket),
 		Key:    aws.String(key),
 	})
@@ -171,77 +188,87 @@ func (c *S3Client) DeleteFile(fileURL string) error {
 
 // extractKeyFromURL извлекает S3 ключ из URL
 func (c *S3Client) extractKeyFromURL(fileURL string) string {
-	// Если используется CDN
-	if c.cdnURL != "" && strings.HasPrefix(fileURL, c.cdnURL) {
-		return strings.TrimPrefix(fileURL, c.cdnURL+"/")
+	if key, ok := strings.CutPrefix(fileURL, "s3://"); ok {
+		return key
 	}
+	return c.keyFromPublicURL(fileURL)
+}
 
-	// Если прямой S3 URL
-	// Формат: https://bucket.s3.region.amazonaws.com/key
-	// или: https://endpoint/bucket/key
-	parts := strings.Split(fileURL, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+// PublicURL возвращает постоянный URL публичного объекта (CDN, если настроен)
+func (c *S3Client) PublicURL(key string) string {
+	if c.cdnURL != "" {
+		return strings.TrimRight(c.cdnURL, "/") + "/" + key
 	}
-
-	return ""
+	// Path-style: {endpoint}/{bucket}/{key}
+	return strings.TrimRight(c.publicEndpoint, "/") + "/" + c.bucket + "/" + key
 }
 
-// SaveFile сохраняет файл (в S3 или локально в зависимости от конфигурации)
-func SaveFile(file multipart.File, filename string, contentType string) (string, error) {
-	if UseS3 && GlobalS3Client != nil {
-		// Загружаем в S3
-		return GlobalS3Client.UploadFile(file, filename, contentType)
+// keyFromPublicURL извлекает ключ из URL CDN или бакета; "" - URL не относится к бакету
+func (c *S3Client) keyFromPublicURL(fileURL string) string {
+	prefixes := []string{
+		strings.TrimRight(c.endpoint, "/") + "/" + c.bucket + "/",
+		strings.TrimRight(c.publicEndpoint, "/") + "/" + c.bucket + "/",
 	}
-
-	// Сохраняем локально (fallback)
-	return saveFileLocally(file, filename)
+	if c.cdnURL != "" {
+		prefixes = append(prefixes, strings.TrimRight(c.cdnURL, "/")+"/")
+	}
+	for _, prefix := range prefixes {
+		if key, ok := strings.CutPrefix(fileURL, prefix); ok && key != "" {
+			return key
+		}
+	}
+	return ""
 }
 
-// DeleteFile удаляет файл (из S3 или локально)
-func DeleteFile(fileURL string) error {
-	if UseS3 && GlobalS3Client != nil {
-		// Удаляем из S3
-		return GlobalS3Client.DeleteFile(fileURL)
+// putObject загружает данные потоково; public=true - с ACL public-read
+func (c *S3Client) putObject(key string, body io.Reader, contentType string, public bool) error {
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
 	}
-
-	// Удаляем локально (fallback)
-	// Извлекаем путь из URL (например /uploads/file.jpg -> ../../uploads/file.jpg)
-	if strings.HasPrefix(fileURL, "/uploads/") {
-		localPath := filepath.Join("../../uploads", strings.TrimPrefix(fileURL, "/uploads/"))
-		return os.Remove(localPath)
+	if public {
+		input.ACL = aws.String("public-read")
+	}
+	if _, err := c.uploader.Upload(input); err != nil {
+		return fmt.Errorf("failed to upload to S3: %v", err)
 	}
-
 	return nil
 }
 
-// saveFileLocally сохраняет файл локально
-func saveFileLocally(file multipart.File, filename string) (string, error) {
-	// Определяем директорию по типу файла
-	uploadDir := "../../uploads"
-
-	// Создаем директорию если не существует
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %v", err)
-	}
-
-	// Полный путь к файлу
-	fullPath := filepath.Join(uploadDir, filename)
-
-	// Создаем файл
-	dst, err := os.Create(fullPath)
+// openObject открывает объект для потокового чтения
+func (c *S3Client) openObject(key string) (io.ReadCloser, error) {
+	out, err := c.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
+		return nil, fmt.Errorf("failed to download %s: %v", key, err)
 	}
-	defer dst.Close()
+	return out.Body, nil
+}
 
-	// Копируем содержимое
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %v", err)
+// SaveFile сохраняет файл в текущее хранилище (Default) и возвращает его URL
+func SaveFile(file multipart.File, filename string, contentType string) (string, error) {
+	s := Default()
+	if err := s.Put(filename, file, contentType, true); err != nil {
+		return "", err
 	}
+	return s.URL(filename), nil
+}
 
-	// Возвращаем относительный URL
-	return fmt.Sprintf("/uploads/%s", filename), nil
+// DeleteFile удаляет файл по сохранённой ссылке (/uploads/..., s3://... или URL бакета)
+func DeleteFile(fileURL string) error {
+	backend, key, ok := ParseRef(fileURL)
+	if !ok {
+		return nil
+	}
+	s, err := Backend(backend, UploadPath())
+	if err != nil {
+		return err
+	}
+	return s.Delete(key)
 }
 
 // GetFileURL возвращает URL файла (с CDN если настроен)
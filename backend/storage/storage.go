@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Имена бэкендов хранилища (совпадают со значениями user_media.storage)
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Storage - хранилище файлов. Ключ - путь относительно корня хранилища
+// (например users/1/avatars/abc.jpg), одинаковый для всех бэкендов.
+type Storage interface {
+	// Name возвращает имя бэкенда (BackendLocal, BackendS3)
+	Name() string
+	// Put записывает файл. public=false - файл отдаётся только по подписанной ссылке
+	Put(key string, body io.Reader, contentType string, public bool) error
+	// Open открывает файл для чтения
+	Open(key string) (io.ReadCloser, error)
+	// Delete удаляет файл; отсутствие файла не считается ошибкой
+	Delete(key string) error
+	// URL возвращает постоянную ссылку на публичный файл
+	URL(key string) string
+}
+
+// UploadPath - корень локального хранилища (UPLOAD_PATH, по умолчанию ../../uploads)
+func UploadPath() string {
+	if path := os.Getenv("UPLOAD_PATH"); path != "" {
+		return path
+	}
+	return "../../uploads"
+}
+
+// Default возвращает текущее хранилище: S3, если оно настроено, иначе локальное
+func Default() Storage {
+	if Enabled() {
+		return NewS3Storage(GlobalS3Client)
+	}
+	return NewLocalStorage(UploadPath())
+}
+
+// Backend возвращает хранилище по имени. Для S3 клиент должен быть инициализирован (InitS3).
+func Backend(name, localRoot string) (Storage, error) {
+	switch name {
+	case BackendLocal:
+		return NewLocalStorage(localRoot), nil
+	case BackendS3:
+		if !Enabled() {
+			return nil, fmt.Errorf("S3 storage is not configured (USE_S3=true and S3_* variables are required)")
+		}
+		return NewS3Storage(GlobalS3Client), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", name)
+}
+
+// ParseRef определяет бэкенд и ключ по ссылке на файл, сохранённой в БД:
+// /uploads/{key} - локальный файл, s3://{key} - приватный объект S3,
+// URL CDN или бакета - публичный объект S3. Внешние ссылки не распознаются.
+func ParseRef(ref string) (backend, key string, ok bool) {
+	if key, ok := strings.CutPrefix(ref, "/uploads/"); ok && key != "" {
+		return BackendLocal, key, true
+	}
+	if key, ok := strings.CutPrefix(ref, "s3://"); ok && key != "" {
+		return BackendS3, key, true
+	}
+	if GlobalS3Client != nil {
+		if key := GlobalS3Client.keyFromPublicURL(ref); key != "" {
+			return BackendS3, key, true
+		}
+	}
+	return "", "", false
+}
+
+// RefFor возвращает ссылку для сохранения в БД: публичный URL или s3://{key} для приватных объектов
+func RefFor(s Storage, key string, public bool) string {
+	if !public && s.Name() == BackendS3 {
+		return "s3://" + key
+	}
+	return s.URL(key)
+}
+
+// LocalStorage - файлы на диске, раздаются статикой /uploads/
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+func (s *LocalStorage) Name() string { return BackendLocal }
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(key))
+}
+
+func (s *LocalStorage) Put(key string, body io.Reader, contentType string, public bool) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// Пишем во временный файл, чтобы при ошибке не оставить обрезанный файл под ключом
+	tmp := path + ".part"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	if _, err := io.Copy(dst, body); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save file: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save file: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return "/uploads/" + key
+}
+
+// S3Storage - объекты в бакете S3
+type S3Storage struct {
+	client *S3Client
+}
+
+func NewS3Storage(client *S3Client) *S3Storage {
+	return &S3Storage{client: client}
+}
+
+func (s *S3Storage) Name() string { return BackendS3 }
+
+func (s *S3Storage) Put(key string, body io.Reader, contentType string, public bool) error {
+	return s.client.putObject(key, body, contentType, public)
+}
+
+func (s *S3Storage) Open(key string) (io.ReadCloser, error) {
+	return s.client.openObject(key)
+}
+
+func (s *S3Storage) Delete(key string) error {
+	return s.client.DeleteObject(key)
+}
+
+func (s *S3Storage) URL(key string) string {
+	return s.client.PublicURL(key)
+}